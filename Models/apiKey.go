@@ -0,0 +1,149 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognizable, e.g. by secret scanners.
+const APIKeyPrefix = "rk_"
+
+// apiKeyLastUsedPrecision is how often the last use of an API key is recorded at most, so not every request
+// writes to the database.
+const apiKeyLastUsedPrecision = time.Minute
+
+// ErrInvalidAPIKey is returned by AuthenticateAPIKey if the key is unknown, revoked or expired.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKey is a named key a user created to authenticate scripts without their password, see AuthenticateAPIKey.
+// Prefix is the start of the key to tell keys apart; the key itself is only returned once, by CreateAPIKey.
+// Scopes lists the permissions the key is limited to, on top of the permissions of the roles of the user; an
+// empty list allows all of them. ExpiresAt is nil for a key that does not expire, and LastUsedAt for a key that
+// was never used; the last use is recorded to the minute.
+type APIKey struct {
+	ID         int64
+	UserID     int64 `json:"-"`
+	Name       string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// CreateAPIKey creates an API key for the user with the given name, scopes and expiry, which may be nil.
+// Only the hash of the key is stored; the key is returned so it can be sent to the client once.
+func CreateAPIKey(userId int64, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	key := APIKeyPrefix + token
+
+	apiKey := APIKey{
+		UserID:    userId,
+		Name:      name,
+		Prefix:    key[:len(APIKeyPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []string{}
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		apiKey.ExpiresAt = &utc
+	}
+
+	query := `
+	INSERT INTO api_keys(userId, name, prefix, keyHash, scopes, expiresAt, createdAt)
+	VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	err = db.DB.QueryRow(db.Rebind(query), userId, name, apiKey.Prefix, utils.HashToken(key), strings.Join(apiKey.Scopes, " "),
+		apiKey.ExpiresAt, apiKey.CreatedAt).Scan(&apiKey.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return &apiKey, key, nil
+}
+
+// GetAPIKeys returns the API keys of the user that were not revoked, including expired ones, newest first.
+func GetAPIKeys(userId int64) ([]APIKey, error) {
+	query := `
+	SELECT id, userId, name, prefix, scopes, expiresAt, lastUsedAt, createdAt FROM api_keys
+	WHERE userId = ? AND revokedAt IS NULL ORDER BY id DESC`
+	rows, err := db.DB.Query(db.Rebind(query), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []APIKey{}
+	for rows.Next() {
+		var apiKey APIKey
+		var scopes string
+		err = rows.Scan(&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.ExpiresAt, &apiKey.LastUsedAt, &apiKey.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		apiKey.Scopes = strings.Fields(scopes)
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+// RevokeAPIKey revokes the API key with the given ID of the user, so it stops working at once.
+// It returns sql.ErrNoRows if the user has no such key or it was already revoked.
+func RevokeAPIKey(userId, id int64) error {
+	query := "UPDATE api_keys SET revokedAt = ? WHERE id = ? AND userId = ? AND revokedAt IS NULL"
+	result, err := db.DB.Exec(db.Rebind(query), time.Now().UTC(), id, userId)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the API key that was presented and records that it was used.
+// It returns ErrInvalidAPIKey if the key is unknown, revoked or expired.
+func AuthenticateAPIKey(key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	var apiKey APIKey
+	var scopes string
+	query := `
+	SELECT id, userId, name, prefix, scopes, expiresAt, lastUsedAt, createdAt FROM api_keys
+	WHERE keyHash = ? AND revokedAt IS NULL AND (expiresAt IS NULL OR expiresAt > ?)`
+	err := db.DB.QueryRow(db.Rebind(query), utils.HashToken(key), now).
+		Scan(&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.ExpiresAt, &apiKey.LastUsedAt, &apiKey.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	apiKey.Scopes = strings.Fields(scopes)
+
+	if apiKey.LastUsedAt == nil || apiKey.LastUsedAt.Before(now.Add(-apiKeyLastUsedPrecision)) {
+		_, err = db.DB.Exec(db.Rebind("UPDATE api_keys SET lastUsedAt = ? WHERE id = ?"), now, apiKey.ID)
+		if err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+	return &apiKey, nil
+}
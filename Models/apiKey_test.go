@@ -0,0 +1,123 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		apiKey, key, err := CreateAPIKey(userId, "deploy", []string{"events:create", "events:register"}, nil)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		if !strings.HasPrefix(key, apiKey.Prefix) || !strings.HasPrefix(key, APIKeyPrefix) {
+			t.Errorf("CreateAPIKey returned the key %q with the prefix %q, want it to start with both", key, apiKey.Prefix)
+		}
+
+		authenticated, err := AuthenticateAPIKey(key)
+		if err != nil {
+			t.Fatalf("AuthenticateAPIKey: %v", err)
+		}
+		if authenticated.ID != apiKey.ID || authenticated.UserID != userId ||
+			!slices.Equal(authenticated.Scopes, []string{"events:create", "events:register"}) {
+			t.Errorf("AuthenticateAPIKey = %+v, want the created key %+v", authenticated, apiKey)
+		}
+		if authenticated.LastUsedAt == nil {
+			t.Fatal("AuthenticateAPIKey did not record the use of the key")
+		}
+
+		// Uses within apiKeyLastUsedPrecision are not recorded again.
+		lastUsedAt := authenticated.LastUsedAt.Add(-apiKeyLastUsedPrecision / 2).Truncate(time.Second)
+		_, err = db.DB.Exec(db.Rebind("UPDATE api_keys SET lastUsedAt = ? WHERE id = ?"), lastUsedAt, apiKey.ID)
+		if err != nil {
+			t.Fatalf("Could not backdate the last use: %v", err)
+		}
+		authenticated, err = AuthenticateAPIKey(key)
+		if err != nil {
+			t.Fatalf("AuthenticateAPIKey: %v", err)
+		}
+		if authenticated.LastUsedAt == nil || !authenticated.LastUsedAt.Equal(lastUsedAt) {
+			t.Errorf("AuthenticateAPIKey within the precision recorded the last use %v, want %v", authenticated.LastUsedAt, lastUsedAt)
+		}
+
+		for _, invalid := range []string{"", key[len(APIKeyPrefix):], APIKeyPrefix + "unknown", strings.ToUpper(key)} {
+			_, err = AuthenticateAPIKey(invalid)
+			if !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("AuthenticateAPIKey(%q): got %v, want ErrInvalidAPIKey", invalid, err)
+			}
+		}
+	})
+}
+
+func TestExpiredAPIKey(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		expiresAt := time.Now().Add(time.Hour)
+		apiKey, key, err := CreateAPIKey(userId, "expiring", nil, &expiresAt)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		_, err = AuthenticateAPIKey(key)
+		if err != nil {
+			t.Fatalf("AuthenticateAPIKey before the key expired: %v", err)
+		}
+
+		_, err = db.DB.Exec(db.Rebind("UPDATE api_keys SET expiresAt = ? WHERE id = ?"), time.Now().UTC().Add(-time.Second), apiKey.ID)
+		if err != nil {
+			t.Fatalf("Could not backdate the expiry: %v", err)
+		}
+		_, err = AuthenticateAPIKey(key)
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("AuthenticateAPIKey of an expired key: got %v, want ErrInvalidAPIKey", err)
+		}
+
+		apiKeys, err := GetAPIKeys(userId)
+		if err != nil || len(apiKeys) != 1 || apiKeys[0].ID != apiKey.ID || apiKeys[0].ExpiresAt == nil {
+			t.Errorf("GetAPIKeys = %+v, %v, want the expired key with its expiry", apiKeys, err)
+		}
+	})
+}
+
+func TestRevokedAPIKey(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		otherId := createTestUser(t, "grace@example.com")
+		revoked, key, err := CreateAPIKey(userId, "revoked", nil, nil)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		kept, _, err := CreateAPIKey(userId, "kept", nil, nil)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+
+		err = RevokeAPIKey(otherId, revoked.ID)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("RevokeAPIKey of the key of another user: got %v, want sql.ErrNoRows", err)
+		}
+		err = RevokeAPIKey(userId, revoked.ID)
+		if err != nil {
+			t.Fatalf("RevokeAPIKey: %v", err)
+		}
+		_, err = AuthenticateAPIKey(key)
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("AuthenticateAPIKey of a revoked key: got %v, want ErrInvalidAPIKey", err)
+		}
+		err = RevokeAPIKey(userId, revoked.ID)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("RevokeAPIKey of a revoked key: got %v, want sql.ErrNoRows", err)
+		}
+
+		apiKeys, err := GetAPIKeys(userId)
+		if err != nil || len(apiKeys) != 1 || apiKeys[0].ID != kept.ID {
+			t.Errorf("GetAPIKeys = %+v, %v, want only the key that was not revoked", apiKeys, err)
+		}
+	})
+}
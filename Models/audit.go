@@ -0,0 +1,198 @@
+package models
+
+import (
+	"RestAPI/db"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The types of the targets of audit entries.
+const (
+	AuditTargetEvent = "event"
+	AuditTargetUser  = "user"
+	AuditTargetRole  = "role"
+)
+
+// DefaultAuditLimit and MaxAuditLimit bound the number of entries returned by GetAuditLog.
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+// AuditEntry records one change made through the API. ActorID is the user who made the change, nil if it was
+// made anonymously, e.g. by signing up. Action names the change, like "event.update", and TargetType and
+// TargetID the event, user or role it was made to; roles have no ID, so their TargetID is 0 and After names
+// the role. Before and After hold the fields that changed with their previous and new values as JSON objects;
+// Before is null for created and After for removed targets.
+// RequestID identifies the request that made the change, see middlewares.RequestID.
+type AuditEntry struct {
+	ID         int64
+	ActorID    *int64
+	Action     string
+	TargetType string
+	TargetID   int64
+	Before     json.RawMessage
+	After      json.RawMessage
+	RequestID  string
+	CreatedAt  time.Time
+}
+
+// AuditFilter holds the filters of GetAuditLog. It is bound from the query string of GET /audit.
+//   - ActorID: only changes made by this user.
+//   - TargetType / TargetID: only changes to targets of this type, and with this ID.
+//   - From / To: only changes made within the range (both inclusive, RFC 3339).
+//   - Before: only entries with a smaller ID, to page through the log with the ID of the last entry of a page.
+//   - Limit: the number of entries, DefaultAuditLimit if unset and at most MaxAuditLimit.
+type AuditFilter struct {
+	ActorID    int64     `form:"actorId"`
+	TargetType string    `form:"targetType" binding:"omitempty,oneof=event user role"`
+	TargetID   int64     `form:"targetId"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Before     int64     `form:"before"`
+	Limit      int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// RecordAudit appends the entry to the audit log. Its Before and After are computed from the given values,
+// which are compared by their JSON representation; either may be nil if the target was created or removed.
+func RecordAudit(entry AuditEntry, before, after any) error {
+	var err error
+	entry.Before, entry.After, err = auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO audit_log(actorId, action, targetType, targetId, before, after, requestId, createdAt)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.DB.Exec(db.Rebind(query), entry.ActorID, entry.Action, entry.TargetType, entry.TargetID,
+		nullableJSON(entry.Before), nullableJSON(entry.After), entry.RequestID, time.Now().UTC())
+	return err
+}
+
+// GetAuditLog returns the entries of the audit log matching the filter, newest first.
+func GetAuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	var conditions []string
+	var args []any
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "actorId = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "targetType = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		conditions = append(conditions, "targetId = ?")
+		args = append(args, filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "createdAt >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "createdAt <= ?")
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Before != 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+	args = append(args, min(limit, MaxAuditLimit))
+
+	query := "SELECT id, actorId, action, targetType, targetId, before, after, requestId, createdAt FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	rows, err := db.DB.Query(db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after []byte
+		err = rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &before, &after, &entry.RequestID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After = jsonOrNull(before), jsonOrNull(after)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// auditDiff returns the fields of before and after whose values differ, as two JSON objects with the values
+// of before and of after. A nil value gives JSON null and the other value in full.
+func auditDiff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for name, value := range beforeFields {
+			if other, ok := afterFields[name]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, name)
+				delete(afterFields, name)
+			}
+		}
+	}
+
+	beforeJSON, err := json.Marshal(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := json.Marshal(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+// auditFields converts the value to the fields of its JSON object. It returns nil for a nil value.
+func auditFields(value any) (map[string]any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// nullableJSON returns the JSON document as a string to store, or nil to store NULL for JSON null.
+func nullableJSON(document json.RawMessage) any {
+	if document == nil || string(document) == "null" {
+		return nil
+	}
+	return string(document)
+}
+
+// jsonOrNull returns the stored JSON document, or JSON null for NULL.
+func jsonOrNull(document []byte) json.RawMessage {
+	if document == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(document)
+}
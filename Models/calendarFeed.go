@@ -0,0 +1,44 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"time"
+)
+
+// IssueCalendarFeedToken creates the token of the user's calendar feed, replacing any previous token.
+// The token is part of the feed's URL and authorizes reading the feed, since calendar applications can not
+// send an Authorization header. Only the hash of the token is stored; the plain token is returned so it can
+// be sent to the client once. Issuing a new token revokes the previous URL.
+func IssueCalendarFeedToken(userId int64) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	INSERT INTO calendar_feeds(userId, tokenHash, createdAt) VALUES (?, ?, ?)
+	ON CONFLICT(userId) DO UPDATE SET tokenHash = excluded.tokenHash, createdAt = excluded.createdAt`
+	_, err = db.DB.Exec(db.Rebind(query), userId, utils.HashToken(token), time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RevokeCalendarFeedToken removes the token of the user's calendar feed, so the feed URL stops working.
+// Revoking a feed that does not exist is not an error.
+func RevokeCalendarFeedToken(userId int64) error {
+	_, err := db.DB.Exec(db.Rebind("DELETE FROM calendar_feeds WHERE userId = ?"), userId)
+	return err
+}
+
+// GetCalendarFeedUser returns the ID of the user whose calendar feed the token belongs to.
+// It returns sql.ErrNoRows if the token is unknown or was revoked.
+func GetCalendarFeedUser(token string) (int64, error) {
+	var userId int64
+	query := "SELECT userId FROM calendar_feeds WHERE tokenHash = ?"
+	err := db.DB.QueryRow(db.Rebind(query), utils.HashToken(token)).Scan(&userId)
+	return userId, err
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"RestAPI/config"
+	"RestAPI/db"
+	"RestAPI/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Errors of the email verification.
+var (
+	// ErrEmailAlreadyVerified is returned by IssueEmailVerificationToken if the email of the user is verified.
+	ErrEmailAlreadyVerified = errors.New("the email is already verified")
+	// ErrVerificationThrottled is returned by IssueEmailVerificationToken if a verification email was sent to
+	// the user less than EmailVerificationResendInterval ago.
+	ErrVerificationThrottled = errors.New("a verification email was sent recently")
+	// ErrInvalidVerificationToken is returned by VerifyEmail if the token is invalid, expired or was issued for
+	// an email the user no longer has.
+	ErrInvalidVerificationToken = errors.New("the email verification token is invalid or expired")
+)
+
+// EmailVerificationTTL returns how long the links of verification emails are valid, configured by
+// EMAIL_VERIFICATION_TTL.
+func EmailVerificationTTL() time.Duration {
+	return config.Duration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+}
+
+// EmailVerificationResendInterval returns how long a user has to wait before another verification email is
+// sent, configured by EMAIL_VERIFICATION_RESEND_INTERVAL.
+func EmailVerificationResendInterval() time.Duration {
+	return config.Duration("EMAIL_VERIFICATION_RESEND_INTERVAL", 5*time.Minute)
+}
+
+// EmailVerificationRequired reports whether users must verify their email before they create events or register
+// for them, configured by REQUIRE_VERIFIED_EMAIL.
+func EmailVerificationRequired() bool {
+	return config.Bool("REQUIRE_VERIFIED_EMAIL", false)
+}
+
+// IssueEmailVerificationToken creates the token for the verification email of the user and records that it was
+// sent. It returns the email to send it to together with the token.
+// It returns sql.ErrNoRows if the user does not exist, ErrEmailAlreadyVerified if the email is verified and
+// ErrVerificationThrottled if the last verification email was sent less than EmailVerificationResendInterval ago.
+func IssueEmailVerificationToken(userId int64) (string, string, error) {
+	// Claiming the send in one statement lets only one of concurrent requests send an email.
+	now := time.Now().UTC()
+	query := `
+	UPDATE users SET verificationSentAt = ?
+	WHERE id = ? AND emailVerifiedAt IS NULL AND (verificationSentAt IS NULL OR verificationSentAt <= ?)
+	RETURNING email`
+	var email string
+	err := db.DB.QueryRow(db.Rebind(query), now, userId, now.Add(-EmailVerificationResendInterval())).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		var verifiedAt *time.Time
+		err = db.DB.QueryRow(db.Rebind("SELECT emailVerifiedAt FROM users WHERE id = ?"), userId).Scan(&verifiedAt)
+		if err != nil {
+			return "", "", err
+		}
+		if verifiedAt != nil {
+			return "", "", ErrEmailAlreadyVerified
+		}
+		return "", "", ErrVerificationThrottled
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	token, err := utils.GenerateEmailVerificationToken(userId, email, EmailVerificationTTL())
+	if err != nil {
+		return "", "", err
+	}
+	return email, token, nil
+}
+
+// VerifyEmail marks the email the token was issued for as verified and returns the ID of its user. Verifying an
+// email twice is not an error, so following the link again is harmless.
+// It returns ErrInvalidVerificationToken if the token is invalid or expired, or the user no longer has the email.
+func VerifyEmail(token string) (int64, error) {
+	userId, email, err := utils.VerifyEmailVerificationToken(token)
+	if err != nil {
+		return 0, ErrInvalidVerificationToken
+	}
+
+	query := "UPDATE users SET emailVerifiedAt = COALESCE(emailVerifiedAt, ?) WHERE id = ? AND email = ?"
+	result, err := db.DB.Exec(db.Rebind(query), time.Now().UTC(), userId, email)
+	if err != nil {
+		return 0, err
+	}
+
+	verified, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if verified == 0 {
+		return 0, ErrInvalidVerificationToken
+	}
+	return userId, nil
+}
+
+// IsEmailVerified reports whether the user with the given ID has verified their email.
+// It returns sql.ErrNoRows if the user does not exist.
+func IsEmailVerified(userId int64) (bool, error) {
+	var verifiedAt *time.Time
+	err := db.DB.QueryRow(db.Rebind("SELECT emailVerifiedAt FROM users WHERE id = ?"), userId).Scan(&verifiedAt)
+	return verifiedAt != nil, err
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+// initTestKeys sets up an ephemeral signing key for the tokens mailed to users.
+func initTestKeys(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_KEYS_FILE", "")
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("JWT_SECRET", "")
+	utils.InitKeys()
+}
+
+func TestEmailVerificationResendThrottle(t *testing.T) {
+	initTestKeys(t)
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		email, token, err := IssueEmailVerificationToken(userId)
+		if err != nil || email != "ada@example.com" || token == "" {
+			t.Fatalf("IssueEmailVerificationToken = %q, %q, %v, want a token for ada@example.com", email, token, err)
+		}
+
+		_, _, err = IssueEmailVerificationToken(userId)
+		if !errors.Is(err, ErrVerificationThrottled) {
+			t.Errorf("IssueEmailVerificationToken right after the first one: got %v, want ErrVerificationThrottled", err)
+		}
+
+		sentAt := time.Now().UTC().Add(-EmailVerificationResendInterval() - time.Second)
+		_, err = db.DB.Exec(db.Rebind("UPDATE users SET verificationSentAt = ? WHERE id = ?"), sentAt, userId)
+		if err != nil {
+			t.Fatalf("Could not backdate the verification email: %v", err)
+		}
+		_, _, err = IssueEmailVerificationToken(userId)
+		if err != nil {
+			t.Errorf("IssueEmailVerificationToken after the resend interval: %v", err)
+		}
+
+		_, _, err = IssueEmailVerificationToken(userId + 1000)
+		if err == nil {
+			t.Error("IssueEmailVerificationToken of an unknown user succeeded, want an error")
+		}
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	initTestKeys(t)
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		_, token, err := IssueEmailVerificationToken(userId)
+		if err != nil {
+			t.Fatalf("IssueEmailVerificationToken: %v", err)
+		}
+
+		verifiedId, err := VerifyEmail(token)
+		if err != nil || verifiedId != userId {
+			t.Fatalf("VerifyEmail = %d, %v, want the user %d", verifiedId, err, userId)
+		}
+		var verifiedAt time.Time
+		err = db.DB.QueryRow(db.Rebind("SELECT emailVerifiedAt FROM users WHERE id = ?"), userId).Scan(&verifiedAt)
+		if err != nil {
+			t.Fatalf("Could not read the verification time: %v", err)
+		}
+		verifiedId, err = VerifyEmail(token)
+		if err != nil || verifiedId != userId {
+			t.Fatalf("VerifyEmail again = %d, %v, want following the link twice to be harmless", verifiedId, err)
+		}
+		var reverifiedAt time.Time
+		err = db.DB.QueryRow(db.Rebind("SELECT emailVerifiedAt FROM users WHERE id = ?"), userId).Scan(&reverifiedAt)
+		if err != nil || !reverifiedAt.Equal(verifiedAt) {
+			t.Errorf("Verifying again moved the verification time from %v to %v, %v, want it kept", verifiedAt, reverifiedAt, err)
+		}
+		_, _, err = IssueEmailVerificationToken(userId)
+		if !errors.Is(err, ErrEmailAlreadyVerified) {
+			t.Errorf("IssueEmailVerificationToken of a verified email: got %v, want ErrEmailAlreadyVerified", err)
+		}
+
+		_, err = VerifyEmail("not a token")
+		if !errors.Is(err, ErrInvalidVerificationToken) {
+			t.Errorf("VerifyEmail of an invalid token: got %v, want ErrInvalidVerificationToken", err)
+		}
+	})
+}
+
+func TestVerifyEmailAfterEmailChange(t *testing.T) {
+	initTestKeys(t)
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		_, token, err := IssueEmailVerificationToken(userId)
+		if err != nil {
+			t.Fatalf("IssueEmailVerificationToken: %v", err)
+		}
+		_, err = db.DB.Exec(db.Rebind("UPDATE users SET email = ? WHERE id = ?"), "lovelace@example.com", userId)
+		if err != nil {
+			t.Fatalf("Could not change the email: %v", err)
+		}
+
+		_, err = VerifyEmail(token)
+		if !errors.Is(err, ErrInvalidVerificationToken) {
+			t.Errorf("VerifyEmail of a token for the previous email: got %v, want ErrInvalidVerificationToken", err)
+		}
+		verified, err := IsEmailVerified(userId)
+		if err != nil || verified {
+			t.Errorf("IsEmailVerified = %v, %v, want the new email to be unverified", verified, err)
+		}
+	})
+}
@@ -2,50 +2,246 @@ package models
 
 import (
 	"RestAPI/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrVersionMismatch is returned by Event.Update and Event.UpdateOccurrences if the event was changed since the
+// version the update is based on.
+var ErrVersionMismatch = errors.New("the event was changed since the version the update is based on")
+
 // Event represents an event with its properties and methods.
+// The binding rules are checked whenever an event is bound from a request, so creating and updating an event
+// share them: for example, an event that has an end ends after it starts. That an event starts in the future is
+// only required of new events and of updates that move the start, see ValidateEventStart.
+// The rule "rrule" and the messages of all rules are registered by InitValidation.
+// A recurring event has a RecurrenceRule, an RFC 5545 RRULE value like FREQ=WEEKLY;COUNT=10. Its DateTime and
+// EndDateTime are those of the first occurrence, and ExceptionDates lists the starts of excluded occurrences.
+// TimeZone is the IANA time zone the event takes place in, DefaultTimeZone if not given. The datetimes are stored
+// in UTC and rendered in the time zone, and recurring events keep their local time across daylight saving time changes.
+// Status is the lifecycle state of the event, see EventDraft. An event is created as a draft or published, and
+// changes its status later only through SetEventStatus.
+// DeletedAt is set while the event is in the trash, see Event.Delete; events in the trash are not returned by the
+// other functions of the store.
+// Version is incremented by every write to the event. It is not bound from requests; updates that must not
+// overwrite changes made in the meantime pass the version they are based on, see Event.Update.
 type Event struct {
-	ID          int64
-	Name        string    `binding:"required"`
-	Description string    `binding:"required"`
-	Location    string    `binding:"required"`
-	DateTime    time.Time `binding:"required"`
-	UserID      int64
+	ID             int64
+	Name           string     `binding:"required,max=200"`
+	Description    string     `binding:"required,max=5000"`
+	Location       string     `binding:"required,max=200"`
+	DateTime       time.Time  `binding:"required"`
+	EndDateTime    *time.Time `binding:"omitempty,gtfield=DateTime"`
+	TimeZone       string     `binding:"omitempty,timezone"`
+	Status         string     `binding:"omitempty,oneof=draft published"`
+	DeletedAt      *time.Time `json:",omitempty"`
+	Version        int64      `binding:"-"`
+	UserID         int64
+	Capacity       *int64      `binding:"omitempty,min=1"`
+	RecurrenceRule string      `json:",omitempty" binding:"omitempty,max=500,rrule"`
+	ExceptionDates []time.Time `json:",omitempty" binding:"omitempty,max=1000"`
+}
+
+// eventColumns lists the columns of the events table in the order scanEvent expects them.
+const eventColumns = "id, name, description, location, dateTime, user_id, capacity, endDateTime, recurrenceRule, exceptionDates, timeZone, status, deletedAt, version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEvent scans a row selected with eventColumns into an Event, with its datetimes in the event's time zone.
+// Columns selected after eventColumns are scanned into extra.
+func scanEvent(row rowScanner, extra ...any) (Event, error) {
+	var event Event
+	var recurrenceRule, exceptionDates sql.NullString
+	dest := []any{&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID, &event.Capacity, &event.EndDateTime,
+		&recurrenceRule, &exceptionDates, &event.TimeZone, &event.Status, &event.DeletedAt, &event.Version}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return event, err
+	}
+
+	event.RecurrenceRule = recurrenceRule.String
+	if exceptionDates.Valid {
+		err = json.Unmarshal([]byte(exceptionDates.String), &event.ExceptionDates)
+	}
+	return event.InZone(""), err
+}
+
+// recurrenceColumns returns the values of the recurrenceRule and exceptionDates columns of the event.
+// Events that do not recur store NULL in both.
+func recurrenceColumns(event Event) (any, any, error) {
+	if event.RecurrenceRule == "" {
+		return nil, nil, nil
+	}
+	if len(event.ExceptionDates) == 0 {
+		return event.RecurrenceRule, nil, nil
+	}
+
+	exceptionDates := make([]time.Time, len(event.ExceptionDates))
+	for i, exceptionDate := range event.ExceptionDates {
+		exceptionDates[i] = exceptionDate.UTC()
+	}
+	data, err := json.Marshal(exceptionDates)
+	if err != nil {
+		return nil, nil, err
+	}
+	return event.RecurrenceRule, string(data), nil
+}
+
+// utcTime returns the optional time t in UTC.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
 }
 
 // events is a slice of Event structs, used to store a collection of events.
 var events = []Event{}
 
-// Save saves the event to the database. It inserts a new record into the "events" table,
-// with the event's name, description, location, datetime, and user_id as values.
-// It returns an error if there is an issue with the database query or execution.
-// The last inserted ID is retrieved and assigned to the event's ID field.
+// Save saves the event to the configured EventStore, as a draft unless its Status is EventPublished.
+// The ID assigned by the database is set on the event.
+// It returns an error if the event could not be saved.
 func (event *Event) Save() error {
-	query := `
-	INSERT INTO events(name, description, location, dateTime, user_id) 
-	VALUES (?, ?, ?, ?, ?)`
-	stmt, err := db.DB.Prepare(query)
+	return Events.Save(event)
+}
+
+// SaveEvents saves all of the events to the configured EventStore in one transaction, setting their IDs.
+// If any of them can not be saved, none of them is saved and the error is returned.
+func SaveEvents(events []Event) error {
+	return Events.SaveAll(events)
+}
+
+// GetAllEvents retrieves all events from the configured EventStore and returns them as a slice of Event structs.
+// If an error occurs, it returns nil and the error.
+func GetAllEvents() ([]Event, error) {
+	return Events.GetAll()
+}
+
+// GetEventByID retrieves an event from the configured EventStore based on the provided event ID.
+// If the event is found, it is returned along with nil error. If no event is found,
+// or an error occurs during the fetching process, nil event and the error are returned.
+func GetEventByID(id int64) (*Event, error) {
+	return Events.GetByID(id)
+}
+
+// Update updates the name, description, location, dateTime, end, capacity, recurrence and time zone of the event
+// with the event's ID in the configured EventStore. If the capacity was raised or removed, waitlisted registrations
+// are promoted to fill the new seats. If the start of a recurring event moves, the registrations move with their
+// occurrences.
+// The status of the event is not changed, see SetEventStatus.
+// If the event has a Version, the update is only made if the stored event still has that version, and
+// ErrVersionMismatch is returned otherwise. A Version of 0 updates the event whatever its version.
+// It returns ErrEventClosed if the event was cancelled or completed, ErrRecurrenceChange if the event has
+// registrations and would start or stop recurring, and an error if the update operation fails.
+func (event Event) Update() error {
+	return Events.Update(event)
+}
+
+// Delete moves the event to the trash of the configured EventStore. It is hidden from then on, but keeps its
+// registrations until it is restored with RestoreEvent or purged with PurgeTrash.
+// Returns ErrEventHasRegistrations if the event is published and users are registered for it, since they
+// should be notified by cancelling the event instead, and an error if the deletion operation fails.
+func (event Event) Delete() error {
+	return Events.Delete(event.ID)
+}
+
+// Register registers the user with the given ID for the event and returns the resulting registration.
+// A recurring event is registered for per occurrence, given by its start; for other events the occurrence must be nil.
+// If the event has a capacity and all seats (of the occurrence) are taken, the user is put on the waitlist instead,
+// ordered by the time of registration. Concurrent registrations can not oversell the event.
+// Registering a user that is already registered or waitlisted returns the existing registration.
+// Returns ErrEventNotOpen if the event is not published, ErrOccurrenceRequired, ErrNotRecurring or
+// ErrNotAnOccurrence if the occurrence does not fit the event, and an error if the registration could not be saved.
+func (event Event) Register(userId int64, occurrence *time.Time) (*Registration, error) {
+	key, err := event.occurrenceKey(occurrence)
+	if err != nil {
+		return nil, err
+	}
+	return Registrations.Register(event.ID, userId, key)
+}
+
+// CancelRegistration removes the registration of the user with the given ID for the event, or for the
+// given occurrence of a recurring event. If the cancelled registration held a seat, the next users on the
+// waitlist are promoted to fill the free seats.
+// Returns the errors of Register if the occurrence does not fit the event, and an error if the registration
+// could not be removed.
+func (event Event) CancelRegistration(userId int64, occurrence *time.Time) error {
+	key, err := event.occurrenceKey(occurrence)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-	result, err := stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.UserID)
+	return Registrations.Cancel(event.ID, userId, key)
+}
+
+// Save inserts a new record into the "events" table, see insertEvent.
+func (sqlEventStore) Save(event *Event) error {
+	return insertEvent(db.DB, event)
+}
+
+// SaveAll inserts the events in one transaction, so either all of them or none are saved.
+func (sqlEventStore) SaveAll(events []Event) error {
+	tx, err := db.DB.Begin()
 	if err != nil {
 		return err
 	}
-	id, err := result.LastInsertId()
-	event.ID = id
-	return err
+	defer tx.Rollback()
+
+	for i := range events {
+		err = insertEvent(tx, &events[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// GetAllEvents retrieves all events from the database and returns them as a slice of Event structs.
-// It executes the SQL query "SELECT * FROM events" and scans the results into Event objects.
-// If an error occurs during the database query or scanning process, it returns nil and the error.
-// Otherwise, it returns the slice of events and nil error.
-func GetAllEvents() ([]Event, error) {
-	query := "SELECT * FROM events"
+// rowQuerier is implemented by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// insertEvent inserts a new record into the "events" table, with the event's name, description, location,
+// datetime, user_id, capacity, end datetime, recurrence and time zone as values, and assigns the returned ID to
+// the event's ID field. The datetimes are stored in UTC, so stored values compare and sort chronologically;
+// the event is then expressed in its time zone like events read from the store. Events without a status are drafts.
+func insertEvent(querier rowQuerier, event *Event) error {
+	recurrenceRule, exceptionDates, err := recurrenceColumns(*event)
+	if err != nil {
+		return err
+	}
+	if event.TimeZone == "" {
+		event.TimeZone = DefaultTimeZone
+	}
+	if event.Status == "" {
+		event.Status = EventDraft
+	}
+
+	query := `
+	INSERT INTO events(name, description, location, dateTime, user_id, capacity, endDateTime, recurrenceRule, exceptionDates, timeZone, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`
+	row := querier.QueryRow(db.Rebind(query), event.Name, event.Description, event.Location, event.DateTime.UTC(), event.UserID, event.Capacity, utcTime(event.EndDateTime),
+		recurrenceRule, exceptionDates, event.TimeZone, event.Status)
+	err = row.Scan(&event.ID)
+	if err != nil {
+		return err
+	}
+
+	event.Version = 1
+	*event = event.InZone("")
+	return nil
+}
+
+// GetAll executes the SQL query "SELECT ... FROM events" for the events not in the trash and scans the results into Event objects.
+func (sqlEventStore) GetAll() ([]Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE deletedAt IS NULL"
 	rows, err := db.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -55,8 +251,7 @@ func GetAllEvents() ([]Event, error) {
 	var events []Event
 
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+		event, err := scanEvent(rows)
 
 		if err != nil {
 			return nil, err
@@ -65,19 +260,16 @@ func GetAllEvents() ([]Event, error) {
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-// GetEventByID retrieves an event from the database based on the provided event ID.
-// It executes a SQL SELECT query and scans the retrieved row into an Event struct.
-// If the event is found, it is returned along with nil error. If no event is found,
-// or an error occurs during the fetching process, nil event and the error are returned.
-func GetEventByID(id int64) (*Event, error) {
-	query := "SELECT * FROM events WHERE id = ?"
-	row := db.DB.QueryRow(query, id)
+// GetByID executes a SQL SELECT query for the event ID and scans the retrieved row into an Event struct.
+// It returns sql.ErrNoRows if no event has that ID or the event is in the trash.
+func (sqlEventStore) GetByID(id int64) (*Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ? AND deletedAt IS NULL"
+	row := db.DB.QueryRow(db.Rebind(query), id)
 
-	var event Event
-	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+	event, err := scanEvent(row)
 	if err != nil {
 		return nil, err
 	}
@@ -85,90 +277,130 @@ func GetEventByID(id int64) (*Event, error) {
 	return &event, nil
 }
 
-// Update updates the event details in the events table.
-// It updates the name, description, location, and dateTime fields for the event with the specified ID.
-// It returns an error if the update operation fails.
-// The function prepares an SQL UPDATE statement to update the event details.
-// It uses the global variable db.DB of type *sql.DB to prepare the statement.
-// Once prepared, the statement is closed at the end of the function.
-// Then, it executes the prepared statement and passes the updated event details as arguments.
-// It returns the result of the Exec method, which is the number of affected rows and an error if any.
-// The error is returned and can be handled by the calling code accordingly.
-func (event Event) Update() error {
-	query := `
-	UPDATE events
-	SET name = ?, description = ?, location = ?, dateTime = ?
-	WHERE id = ?
-	`
-	stmt, err := db.DB.Prepare(query)
+// Update updates the event row and promotes waitlisted registrations in one transaction, see updateEventInTx.
+func (sqlEventStore) Update(event Event) error {
+	tx, err := db.DB.Begin()
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
-
-	_, err = stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.ID)
-	return err
-}
+	defer tx.Rollback()
 
-// Delete removes the event from the database using the event's ID.
-// It executes the SQL query "DELETE FROM events WHERE id = ?" on the global DB connection.
-// Returns an error if the deletion operation fails.
-func (event Event) Delete() error {
-	query := "DELETE FROM events WHERE id = ?"
-	stmt, err := db.DB.Prepare(query)
+	previous, err := selectEventForUpdate(tx, event.ID)
+	if err != nil {
+		return err
+	}
+	if event.Version != 0 && event.Version != previous.Version {
+		return ErrVersionMismatch
+	}
 
+	err = updateEventInTx(tx, previous, event)
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	return tx.Commit()
+}
 
-	_, err = stmt.Exec(event.ID)
-	return err
+// selectEventForUpdate loads the event within the transaction. On PostgreSQL its row is locked until the end of
+// the transaction, like lockEvent does for registrations; SQLite transactions hold the write lock anyway.
+// It returns sql.ErrNoRows if the event does not exist or is in the trash.
+func selectEventForUpdate(tx *sql.Tx, id int64) (Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ? AND deletedAt IS NULL"
+	if db.Driver == db.Postgres {
+		query += " FOR UPDATE"
+	}
+	return scanEvent(tx.QueryRow(db.Rebind(query), id))
 }
 
-// Register inserts a new registration record in the database for the given event and user ID.
-// It executes an SQL query to insert the event ID and user ID into the "registrations" table.
-// Returns an error if there was an issue preparing the SQL statement or executing the query.
-func (event Event) Register(userId int64) error {
-	query := "INSERT INTO registrations(eventId, userId) VALUES (?,?)"
+// updateEventInTx replaces the stored previous version of the event with the event and promotes waitlisted
+// registrations to fill seats added by a raised capacity. Like Save, it stores the datetimes in UTC.
+// If a recurring event's start moves, the occurrences of its registrations are shifted by the same amount.
+// The status is kept. It returns ErrEventClosed if the event was cancelled or completed and ErrRecurrenceChange
+// if the event has registrations and would start or stop recurring.
+func updateEventInTx(tx *sql.Tx, previous, event Event) error {
+	if previous.IsClosed() {
+		return ErrEventClosed
+	}
 
-	stmt, err := db.DB.Prepare(query)
+	if previous.IsRecurring() != event.IsRecurring() {
+		var registrations int
+		err := tx.QueryRow(db.Rebind("SELECT COUNT(*) FROM registrations WHERE eventId = ?"), event.ID).Scan(&registrations)
+		if err != nil {
+			return err
+		}
+		if registrations > 0 {
+			return ErrRecurrenceChange
+		}
+	}
 
+	if event.IsRecurring() && previous.IsRecurring() {
+		shift := event.DateTime.Unix() - previous.DateTime.Unix()
+		_, err := tx.Exec(db.Rebind("UPDATE registrations SET occurrence = occurrence + ? WHERE eventId = ?"), shift, event.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	recurrenceRule, exceptionDates, err := recurrenceColumns(event)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	if event.TimeZone == "" {
+		event.TimeZone = DefaultTimeZone
+	}
 
-	_, err = stmt.Exec(event.ID, userId)
+	query := `
+	UPDATE events
+	SET name = ?, description = ?, location = ?, dateTime = ?, capacity = ?, endDateTime = ?, recurrenceRule = ?, exceptionDates = ?, timeZone = ?,
+	version = version + 1
+	WHERE id = ?
+	`
+	_, err = tx.Exec(db.Rebind(query), event.Name, event.Description, event.Location, event.DateTime.UTC(), event.Capacity, utcTime(event.EndDateTime),
+		recurrenceRule, exceptionDates, event.TimeZone, event.ID)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return promoteAllWaitlisted(tx, event.ID)
 }
 
-// CancelRegistration deletes a registration record from the "registrations" table
-// for a specific event and user. It takes a userId as a parameter and uses the
-// eventId from the Event struct on which it is called.
-// It prepares a SQL DELETE statement with placeholders for the eventId and userId values.
-// It then executes the statement with the eventId and userId as arguments, deleting the
-// registration record from the database.
-// Returns an error if there was any issue with preparing the statement or executing the query.
-func (event Event) CancelRegistration(userId int64) error {
-	query := "DELETE FROM registrations WHERE eventId = ? AND userId = ?"
+// Delete checks the registrations of the event and moves it to the trash in one transaction.
+func (sqlEventStore) Delete(id int64) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
 
-	stmt, err := db.DB.Prepare(query)
+	defer tx.Rollback()
 
+	event, err := selectEventForUpdate(tx, id)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	if event.Status == EventPublished {
+		var registrations int
+		err = tx.QueryRow(db.Rebind("SELECT COUNT(*) FROM registrations WHERE eventId = ?"), id).Scan(&registrations)
+		if err != nil {
+			return err
+		}
+		if registrations > 0 {
+			return ErrEventHasRegistrations
+		}
+	}
 
-	_, err = stmt.Exec(event.ID, userId)
+	err = trashEventInTx(tx, id)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return tx.Commit()
+}
+
+// trashEventInTx moves the event to the trash within the transaction by setting its deletedAt.
+func trashEventInTx(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec(db.Rebind("UPDATE events SET deletedAt = ?, version = version + 1 WHERE id = ?"), time.Now().UTC(), id)
+	return err
 }
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"RestAPI/calendar"
+	"RestAPI/problems"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxImportRows is the maximum number of events in a single import file.
+const MaxImportRows = 1000
+
+// ErrInvalidImport is returned, wrapped with a description of the problem, if an import file can not be read
+// as a whole, e.g. because it is not a CSV or iCalendar file or the column mapping does not match it.
+var ErrInvalidImport = errors.New("invalid import file")
+
+// importFields are the fields of Event that can be imported, in the order of a CSV file without a mapping.
+var importFields = []string{"Name", "Description", "Location", "DateTime", "EndDateTime", "Capacity", "RecurrenceRule", "TimeZone", "Status"}
+
+// ImportRow is an event read from an import file. Line is the line of the file the event starts on, and
+// Errors lists every field of the event that could not be parsed or failed validation.
+type ImportRow struct {
+	Line   int
+	Event  Event
+	Errors []problems.FieldError
+}
+
+// ParseEventsCSV reads events owned by the given user from a CSV file and validates them with the binding
+// rules of Event, so imported events follow the same rules as events created through the API.
+// The first record is the header. The columns map assigns an event field (see importFields) to the name of
+// the header holding it; fields without a mapping are read from the header named like the field, ignoring case.
+// Times are RFC 3339, e.g. 2030-01-01T09:00:00+01:00, and empty EndDateTime and Capacity values are left unset.
+// A RecurrenceRule is an RRULE value, e.g. FREQ=WEEKLY;COUNT=10, and a TimeZone an IANA time zone, e.g. Europe/Berlin.
+// The Status is draft or published; events without one are imported as drafts.
+// It returns an error wrapping ErrInvalidImport if the file can not be read, a mapped header does not exist
+// or the file holds more than MaxImportRows events.
+func ParseEventsCSV(r io.Reader, columns map[string]string, userId int64) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read the CSV header: %v", ErrInvalidImport, err)
+	}
+
+	indexes, err := mapColumns(header, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("%w: more than %d events", ErrInvalidImport, MaxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		values := map[string]string{}
+		for field, index := range indexes {
+			values[field] = strings.TrimSpace(record[index])
+		}
+
+		row := ImportRow{Line: line, Event: Event{UserID: userId}}
+		row.setText(values)
+		row.Event.DateTime = row.parseTime("DateTime", values["DateTime"])
+		if values["EndDateTime"] != "" {
+			end := row.parseTime("EndDateTime", values["EndDateTime"])
+			row.Event.EndDateTime = &end
+		}
+		if values["Capacity"] != "" {
+			capacity, err := strconv.ParseInt(values["Capacity"], 10, 64)
+			if err != nil {
+				row.addError("Capacity", "format", "must be a whole number")
+			}
+			row.Event.Capacity = &capacity
+		}
+		row.Event.RecurrenceRule = values["RecurrenceRule"]
+		row.Event.TimeZone = values["TimeZone"]
+		row.Event.Status = strings.ToLower(values["Status"])
+
+		row.validate()
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// ParseEventsICS reads the VEVENTs of an iCalendar file as events owned by the given user and validates them
+// like ParseEventsCSV. SUMMARY becomes the name, DESCRIPTION the description, LOCATION the location, and
+// DTSTART and DTEND the dateTime and end, and RRULE and EXDATE the recurrence. The TZID of DTSTART becomes the
+// time zone of the event. The calendar format has no capacity, so imported events have none.
+// It returns an error wrapping ErrInvalidImport if the file is not an iCalendar document or holds more than
+// MaxImportRows events.
+func ParseEventsICS(r io.Reader, userId int64) ([]ImportRow, error) {
+	components, err := calendar.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(components) > MaxImportRows {
+		return nil, fmt.Errorf("%w: more than %d events", ErrInvalidImport, MaxImportRows)
+	}
+
+	rows := make([]ImportRow, 0, len(components))
+	for _, component := range components {
+		row := ImportRow{Line: component.Line, Event: Event{UserID: userId}}
+		row.setText(map[string]string{
+			"Name":        component.Properties["SUMMARY"].Text(),
+			"Description": component.Properties["DESCRIPTION"].Text(),
+			"Location":    component.Properties["LOCATION"].Text(),
+		})
+
+		if start, ok := component.Properties["DTSTART"]; ok {
+			row.Event.DateTime = row.parseCalendarTime("DateTime", start)
+			row.Event.TimeZone = start.Params["TZID"]
+		}
+		if end, ok := component.Properties["DTEND"]; ok {
+			endTime := row.parseCalendarTime("EndDateTime", end)
+			row.Event.EndDateTime = &endTime
+		}
+		row.Event.RecurrenceRule = component.Properties["RRULE"].Value
+		for _, exceptionDates := range component.All["EXDATE"] {
+			row.Event.ExceptionDates = append(row.Event.ExceptionDates, row.parseCalendarTimes("ExceptionDates", exceptionDates)...)
+		}
+
+		row.validate()
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// mapColumns returns the index of the CSV column holding each event field, given the header of the file.
+// Fields that are neither mapped nor have a header of their name are left out.
+func mapColumns(header []string, columns map[string]string) (map[string]int, error) {
+	byName := map[string]int{}
+	for i, name := range header {
+		byName[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for field := range columns {
+		known := false
+		for _, importField := range importFields {
+			known = known || importField == field
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: the field %q can not be imported", ErrInvalidImport, field)
+		}
+	}
+
+	indexes := map[string]int{}
+	for _, field := range importFields {
+		name, mapped := columns[field]
+		if !mapped {
+			name = field
+		}
+		index, ok := byName[strings.ToLower(name)]
+		if !ok && mapped {
+			return nil, fmt.Errorf("%w: the column %q mapped to %s does not exist", ErrInvalidImport, name, field)
+		}
+		if ok {
+			indexes[field] = index
+		}
+	}
+	return indexes, nil
+}
+
+// setText sets the name, description and location of the row's event from the values of those fields.
+func (row *ImportRow) setText(values map[string]string) {
+	row.Event.Name = values["Name"]
+	row.Event.Description = values["Description"]
+	row.Event.Location = values["Location"]
+}
+
+// parseTime parses an RFC 3339 value of the field, recording an error if it is invalid.
+// An empty value is left to validation, which reports required fields.
+func (row *ImportRow) parseTime(field, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		row.addError(field, "format", "must be an RFC 3339 date and time")
+	}
+	return t
+}
+
+// parseCalendarTime parses a DATE-TIME or DATE property of the field, recording an error if it is invalid.
+func (row *ImportRow) parseCalendarTime(field string, property calendar.Property) time.Time {
+	t, err := property.Time()
+	if err != nil {
+		row.addError(field, "format", "must be an iCalendar date or date and time in a known time zone")
+	}
+	return t
+}
+
+// parseCalendarTimes parses a property holding a list of DATE-TIME or DATE values of the field, like EXDATE,
+// recording an error if it is invalid.
+func (row *ImportRow) parseCalendarTimes(field string, property calendar.Property) []time.Time {
+	times, err := property.Times()
+	if err != nil {
+		row.addError(field, "format", "must be iCalendar dates or dates and times in a known time zone")
+	}
+	return times
+}
+
+// addError records that the field of the row is invalid.
+func (row *ImportRow) addError(field, rule, reason string) {
+	row.Errors = append(row.Errors, problems.FieldError{Row: row.Line, Field: field, Rule: rule, Reason: reason})
+}
+
+// validate checks the row's event against the binding rules of Event and, as it is a new event, that it starts in
+// the future, see ValidateEventStart. Fields that already failed to parse are not validated again, since their
+// zero value would only be reported as missing.
+func (row *ImportRow) validate() {
+	var fieldErrors []problems.FieldError
+	var validationErrors validator.ValidationErrors
+	err := binding.Validator.ValidateStruct(&row.Event)
+	if errors.As(err, &validationErrors) {
+		fieldErrors = problems.FieldErrors(validationErrors)
+	}
+	if fieldError := ValidateEventStart(row.Event, time.Time{}); fieldError != nil {
+		fieldErrors = append(fieldErrors, *fieldError)
+	}
+
+	invalid := map[string]bool{}
+	for _, fieldError := range row.Errors {
+		invalid[fieldError.Field] = true
+	}
+	for _, fieldError := range fieldErrors {
+		if !invalid[fieldError.Field] {
+			fieldError.Row = row.Line
+			row.Errors = append(row.Errors, fieldError)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rowErrors returns the rule every invalid field of the row failed, by field.
+func rowErrors(row ImportRow) map[string]string {
+	rules := map[string]string{}
+	for _, fieldError := range row.Errors {
+		rules[fieldError.Field] = fieldError.Rule
+	}
+	return rules
+}
+
+func TestParseEventsCSV(t *testing.T) {
+	InitValidation()
+	file := strings.Join([]string{
+		"Title, Venue, Description, datetime, capacity, Notes",
+		`Go Meetup, Berlin, "Talks, food", 2030-01-01T18:00:00+01:00, 20, bring a laptop`,
+		", Hamburg, Talks, yesterday, many, ",
+		"Rust Meetup, Munich, Talks, 2001-01-01T18:00:00Z, , ",
+	}, "\n")
+
+	rows, err := ParseEventsCSV(strings.NewReader(file), map[string]string{"Name": "title", "Location": "Venue"}, 7)
+	if err != nil {
+		t.Fatalf("ParseEventsCSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("ParseEventsCSV returned %d rows, want 3", len(rows))
+	}
+
+	valid := rows[0]
+	wantStart := time.Date(2030, 1, 1, 17, 0, 0, 0, time.UTC)
+	if valid.Line != 2 || len(valid.Errors) != 0 {
+		t.Errorf("The first row = line %d with the errors %+v, want line 2 without errors", valid.Line, valid.Errors)
+	}
+	if valid.Event.Name != "Go Meetup" || valid.Event.Location != "Berlin" || valid.Event.Description != "Talks, food" ||
+		!valid.Event.DateTime.Equal(wantStart) || valid.Event.Capacity == nil || *valid.Event.Capacity != 20 ||
+		valid.Event.UserID != 7 || valid.Event.Status != "" {
+		t.Errorf("The first row holds the event %+v, want the mapped and named columns", valid.Event)
+	}
+
+	invalid := rows[1]
+	want := map[string]string{"Name": "required", "DateTime": "format", "Capacity": "format"}
+	if invalid.Line != 3 || fmt.Sprint(rowErrors(invalid)) != fmt.Sprint(want) {
+		t.Errorf("The second row = line %d with the errors %+v, want line 3 with %v", invalid.Line, invalid.Errors, want)
+	}
+	for _, fieldError := range invalid.Errors {
+		if fieldError.Row != 3 {
+			t.Errorf("The error %+v names row %d, want 3", fieldError, fieldError.Row)
+		}
+	}
+
+	past := rows[2]
+	if fmt.Sprint(rowErrors(past)) != fmt.Sprint(map[string]string{"DateTime": "future"}) || past.Event.Capacity != nil {
+		t.Errorf("The third row = %+v, want no capacity and only the past start to be reported", past)
+	}
+}
+
+func TestParseEventsCSVMapping(t *testing.T) {
+	header := "Name,Description,Location,DateTime\n"
+	tests := []struct {
+		name    string
+		columns map[string]string
+	}{
+		{"unknown field", map[string]string{"Organizer": "Name"}},
+		{"missing column", map[string]string{"Location": "Venue"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseEventsCSV(strings.NewReader(header), test.columns, 7)
+			if !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("ParseEventsCSV: got %v, want ErrInvalidImport", err)
+			}
+		})
+	}
+
+	_, err := ParseEventsCSV(strings.NewReader(""), nil, 7)
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("ParseEventsCSV of an empty file: got %v, want ErrInvalidImport", err)
+	}
+
+	// Columns without a mapping are read from the header named like the field, others are left unset.
+	rows, err := ParseEventsCSV(strings.NewReader("NAME,Extra\nGo Meetup,x\n"), map[string]string{}, 7)
+	if err != nil || len(rows) != 1 || rows[0].Event.Name != "Go Meetup" || rows[0].Event.Location != "" {
+		t.Errorf("ParseEventsCSV with only a name = %+v, %v, want the name to be read", rows, err)
+	}
+}
+
+func TestParseEventsCSVRowLimit(t *testing.T) {
+	InitValidation()
+	file := "Name,Description,Location,DateTime\n" +
+		strings.Repeat("Go Meetup,Talks,Berlin,2030-01-01T18:00:00Z\n", MaxImportRows)
+
+	rows, err := ParseEventsCSV(strings.NewReader(file), nil, 7)
+	if err != nil || len(rows) != MaxImportRows {
+		t.Fatalf("ParseEventsCSV of %d events = %d rows, %v, want all of them", MaxImportRows, len(rows), err)
+	}
+
+	file += "Go Meetup,Talks,Berlin,2030-01-01T18:00:00Z\n"
+	_, err = ParseEventsCSV(strings.NewReader(file), nil, 7)
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("ParseEventsCSV of %d events: got %v, want ErrInvalidImport", MaxImportRows+1, err)
+	}
+}
+
+func TestParseEventsICS(t *testing.T) {
+	InitValidation()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	file := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Go Meetup",
+		"DESCRIPTION:Talks\\, food",
+		"LOCATION:Berlin",
+		"DTSTART;TZID=Europe/Berlin:20300107T180000",
+		"DTEND;TZID=Europe/Berlin:20300107T200000",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"EXDATE;TZID=Europe/Berlin:20300114T180000,",
+		" 20300121T180000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Rust Meetup",
+		"LOCATION:Hamburg",
+		"DTSTART;TZID=Mars/Olympus:20300107T180000",
+		"DTEND:tomorrow",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	rows, err := ParseEventsICS(strings.NewReader(file), 7)
+	if err != nil {
+		t.Fatalf("ParseEventsICS: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("ParseEventsICS returned %d rows, want 2", len(rows))
+	}
+
+	event := rows[0].Event
+	if rows[0].Line != 2 || len(rows[0].Errors) != 0 {
+		t.Errorf("The first event = line %d with the errors %+v, want line 2 without errors", rows[0].Line, rows[0].Errors)
+	}
+	if event.Name != "Go Meetup" || event.Description != "Talks, food" || event.Location != "Berlin" || event.UserID != 7 ||
+		event.TimeZone != "Europe/Berlin" || !event.DateTime.Equal(time.Date(2030, 1, 7, 18, 0, 0, 0, berlin)) ||
+		event.EndDateTime == nil || !event.EndDateTime.Equal(time.Date(2030, 1, 7, 20, 0, 0, 0, berlin)) ||
+		event.RecurrenceRule != "FREQ=WEEKLY;COUNT=4" || len(event.ExceptionDates) != 2 {
+		t.Errorf("The first event = %+v, want the properties of the VEVENT and not of its VALARM", event)
+	}
+
+	want := map[string]string{"Description": "required", "DateTime": "format", "EndDateTime": "format", "TimeZone": "timezone"}
+	if rows[1].Line != 16 || fmt.Sprint(rowErrors(rows[1])) != fmt.Sprint(want) {
+		t.Errorf("The second event = line %d with the errors %+v, want line 16 with %v", rows[1].Line, rows[1].Errors, want)
+	}
+
+	_, err = ParseEventsICS(strings.NewReader("Name,Location\n"), 7)
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("ParseEventsICS of a CSV file: got %v, want ErrInvalidImport", err)
+	}
+	tooMany := "BEGIN:VCALENDAR\n" + strings.Repeat("BEGIN:VEVENT\nSUMMARY:Go Meetup\nEND:VEVENT\n", MaxImportRows+1) + "END:VCALENDAR\n"
+	_, err = ParseEventsICS(strings.NewReader(tooMany), 7)
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("ParseEventsICS of %d events: got %v, want ErrInvalidImport", MaxImportRows+1, err)
+	}
+}
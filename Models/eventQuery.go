@@ -0,0 +1,334 @@
+package models
+
+import (
+	"RestAPI/db"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultEventsLimit and MaxEventsLimit bound the page size of ListEvents.
+const (
+	DefaultEventsLimit = 50
+	MaxEventsLimit     = 200
+)
+
+// MaxExpandWindow is the longest window whose occurrences ListEvents expands.
+const MaxExpandWindow = 366 * 24 * time.Hour
+
+// ErrInvalidCursor is returned by ListEvents if the cursor is malformed or was created for another sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidWindow is returned by ListEvents if occurrences are expanded without a from and to at most
+// MaxExpandWindow apart, or sorted by something other than dateTime.
+var ErrInvalidWindow = errors.New("expanding occurrences requires from and to at most 366 days apart and sorting by dateTime")
+
+// EventFilter holds the filters, sort order and page of ListEvents. It is bound from the query string of GET /events.
+//   - From / To: only events whose dateTime lies within the range (both inclusive, RFC 3339).
+//   - Location: only events at this location, compared case-insensitively.
+//   - UserID: only events owned by this user.
+//   - Upcoming: only events that have not started yet.
+//   - Sort: "dateTime", "name" or "created", prefixed with "-" for descending order. Defaults to "dateTime".
+//   - Cursor: the NextCursor of the previous page. It is only valid together with the same sort order.
+//   - Limit: the page size, DefaultEventsLimit if unset and at most MaxEventsLimit.
+//   - Expand: list every occurrence of recurring events within From and To as an event of its own, see Event.Occurrence.
+//     Without it, a recurring event is listed once, by the dateTime of its first occurrence.
+//   - TimeZone: render the datetimes of the events in this IANA time zone instead of their own, see Event.InZone.
+//   - Status: only events in this lifecycle state, see EventDraft.
+//   - ViewerID: the user listing the events, 0 if anonymous. Drafts are only listed for their owner. It is not
+//     bound from the query string but set from the authenticated user.
+type EventFilter struct {
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Location string    `form:"location"`
+	UserID   int64     `form:"userId"`
+	Upcoming bool      `form:"upcoming"`
+	Sort     string    `form:"sort" binding:"omitempty,oneof=dateTime -dateTime name -name created -created"`
+	Cursor   string    `form:"cursor"`
+	Limit    int       `form:"limit" binding:"omitempty,min=1,max=200"`
+	Expand   bool      `form:"expand"`
+	TimeZone string    `form:"tz" binding:"omitempty,timezone"`
+	Status   string    `form:"status" binding:"omitempty,oneof=draft published cancelled completed"`
+	ViewerID int64     `form:"-"`
+}
+
+// EventPage is one page of events returned by ListEvents.
+// NextCursor is empty on the last page.
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// eventCursor is the decoded form of a page cursor. It stores the sort order it was created for and the
+// sort value and ID of the last event of the page, so the next page continues right after that event
+// even if events were inserted or deleted in the meantime.
+type eventCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// sortColumns maps the sort parameter (without direction) to the column it sorts by.
+// "created" sorts by ID, which grows with every inserted event.
+var sortColumns = map[string]string{
+	"dateTime": "dateTime",
+	"name":     "name",
+	"created":  "id",
+}
+
+// ListEvents returns a page of events matching the filter from the configured EventStore.
+// It returns ErrInvalidCursor if the cursor is malformed or belongs to a different sort order,
+// and ErrInvalidWindow if occurrences are expanded without a valid window.
+func ListEvents(filter EventFilter) (*EventPage, error) {
+	return Events.List(filter)
+}
+
+// List returns a page of events matching the filter, using keyset pagination:
+// events are ordered by the sort column and then by ID, and the cursor holds the position of the last
+// event of the previous page, so a page is fetched with an indexed range scan no matter how deep it is.
+func (sqlEventStore) List(filter EventFilter) (*EventPage, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = "dateTime"
+	}
+	descending := strings.HasPrefix(sort, "-")
+	column, ok := sortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, errors.New("invalid sort")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEventsLimit
+	}
+	limit = min(limit, MaxEventsLimit)
+
+	if filter.Expand {
+		return listOccurrences(filter, sort, limit)
+	}
+
+	visible, args := visibleTo(filter.ViewerID)
+	conditions := []string{visible}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "dateTime >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "dateTime <= ?")
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Upcoming {
+		conditions = append(conditions, "dateTime >= ?")
+		args = append(args, time.Now().UTC())
+	}
+	if filter.Location != "" {
+		conditions = append(conditions, "lower(location) = lower(?)")
+		args = append(args, filter.Location)
+	}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeEventCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sort {
+			return nil, ErrInvalidCursor
+		}
+
+		if column == "id" {
+			conditions = append(conditions, "id "+comparison+" ?")
+			args = append(args, cursor.ID)
+		} else {
+			var value any = cursor.Value
+			if column == "dateTime" {
+				value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+				if err != nil {
+					return nil, ErrInvalidCursor
+				}
+			}
+			conditions = append(conditions, "("+column+" "+comparison+" ? OR ("+column+" = ? AND id "+comparison+" ?))")
+			args = append(args, value, value, cursor.ID)
+		}
+	}
+
+	query := "SELECT " + eventColumns + " FROM events WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY " + column + " " + direction
+	if column != "id" {
+		query += ", id " + direction
+	}
+	query += " LIMIT ?"
+	// One more event than requested is fetched to find out whether there is a next page.
+	args = append(args, limit+1)
+
+	rows, err := db.DB.Query(db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := EventPage{Events: []Event{}}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Events = append(page.Events, event.InZone(filter.TimeZone))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		last := page.Events[limit-1]
+		cursor := eventCursor{Sort: sort, ID: last.ID}
+		switch column {
+		case "dateTime":
+			cursor.Value = last.DateTime.UTC().Format(time.RFC3339Nano)
+		case "name":
+			cursor.Value = last.Name
+		}
+		page.NextCursor, err = encodeEventCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+// listOccurrences returns a page of the occurrences of the events matching the filter within its window.
+// Recurring events are expanded in Go, so they are selected if they start before the end of the window and
+// their occurrences are then sorted by start and ID and paginated like List does with the database.
+func listOccurrences(filter EventFilter, sort string, limit int) (*EventPage, error) {
+	from, to := filter.From.UTC(), filter.To.UTC()
+	if from.IsZero() || to.IsZero() || to.Before(from) || to.Sub(from) > MaxExpandWindow || strings.TrimPrefix(sort, "-") != "dateTime" {
+		return nil, ErrInvalidWindow
+	}
+	if now := time.Now().UTC(); filter.Upcoming && now.After(from) {
+		from = now
+	}
+
+	visible, args := visibleTo(filter.ViewerID)
+	conditions := []string{visible, "((recurrenceRule IS NULL AND dateTime >= ? AND dateTime <= ?) OR (recurrenceRule IS NOT NULL AND dateTime <= ?))"}
+	args = append(args, from, to, to)
+	if filter.Location != "" {
+		conditions = append(conditions, "lower(location) = lower(?)")
+		args = append(args, filter.Location)
+	}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := "SELECT " + eventColumns + " FROM events WHERE " + strings.Join(conditions, " AND ")
+	rows, err := db.DB.Query(db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var occurrences []Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		starts, err := event.Occurrences(from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, start := range starts {
+			occurrences = append(occurrences, event.Occurrence(start))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// compare orders occurrences like the ORDER BY of List: by their start, then by the ID of their event.
+	descending := strings.HasPrefix(sort, "-")
+	compare := func(a, b Event) int {
+		order := cmp.Or(a.DateTime.Compare(b.DateTime), cmp.Compare(a.ID, b.ID))
+		if descending {
+			return -order
+		}
+		return order
+	}
+	slices.SortFunc(occurrences, compare)
+
+	if filter.Cursor != "" {
+		cursor, err := decodeEventCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sort {
+			return nil, ErrInvalidCursor
+		}
+		position, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+
+		last := Event{ID: cursor.ID, DateTime: position}
+		next, _ := slices.BinarySearchFunc(occurrences, last, compare)
+		for next < len(occurrences) && compare(occurrences[next], last) == 0 {
+			next++
+		}
+		occurrences = occurrences[next:]
+	}
+
+	page := EventPage{Events: []Event{}}
+	for _, occurrence := range occurrences[:min(limit, len(occurrences))] {
+		page.Events = append(page.Events, occurrence.InZone(filter.TimeZone))
+	}
+	if len(occurrences) > limit {
+		last := page.Events[limit-1]
+		cursor := eventCursor{Sort: sort, Value: last.DateTime.UTC().Format(time.RFC3339Nano), ID: last.ID}
+		page.NextCursor, err = encodeEventCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+// encodeEventCursor serializes a cursor into an opaque, URL-safe string.
+func encodeEventCursor(cursor eventCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeEventCursor parses a cursor created by encodeEventCursor.
+func decodeEventCursor(value string) (*eventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor eventCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// DefaultSearchLimit and MaxSearchLimit bound the number of results of SearchEvents.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// ErrSearchUnavailable is returned by SearchEvents if the full-text index is not available (see db.FullTextSearch).
+var ErrSearchUnavailable = errors.New("full-text search is not available")
+
+// ErrEmptySearch is returned by SearchEvents if the query contains no searchable terms.
+var ErrEmptySearch = errors.New("empty search query")
+
+// SearchResult is an event matching a search together with its rank and highlighted snippets.
+// Results are ordered by Rank, lower is better. The highlights mark matched terms
+// with <mark> and </mark>; the description is shortened to a snippet around the matches.
+type SearchResult struct {
+	Event      Event             `json:"event"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// searchTerm is a single term or phrase of a search query. A phrase holds several words that must
+// appear in sequence; a prefix term matches every word starting with it.
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// SearchEvents searches the name, description and location of events in the configured EventStore
+// and returns the best matches first.
+// The query consists of terms and "quoted phrases", all of which must match. A term ending in * matches
+// every word starting with it, e.g. conf* matches conference. Matches in the name weigh most,
+// followed by the location and the description.
+// Drafts are only found for their owner, the user with the ID viewerId (0 for anonymous searches).
+// It returns ErrSearchUnavailable if full-text search is not available and ErrEmptySearch if there is nothing to search for.
+func SearchEvents(query string, limit int, viewerId int64) ([]SearchResult, error) {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	return Events.Search(query, min(limit, MaxSearchLimit), viewerId)
+}
+
+// parseSearchQuery splits a user query into terms and "quoted phrases".
+// Words are split at every character that is neither a letter nor a digit, so the terms never contain
+// operators or other syntax of the database's query language. A term like e-mail becomes the phrase "e mail".
+// A trailing * on a term that is not part of a quoted phrase makes it a prefix term.
+func parseSearchQuery(query string) []searchTerm {
+	var terms []searchTerm
+	rest := strings.TrimSpace(query)
+
+	for rest != "" {
+		var text string
+		quoted := rest[0] == '"'
+		if quoted {
+			end := strings.IndexByte(rest[1:], '"')
+			if end >= 0 {
+				text, rest = rest[1:end+1], rest[end+2:]
+			} else {
+				text, rest = rest[1:], ""
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end >= 0 {
+				text, rest = rest[:end], rest[end:]
+			} else {
+				text, rest = rest, ""
+			}
+		}
+
+		words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if len(words) > 0 {
+			terms = append(terms, searchTerm{words: words, prefix: !quoted && strings.HasSuffix(text, "*")})
+		}
+		rest = strings.TrimSpace(rest)
+	}
+
+	return terms
+}
+
+// prefixColumns qualifies every column of a comma separated column list with the given table prefix.
+func prefixColumns(prefix, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return strings.Join(names, ", ")
+}
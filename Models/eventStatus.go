@@ -0,0 +1,102 @@
+package models
+
+import (
+	"RestAPI/db"
+	"errors"
+	"slices"
+)
+
+// The lifecycle states of an event. A draft is only visible to its owner and is published to open it for
+// registration. A published event is completed once it took place. Any event can be cancelled, which keeps
+// it and its registrations but notifies the registered users.
+const (
+	EventDraft     = "draft"
+	EventPublished = "published"
+	EventCancelled = "cancelled"
+	EventCompleted = "completed"
+)
+
+// The errors of the event lifecycle.
+var (
+	// ErrInvalidTransition is returned by SetEventStatus if the event can not change from its status to the requested one.
+	ErrInvalidTransition = errors.New("the event can not change to the requested status")
+	// ErrEventNotOpen is returned when registering for an event that is not published.
+	ErrEventNotOpen = errors.New("registration is only open for published events")
+	// ErrEventClosed is returned when updating a cancelled or completed event.
+	ErrEventClosed = errors.New("cancelled and completed events can not be changed")
+	// ErrEventHasRegistrations is returned when deleting a published event that users are registered for.
+	ErrEventHasRegistrations = errors.New("a published event with registrations must be cancelled before it is deleted")
+)
+
+// statusTransitions lists the statuses every status can change to.
+var statusTransitions = map[string][]string{
+	EventDraft:     {EventPublished, EventCancelled},
+	EventPublished: {EventCompleted, EventCancelled},
+	EventCompleted: {EventCancelled},
+}
+
+// CanTransition reports whether an event can change from the status from to the status to.
+func CanTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// IsClosed reports whether the event was cancelled or completed, after which it can not be updated.
+func (event Event) IsClosed() bool {
+	return event.Status == EventCancelled || event.Status == EventCompleted
+}
+
+// IsVisibleTo reports whether the user with the given ID may see the event: drafts are only visible to their
+// owner. A viewerId of 0 is an anonymous viewer.
+func (event Event) IsVisibleTo(viewerId int64) bool {
+	return event.Status != EventDraft || (viewerId != 0 && event.UserID == viewerId)
+}
+
+// visibleTo returns the SQL condition and its arguments that restrict events to those visible to the viewer,
+// see Event.IsVisibleTo. Events in the trash are visible to nobody.
+func visibleTo(viewerId int64) (string, []any) {
+	return "deletedAt IS NULL AND (status <> ? OR user_id = ?)", []any{EventDraft, viewerId}
+}
+
+// SetEventStatus changes the status of the event with the given ID and returns the changed event.
+// Cancelling an event notifies every user registered for it, with the reason if one is given.
+// It returns ErrInvalidTransition if the event can not change to the status, see CanTransition.
+func SetEventStatus(id int64, status, reason string) (*Event, error) {
+	return Events.SetStatus(id, status, reason)
+}
+
+// SetStatus locks the event, changes its status and notifies its registrants in one transaction.
+func (sqlEventStore) SetStatus(id int64, status, reason string) (*Event, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	event, err := selectEventForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(event.Status, status) {
+		return nil, ErrInvalidTransition
+	}
+
+	_, err = tx.Exec(db.Rebind("UPDATE events SET status = ?, version = version + 1 WHERE id = ?"), status, id)
+	if err != nil {
+		return nil, err
+	}
+	event.Status = status
+	event.Version++
+
+	if status == EventCancelled {
+		message := "The event " + event.Name + " on " + event.DateTime.Format("Mon, 02 Jan 2006 15:04 MST") + " was cancelled."
+		if reason != "" {
+			message += " " + reason
+		}
+		err = notifyRegistrantsInTx(tx, event.ID, NotificationEventCancelled, message)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &event, tx.Commit()
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// GetTrash returns the events of the user with the given ID that are in the trash, most recently deleted first.
+func GetTrash(userId int64) ([]Event, error) {
+	return Events.ListDeleted(userId)
+}
+
+// GetDeletedEventByID returns the event with the given ID if it is in the trash.
+// It returns sql.ErrNoRows if no event has that ID or the event is not in the trash.
+func GetDeletedEventByID(id int64) (*Event, error) {
+	return Events.GetDeletedByID(id)
+}
+
+// Restore takes the event out of the trash of the configured EventStore, together with its registrations.
+// It returns sql.ErrNoRows if the event is not in the trash.
+func (event Event) Restore() error {
+	return Events.Restore(event.ID)
+}
+
+// PurgeTrash permanently removes the events that were moved to the trash before the given time, together with
+// their registrations and notifications, and returns the number of removed events. Their audit log entries stay.
+func PurgeTrash(before time.Time) (int64, error) {
+	return Events.Purge(before)
+}
+
+// StartTrashPurge purges the events that have been in the trash for longer than the retention period, once
+// right away and then every interval, until the program exits. Errors are logged and retried on the next run.
+func StartTrashPurge(retention, interval time.Duration) {
+	go func() {
+		for {
+			purged, err := PurgeTrash(time.Now().UTC().Add(-retention))
+			if err != nil {
+				log.Printf("Could not purge the trash: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d events from the trash", purged)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ListDeleted selects the events of the user that have a deletedAt.
+func (sqlEventStore) ListDeleted(userId int64) ([]Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE user_id = ? AND deletedAt IS NOT NULL ORDER BY deletedAt DESC, id DESC"
+	rows, err := db.DB.Query(db.Rebind(query), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetDeletedByID selects the event with the given ID if it has a deletedAt.
+func (sqlEventStore) GetDeletedByID(id int64) (*Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ? AND deletedAt IS NOT NULL"
+	row := db.DB.QueryRow(db.Rebind(query), id)
+
+	event, err := scanEvent(row)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Restore clears the deletedAt of the event. Its registrations were kept while it was in the trash.
+func (sqlEventStore) Restore(id int64) error {
+	result, err := db.DB.Exec(db.Rebind("UPDATE events SET deletedAt = NULL, version = version + 1 WHERE id = ? AND deletedAt IS NOT NULL"), id)
+	if err != nil {
+		return err
+	}
+
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Purge deletes the registrations and notifications of the events deleted before the given time and then the
+// events themselves in one transaction, so no registration or notification is left pointing to a removed event.
+// The audit log entries of the events are kept on purpose: the log is an append-only history of changes, which
+// must still tell who changed and deleted an event after it is gone.
+func (sqlEventStore) Purge(before time.Time) (int64, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"registrations", "notifications"} {
+		query := "DELETE FROM " + table + " WHERE eventId IN (SELECT id FROM events WHERE deletedAt < ?)"
+		_, err = tx.Exec(db.Rebind(query), before)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(db.Rebind("DELETE FROM events WHERE deletedAt < ?"), before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
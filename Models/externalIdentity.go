@@ -0,0 +1,155 @@
+package models
+
+import (
+	"RestAPI/config"
+	"RestAPI/db"
+	"RestAPI/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// How SignInWithIdentity found the user of an external identity.
+const (
+	// IdentityKnown is an identity that was linked to the user by an earlier login.
+	IdentityKnown = "known"
+	// IdentityLinked is an identity that was linked to the existing user with its email by this login.
+	IdentityLinked = "linked"
+	// IdentityCreated is an identity whose user was created by this login.
+	IdentityCreated = "created"
+)
+
+// Errors of logins with an identity provider.
+var (
+	// ErrInvalidOIDCLogin is returned by ConsumeOIDCLogin if the state is unknown, expired or was already used.
+	ErrInvalidOIDCLogin = errors.New("the login is unknown or expired")
+	// ErrIdentityEmailNotVerified is returned by SignInWithIdentity for a new identity whose email the identity
+	// provider has not verified, as it can neither be linked to a user by it nor create one.
+	ErrIdentityEmailNotVerified = errors.New("the identity provider has not verified the email")
+	// ErrNoAccount is returned by SignInWithIdentity for a new identity with an email no user has, if
+	// OIDCCreateUsers is off.
+	ErrNoAccount = errors.New("no user has the email")
+)
+
+// OIDCLoginTTL returns how long a user has to log in at the identity provider, configured by OIDC_LOGIN_TTL.
+func OIDCLoginTTL() time.Duration {
+	return config.Duration("OIDC_LOGIN_TTL", 10*time.Minute)
+}
+
+// OIDCCreateUsers reports whether the first login with an identity whose email no user has creates a user,
+// configured by OIDC_CREATE_USERS.
+func OIDCCreateUsers() bool {
+	return config.Bool("OIDC_CREATE_USERS", true)
+}
+
+// StartOIDCLogin stores a new login with the identity provider until it redirects back, see ConsumeOIDCLogin,
+// and returns its random state and nonce; the code verifier is chosen by the caller. Logins that expired
+// are removed from the database along the way.
+func StartOIDCLogin(codeVerifier string) (string, string, error) {
+	state, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = db.DB.Exec(db.Rebind("DELETE FROM oidc_logins WHERE expiresAt < ?"), now)
+	if err != nil {
+		return "", "", err
+	}
+	query := "INSERT INTO oidc_logins(stateHash, nonce, codeVerifier, expiresAt) VALUES (?, ?, ?, ?)"
+	_, err = db.DB.Exec(db.Rebind(query), utils.HashToken(state), nonce, codeVerifier, now.Add(OIDCLoginTTL()))
+	if err != nil {
+		return "", "", err
+	}
+	return state, nonce, nil
+}
+
+// ConsumeOIDCLogin removes the login with the state started by StartOIDCLogin and returns its nonce and code
+// verifier, so every login can only be completed once.
+// It returns ErrInvalidOIDCLogin if there is no such login or it expired.
+func ConsumeOIDCLogin(state string) (string, string, error) {
+	var nonce, codeVerifier string
+	query := "DELETE FROM oidc_logins WHERE stateHash = ? AND expiresAt > ? RETURNING nonce, codeVerifier"
+	err := db.DB.QueryRow(db.Rebind(query), utils.HashToken(state), time.Now().UTC()).Scan(&nonce, &codeVerifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrInvalidOIDCLogin
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return nonce, codeVerifier, nil
+}
+
+// SignInWithIdentity returns the user of the identity the identity provider signed in, identified by issuer and
+// subject, and how it was found:
+//   - IdentityKnown if an earlier login linked the identity to a user. The email of the user is left alone.
+//   - IdentityLinked if the identity is new and a user has its email, which the provider has verified. The
+//     identity is linked to that user, whose email counts as verified from now on.
+//   - IdentityCreated if the identity is new and no user has its email. A user is created like by User.Save,
+//     with the verified email and without a password, and the identity is linked to it.
+//
+// It returns ErrIdentityEmailNotVerified if the identity is new and the provider has not verified its email,
+// and ErrNoAccount if no user has the email and OIDCCreateUsers is off.
+func SignInWithIdentity(issuer, subject, email string, emailVerified bool) (*User, string, error) {
+	now := time.Now().UTC()
+
+	var userId int64
+	query := "UPDATE external_identities SET email = ?, lastLoginAt = ? WHERE issuer = ? AND subject = ? RETURNING userId"
+	err := db.DB.QueryRow(db.Rebind(query), email, now, issuer, subject).Scan(&userId)
+	if err == nil {
+		user, err := GetUserByID(userId)
+		return user, IdentityKnown, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, "", err
+	}
+
+	if !emailVerified {
+		return nil, "", ErrIdentityEmailNotVerified
+	}
+
+	outcome := IdentityLinked
+	err = db.DB.QueryRow(db.Rebind("SELECT id FROM users WHERE LOWER(email) = LOWER(?)"), email).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		if !OIDCCreateUsers() {
+			return nil, "", ErrNoAccount
+		}
+		userId, err = Users.Create(email, "", newUserRoles(email))
+		if db.IsConstraintViolation(err) {
+			return nil, "", ErrEmailTaken
+		}
+		outcome = IdentityCreated
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	query = `
+	INSERT INTO external_identities(issuer, subject, userId, email, createdAt, lastLoginAt)
+	VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT(issuer, subject) DO NOTHING`
+	_, err = tx.Exec(db.Rebind(query), issuer, subject, userId, email, now, now)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tx.Exec(db.Rebind("UPDATE users SET emailVerifiedAt = COALESCE(emailVerifiedAt, ?) WHERE id = ?"), now, userId)
+	if err != nil {
+		return nil, "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := GetUserByID(userId)
+	return user, outcome, err
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://idp.example.com"
+
+func TestOIDCLogin(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		state, nonce, err := StartOIDCLogin("verifier")
+		if err != nil {
+			t.Fatalf("StartOIDCLogin: %v", err)
+		}
+		gotNonce, verifier, err := ConsumeOIDCLogin(state)
+		if err != nil || gotNonce != nonce || verifier != "verifier" {
+			t.Errorf("ConsumeOIDCLogin = %q, %q, %v, want %q, %q", gotNonce, verifier, err, nonce, "verifier")
+		}
+		_, _, err = ConsumeOIDCLogin(state)
+		if !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("ConsumeOIDCLogin of a completed login: got %v, want ErrInvalidOIDCLogin", err)
+		}
+		_, _, err = ConsumeOIDCLogin("unknown")
+		if !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("ConsumeOIDCLogin of an unknown state: got %v, want ErrInvalidOIDCLogin", err)
+		}
+
+		state, _, err = StartOIDCLogin("verifier")
+		if err != nil {
+			t.Fatalf("StartOIDCLogin: %v", err)
+		}
+		_, err = db.DB.Exec(db.Rebind("UPDATE oidc_logins SET expiresAt = ? WHERE stateHash = ?"), time.Now().UTC().Add(-time.Second), utils.HashToken(state))
+		if err != nil {
+			t.Fatalf("Could not backdate the login: %v", err)
+		}
+		_, _, err = ConsumeOIDCLogin(state)
+		if !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("ConsumeOIDCLogin of an expired login: got %v, want ErrInvalidOIDCLogin", err)
+		}
+	})
+}
+
+// emailVerified reports whether the email of the user counts as verified.
+func emailVerified(t *testing.T, userId int64) bool {
+	t.Helper()
+	var verifiedAt *time.Time
+	err := db.DB.QueryRow(db.Rebind("SELECT emailVerifiedAt FROM users WHERE id = ?"), userId).Scan(&verifiedAt)
+	if err != nil {
+		t.Fatalf("Could not read the email verification: %v", err)
+	}
+	return verifiedAt != nil
+}
+
+func TestSignInWithIdentity(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		user, outcome, err := SignInWithIdentity(testIssuer, "ada", "ada@example.com", true)
+		if err != nil || outcome != IdentityCreated || user.Email != "ada@example.com" {
+			t.Fatalf("SignInWithIdentity of a new email = %+v, %q, %v, want a created user", user, outcome, err)
+		}
+		if !emailVerified(t, user.ID) {
+			t.Error("The created user has no verified email")
+		}
+
+		// A known identity keeps its user, even once its email changed at the provider.
+		known, outcome, err := SignInWithIdentity(testIssuer, "ada", "ada@example.org", false)
+		if err != nil || outcome != IdentityKnown || known.ID != user.ID || known.Email != "ada@example.com" {
+			t.Errorf("SignInWithIdentity of a known identity = %+v, %q, %v, want the user %d unchanged", known, outcome, err, user.ID)
+		}
+
+		// The same subject at another issuer is another identity.
+		_, outcome, err = SignInWithIdentity("https://other.example.com", "ada", "Ada@Example.com", true)
+		if err != nil || outcome != IdentityLinked {
+			t.Errorf("SignInWithIdentity at another issuer = %q, %v, want the identity linked", outcome, err)
+		}
+
+		userId := createTestUser(t, "grace@example.com")
+		_, _, err = SignInWithIdentity(testIssuer, "grace", "grace@example.com", false)
+		if !errors.Is(err, ErrIdentityEmailNotVerified) {
+			t.Errorf("SignInWithIdentity with an unverified email: got %v, want ErrIdentityEmailNotVerified", err)
+		}
+		linked, outcome, err := SignInWithIdentity(testIssuer, "grace", "GRACE@example.com", true)
+		if err != nil || outcome != IdentityLinked || linked.ID != userId {
+			t.Errorf("SignInWithIdentity with the email of a user in another case = %+v, %q, %v, want linked to the user %d", linked, outcome, err, userId)
+		}
+		if !emailVerified(t, userId) {
+			t.Error("The linked user has no verified email")
+		}
+
+		t.Setenv("OIDC_CREATE_USERS", "false")
+		_, _, err = SignInWithIdentity(testIssuer, "linus", "linus@example.com", true)
+		if !errors.Is(err, ErrNoAccount) {
+			t.Errorf("SignInWithIdentity of a new email without creating users: got %v, want ErrNoAccount", err)
+		}
+		_, outcome, err = SignInWithIdentity(testIssuer, "grace", "grace@example.com", true)
+		if err != nil || outcome != IdentityKnown {
+			t.Errorf("SignInWithIdentity of a known identity without creating users = %q, %v, want it known", outcome, err)
+		}
+	})
+}
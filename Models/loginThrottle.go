@@ -0,0 +1,183 @@
+package models
+
+import (
+	"RestAPI/config"
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// The scopes of a LoginThrottledError.
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+// LoginThrottledError is returned by CheckLoginThrottle if logins are locked, for the account when its
+// Scope is ThrottleAccount and for the client IP when it is ThrottleIP. RetryAfter is the time until the lock
+// ends.
+type LoginThrottledError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+// Error describes the lock.
+func (e *LoginThrottledError) Error() string {
+	return "too many failed logins for this " + e.Scope
+}
+
+// loginThrottle holds the limits of one scope: the number of failures that lock it, configured by maxSetting.
+type loginThrottle struct {
+	scope      string
+	maxSetting string
+	maxDefault int
+}
+
+var (
+	accountThrottle = loginThrottle{scope: ThrottleAccount, maxSetting: "LOGIN_MAX_ATTEMPTS", maxDefault: 5}
+	ipThrottle      = loginThrottle{scope: ThrottleIP, maxSetting: "LOGIN_IP_MAX_ATTEMPTS", maxDefault: 20}
+)
+
+// LoginLockout returns how long the first lock lasts, configured by LOGIN_LOCKOUT. Every further failure after
+// a lock doubles it, up to LoginLockoutMax.
+func LoginLockout() time.Duration {
+	return config.Duration("LOGIN_LOCKOUT", time.Minute)
+}
+
+// LoginLockoutMax returns the longest lock, configured by LOGIN_LOCKOUT_MAX.
+func LoginLockoutMax() time.Duration {
+	return config.Duration("LOGIN_LOCKOUT_MAX", time.Hour)
+}
+
+// LoginAttemptWindow returns how long failures are remembered after the last one, or after a lock ended,
+// configured by LOGIN_ATTEMPT_WINDOW.
+func LoginAttemptWindow() time.Duration {
+	return config.Duration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+}
+
+// CheckLoginThrottle returns a *LoginThrottledError if logins to the account with the email, or from the client
+// IP, are locked after too many failures. It is checked before the password, so locked logins cost no hashing.
+// An IP is checked first, as it is locked for every account it tries.
+func CheckLoginThrottle(email, ip string) error {
+	now := time.Now().UTC()
+	for _, check := range []struct {
+		throttle loginThrottle
+		key      string
+	}{{ipThrottle, ipThrottleKey(ip)}, {accountThrottle, accountThrottleKey(email)}} {
+		var lockedUntil *time.Time
+		query := "SELECT lockedUntil FROM login_throttles WHERE throttleKey = ?"
+		err := db.DB.QueryRow(db.Rebind(query), check.key).Scan(&lockedUntil)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if lockedUntil != nil && lockedUntil.After(now) {
+			return &LoginThrottledError{Scope: check.throttle.scope, RetryAfter: lockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login to the account with the email from the client IP, and locks either
+// once it failed too often: the account after LOGIN_MAX_ATTEMPTS and the IP after LOGIN_IP_MAX_ATTEMPTS failures
+// within LoginAttemptWindow. Emails without an account are counted too, so a lock does not reveal whether an
+// account exists.
+func RecordLoginFailure(email, ip string) error {
+	err := recordThrottleFailure(ipThrottle, ipThrottleKey(ip))
+	if err != nil {
+		return err
+	}
+	return recordThrottleFailure(accountThrottle, accountThrottleKey(email))
+}
+
+// RecordLoginSuccess forgets the failures of the account with the email after a successful login. Failures of
+// the client IP are kept, so logging in to an own account does not allow to go on guessing others.
+// Forgotten failures are removed from the database along the way.
+func RecordLoginSuccess(email string) error {
+	now := time.Now().UTC()
+	_, err := db.DB.Exec(db.Rebind("DELETE FROM login_throttles WHERE throttleKey = ?"), accountThrottleKey(email))
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM login_throttles WHERE expiresAt < ? AND (lockedUntil IS NULL OR lockedUntil < ?)"
+	_, err = db.DB.Exec(db.Rebind(query), now, now)
+	return err
+}
+
+// UnlockAccount forgets the failed logins of the user with the given ID, which lifts a lock of the account.
+// It returns sql.ErrNoRows if the user does not exist.
+func UnlockAccount(userId int64) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = unlockAccountInTx(tx, userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unlockAccountInTx forgets the failed logins of the user with the given ID within the transaction.
+func unlockAccountInTx(tx *sql.Tx, userId int64) error {
+	var email string
+	err := tx.QueryRow(db.Rebind("SELECT email FROM users WHERE id = ?"), userId).Scan(&email)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(db.Rebind("DELETE FROM login_throttles WHERE throttleKey = ?"), accountThrottleKey(email))
+	return err
+}
+
+// recordThrottleFailure counts a failure under the key and locks it once the limit of the throttle is reached.
+// The lock lasts LoginLockout at the limit and doubles with every further failure, up to LoginLockoutMax.
+func recordThrottleFailure(throttle loginThrottle, key string) error {
+	now := time.Now().UTC()
+	window := LoginAttemptWindow()
+
+	// Counting in one statement keeps concurrent failures from being lost. The count starts over once the
+	// failures expired.
+	query := `
+	INSERT INTO login_throttles(throttleKey, failures, lockedUntil, expiresAt) VALUES (?, 1, NULL, ?)
+	ON CONFLICT(throttleKey) DO UPDATE SET
+		failures = CASE WHEN login_throttles.expiresAt < ? THEN 1 ELSE login_throttles.failures + 1 END,
+		lockedUntil = CASE WHEN login_throttles.expiresAt < ? THEN NULL ELSE login_throttles.lockedUntil END,
+		expiresAt = excluded.expiresAt
+	RETURNING failures`
+	var failures int
+	err := db.DB.QueryRow(db.Rebind(query), key, now.Add(window), now, now).Scan(&failures)
+	if err != nil {
+		return err
+	}
+
+	excess := failures - config.Int(throttle.maxSetting, throttle.maxDefault)
+	if excess < 0 {
+		return nil
+	}
+	lockout, maxLockout := LoginLockout(), LoginLockoutMax()
+	for i := 0; i < excess && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, maxLockout)
+
+	lockedUntil := now.Add(lockout)
+	query = "UPDATE login_throttles SET lockedUntil = ?, expiresAt = ? WHERE throttleKey = ?"
+	_, err = db.DB.Exec(db.Rebind(query), lockedUntil, lockedUntil.Add(window), key)
+	return err
+}
+
+// accountThrottleKey returns the key failed logins to the account with the email are counted under.
+func accountThrottleKey(email string) string {
+	return ThrottleAccount + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipThrottleKey returns the key failed logins from the client IP are counted under.
+func ipThrottleKey(ip string) string {
+	return ThrottleIP + ":" + ip
+}
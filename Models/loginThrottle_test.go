@@ -0,0 +1,158 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// setLoginLimits configures the login throttle for a test: the account locks after three failures for a minute,
+// doubling up to five minutes, and the IP after ipMaxAttempts failures.
+func setLoginLimits(t *testing.T, ipMaxAttempts string) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	t.Setenv("LOGIN_IP_MAX_ATTEMPTS", ipMaxAttempts)
+	t.Setenv("LOGIN_LOCKOUT", "1m")
+	t.Setenv("LOGIN_LOCKOUT_MAX", "5m")
+	t.Setenv("LOGIN_ATTEMPT_WINDOW", "15m")
+}
+
+// throttled returns the lock CheckLoginThrottle reports for the email and IP, or nil if logins are allowed.
+func throttled(t *testing.T, email, ip string) *LoginThrottledError {
+	t.Helper()
+	err := CheckLoginThrottle(email, ip)
+	var throttledErr *LoginThrottledError
+	if err != nil && !errors.As(err, &throttledErr) {
+		t.Fatalf("CheckLoginThrottle: %v", err)
+	}
+	return throttledErr
+}
+
+// recordFailures records n failed logins to the email from the IP.
+func recordFailures(t *testing.T, email, ip string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		err := RecordLoginFailure(email, ip)
+		if err != nil {
+			t.Fatalf("RecordLoginFailure: %v", err)
+		}
+	}
+}
+
+func TestLoginLockoutDoublesUpToMax(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		setLoginLimits(t, "1000")
+		// The lock after each failure: none before the third, then doubling from a minute up to five.
+		want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+		for i, lockout := range want {
+			recordFailures(t, "ada@example.com", "192.0.2.1", 1)
+			lock := throttled(t, "ada@example.com", "192.0.2.1")
+			switch {
+			case lockout == 0 && lock != nil:
+				t.Errorf("After %d failures: locked for %v, want no lock", i+1, lock.RetryAfter)
+			case lockout == 0:
+			case lock == nil:
+				t.Errorf("After %d failures: not locked, want a lock of %v", i+1, lockout)
+			case lock.Scope != ThrottleAccount || lock.RetryAfter > lockout || lock.RetryAfter < lockout-5*time.Second:
+				t.Errorf("After %d failures: locked the %s for %v, want the account for %v", i+1, lock.Scope, lock.RetryAfter, lockout)
+			}
+		}
+
+		if lock := throttled(t, "ADA@example.com ", "192.0.2.2"); lock == nil {
+			t.Error("The account is not locked for its email in another case, want it to be")
+		}
+		if lock := throttled(t, "grace@example.com", "192.0.2.1"); lock != nil {
+			t.Errorf("Another account is locked for %v, want only the failing one", lock.RetryAfter)
+		}
+	})
+}
+
+func TestLoginIPLockout(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		setLoginLimits(t, "4")
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+			if lock := throttled(t, email, "192.0.2.1"); lock != nil {
+				t.Fatalf("Locked the %s before the IP reached its limit", lock.Scope)
+			}
+			recordFailures(t, email, "192.0.2.1", 1)
+		}
+
+		lock := throttled(t, "e@example.com", "192.0.2.1")
+		if lock == nil || lock.Scope != ThrottleIP {
+			t.Errorf("CheckLoginThrottle of another account from the IP = %v, want the IP to be locked", lock)
+		}
+		if lock := throttled(t, "e@example.com", "192.0.2.2"); lock != nil {
+			t.Errorf("CheckLoginThrottle from another IP = %v, want no lock", lock)
+		}
+
+		err := RecordLoginSuccess("a@example.com")
+		if err != nil {
+			t.Fatalf("RecordLoginSuccess: %v", err)
+		}
+		if lock := throttled(t, "a@example.com", "192.0.2.1"); lock == nil || lock.Scope != ThrottleIP {
+			t.Errorf("CheckLoginThrottle after a successful login = %v, want the IP to stay locked", lock)
+		}
+	})
+}
+
+func TestLoginFailuresExpire(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		setLoginLimits(t, "1000")
+		recordFailures(t, "ada@example.com", "192.0.2.1", 3)
+		if throttled(t, "ada@example.com", "192.0.2.1") == nil {
+			t.Fatal("The account is not locked after three failures")
+		}
+
+		// Once the lock and the window after it passed, the failures are forgotten and counting starts over.
+		past := time.Now().UTC().Add(-time.Minute)
+		_, err := db.DB.Exec(db.Rebind("UPDATE login_throttles SET lockedUntil = ?, expiresAt = ?"), past, past)
+		if err != nil {
+			t.Fatalf("Could not backdate the lock: %v", err)
+		}
+		if lock := throttled(t, "ada@example.com", "192.0.2.1"); lock != nil {
+			t.Errorf("The account is still locked for %v after the lock ended", lock.RetryAfter)
+		}
+		recordFailures(t, "ada@example.com", "192.0.2.1", 2)
+		if lock := throttled(t, "ada@example.com", "192.0.2.1"); lock != nil {
+			t.Errorf("The account is locked for %v after two failures in a new window, want no lock", lock.RetryAfter)
+		}
+		recordFailures(t, "ada@example.com", "192.0.2.1", 1)
+		if lock := throttled(t, "ada@example.com", "192.0.2.1"); lock == nil || lock.RetryAfter > time.Minute {
+			t.Errorf("CheckLoginThrottle after three failures in a new window = %v, want the first lock of a minute", lock)
+		}
+	})
+}
+
+func TestLoginSuccessAndUnlockForgetFailures(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		setLoginLimits(t, "1000")
+		recordFailures(t, "ada@example.com", "192.0.2.1", 2)
+		err := RecordLoginSuccess("ada@example.com")
+		if err != nil {
+			t.Fatalf("RecordLoginSuccess: %v", err)
+		}
+		recordFailures(t, "ada@example.com", "192.0.2.1", 2)
+		if lock := throttled(t, "ada@example.com", "192.0.2.1"); lock != nil {
+			t.Errorf("The account is locked after a successful login and two failures, want the count to start over")
+		}
+
+		userId := createTestUser(t, "ada@example.com")
+		recordFailures(t, "Ada@Example.com", "192.0.2.1", 1)
+		if throttled(t, "ada@example.com", "192.0.2.1") == nil {
+			t.Fatal("The account is not locked after three failures")
+		}
+		err = UnlockAccount(userId)
+		if err != nil {
+			t.Fatalf("UnlockAccount: %v", err)
+		}
+		if lock := throttled(t, "ada@example.com", "192.0.2.1"); lock != nil {
+			t.Errorf("The account is still locked for %v after unlocking it", lock.RetryAfter)
+		}
+
+		err = UnlockAccount(userId + 100)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UnlockAccount of an unknown user: got %v, want sql.ErrNoRows", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"time"
+)
+
+// The kinds of notifications.
+const (
+	NotificationEventCancelled = "event_cancelled"
+)
+
+// Notification tells a user about a change to an event the user registered for. ReadAt is set once the user
+// marked the notification as read.
+type Notification struct {
+	ID        int64
+	EventID   int64
+	Kind      string
+	Message   string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+// MaxNotifications is the number of most recent notifications returned by GetNotifications.
+const MaxNotifications = 100
+
+// GetNotifications returns the most recent notifications of the user, newest first.
+func GetNotifications(userId int64) ([]Notification, error) {
+	query := `
+	SELECT id, eventId, kind, message, createdAt, readAt FROM notifications
+	WHERE userId = ?
+	ORDER BY id DESC
+	LIMIT ?`
+	rows, err := db.DB.Query(db.Rebind(query), userId, MaxNotifications)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var notification Notification
+		err = rows.Scan(&notification.ID, &notification.EventID, &notification.Kind, &notification.Message, &notification.CreatedAt, &notification.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkNotificationRead marks the notification with the given ID of the user as read.
+// It returns sql.ErrNoRows if the user has no such notification.
+func MarkNotificationRead(userId, id int64) error {
+	query := "UPDATE notifications SET readAt = COALESCE(readAt, ?) WHERE id = ? AND userId = ?"
+	result, err := db.DB.Exec(db.Rebind(query), time.Now().UTC(), id, userId)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// notifyRegistrantsInTx creates a notification of the given kind for every user registered or waitlisted for
+// the event, within the transaction.
+func notifyRegistrantsInTx(tx *sql.Tx, eventId int64, kind, message string) error {
+	rows, err := tx.Query(db.Rebind("SELECT DISTINCT userId FROM registrations WHERE eventId = ?"), eventId)
+	if err != nil {
+		return err
+	}
+
+	var userIds []int64
+	for rows.Next() {
+		var userId int64
+		err = rows.Scan(&userId)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		userIds = append(userIds, userId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, userId := range userIds {
+		query := "INSERT INTO notifications(userId, eventId, kind, message, createdAt) VALUES (?, ?, ?, ?, ?)"
+		_, err = tx.Exec(db.Rebind(query), userId, eventId, kind, message, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"RestAPI/config"
+	"RestAPI/db"
+	"RestAPI/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken is returned by ResetPassword if the token is unknown, expired or was already used.
+var ErrInvalidResetToken = errors.New("the password reset token is invalid or expired")
+
+// PasswordResetTTL returns how long password reset tokens are valid, configured by PASSWORD_RESET_TTL.
+func PasswordResetTTL() time.Duration {
+	return config.Duration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+// IssuePasswordResetToken creates a password reset token for the user with the given email, which is valid for
+// PasswordResetTTL and replaces the user's earlier tokens. Only the hash of the token is
+// stored; the plain token is returned so it can be mailed to the user.
+// It returns the ID of the user together with the token, and sql.ErrNoRows if no user has the email.
+func IssuePasswordResetToken(email string) (int64, string, error) {
+	userId, _, err := Users.GetCredentials(email)
+	if err != nil {
+		return 0, "", err
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	err = usePasswordResetTokens(tx, userId, now)
+	if err != nil {
+		return 0, "", err
+	}
+
+	query := "INSERT INTO password_reset_tokens(userId, tokenHash, expiresAt, createdAt) VALUES (?, ?, ?, ?)"
+	_, err = tx.Exec(db.Rebind(query), userId, utils.HashToken(token), now.Add(PasswordResetTTL()), now)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return userId, token, tx.Commit()
+}
+
+// ResetPassword consumes the password reset token and sets the new password of its user, hashed like at signup.
+// Every login session of the user is ended, so whoever may have known the old password is logged out; access
+// tokens already issued stay valid until they expire. A lock of the account after failed logins is lifted.
+// It returns the ID of the user, and ErrInvalidResetToken if the token is unknown, expired or was already used.
+func ResetPassword(token, password string) (int64, error) {
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Marking the token as used and reading its user in one statement lets only one of concurrent resets succeed.
+	var userId int64
+	now := time.Now().UTC()
+	query := "UPDATE password_reset_tokens SET usedAt = ? WHERE tokenHash = ? AND usedAt IS NULL AND expiresAt > ? RETURNING userId"
+	err = tx.QueryRow(db.Rebind(query), now, utils.HashToken(token), now).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(db.Rebind("UPDATE users SET password = ? WHERE id = ?"), passwordHash, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	err = usePasswordResetTokens(tx, userId, now)
+	if err != nil {
+		return 0, err
+	}
+
+	err = revokeUserRefreshTokens(tx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	err = unlockAccountInTx(tx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, tx.Commit()
+}
+
+// usePasswordResetTokens marks every unused password reset token of the user as used, within the transaction.
+func usePasswordResetTokens(tx *sql.Tx, userId int64, now time.Time) error {
+	_, err := tx.Exec(db.Rebind("UPDATE password_reset_tokens SET usedAt = ? WHERE userId = ? AND usedAt IS NULL"), now, userId)
+	return err
+}
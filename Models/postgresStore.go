@@ -0,0 +1,123 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"strings"
+)
+
+// postgresEventStore is the EventStore for PostgreSQL. Full-text search uses the generated tsvector
+// column events.search and its GIN index.
+type postgresEventStore struct {
+	sqlEventStore
+}
+
+// postgresRegistrationStore is the RegistrationStore for PostgreSQL. Every registration change first
+// locks the event's row with SELECT ... FOR UPDATE, which serializes concurrent registrations of the
+// same event while registrations of other events proceed in parallel.
+type postgresRegistrationStore struct {
+	sqlRegistrationStore
+}
+
+// Search matches the query against events.search, ranked with ts_rank and highlighted with ts_headline.
+// The rank is negated so that, as with SQLite, lower ranks are better matches.
+func (postgresEventStore) Search(query string, limit int, viewerId int64) ([]SearchResult, error) {
+	tsQuery := buildTSQuery(parseSearchQuery(query))
+	if tsQuery == "" {
+		return nil, ErrEmptySearch
+	}
+
+	sqlQuery := `
+	SELECT ` + prefixColumns("events.", eventColumns) + `,
+		-ts_rank(events.search, query) AS rank,
+		ts_headline('simple', events.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+		ts_headline('simple', events.description, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=1, MaxWords=24, MinWords=8, FragmentDelimiter="…"'),
+		ts_headline('simple', events.location, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+	FROM events, to_tsquery('simple', $1) AS query
+	WHERE events.search @@ query AND events.deletedAt IS NULL AND (events.status <> $3 OR events.user_id = $4)
+	ORDER BY rank
+	LIMIT $2`
+	rows, err := db.DB.Query(sqlQuery, tsQuery, limit, EventDraft, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var name, description, location string
+		result.Event, err = scanEvent(rows, &result.Rank, &name, &description, &location)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Highlights = map[string]string{"name": name, "description": description, "location": location}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// Register locks the event's row and registers the user in the same transaction.
+func (postgresRegistrationStore) Register(eventId, userId, occurrence int64) (*Registration, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = lockEvent(tx, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := registerInTx(tx, eventId, userId, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return registration, tx.Commit()
+}
+
+// Cancel locks the event's row, removes the user's registration and promotes waitlisted users in the same transaction.
+func (postgresRegistrationStore) Cancel(eventId, userId, occurrence int64) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockEvent(tx, eventId)
+	if err != nil {
+		return err
+	}
+
+	err = cancelInTx(tx, eventId, userId, occurrence)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockEvent locks the row of the event until the end of the transaction.
+// It returns sql.ErrNoRows if the event does not exist or is in the trash.
+func lockEvent(tx *sql.Tx, eventId int64) error {
+	var id int64
+	return tx.QueryRow("SELECT id FROM events WHERE id = $1 AND deletedAt IS NULL FOR UPDATE", eventId).Scan(&id)
+}
+
+// buildTSQuery turns search terms into a to_tsquery expression: the words of a term are joined with the
+// followed-by operator <->, prefix terms get :* on their last word, and the terms are joined with &.
+// Terms only consist of letters and digits (see parseSearchQuery), so they need no further quoting.
+func buildTSQuery(terms []searchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = strings.Join(term.words, " <-> ")
+		if term.prefix {
+			parts[i] += ":*"
+		}
+	}
+	return strings.Join(parts, " & ")
+}
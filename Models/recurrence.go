@@ -0,0 +1,388 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"github.com/teambition/rrule-go"
+	"strings"
+	"time"
+)
+
+// The errors of recurring events.
+var (
+	// ErrOccurrenceRequired is returned when registering for a recurring event without giving the occurrence.
+	ErrOccurrenceRequired = errors.New("an occurrence is required for a recurring event")
+	// ErrNotRecurring is returned when an occurrence is given for an event that does not recur.
+	ErrNotRecurring = errors.New("the event does not recur")
+	// ErrNotAnOccurrence is returned when the given time is not an occurrence of the event.
+	ErrNotAnOccurrence = errors.New("not an occurrence of the event")
+	// ErrRecurrenceChange is returned when an update would make an event with registrations recurring or
+	// not recurring, which would leave the registrations without their occurrence.
+	ErrRecurrenceChange = errors.New("can not change whether an event with registrations recurs")
+)
+
+// recurrenceFrequencies are the frequencies allowed in recurrence rules. More frequent rules would create
+// an unreasonable number of occurrences.
+var recurrenceFrequencies = []rrule.Frequency{rrule.DAILY, rrule.WEEKLY, rrule.MONTHLY, rrule.YEARLY}
+
+// IsRecurring reports whether the event has a recurrence rule.
+func (event Event) IsRecurring() bool {
+	return event.RecurrenceRule != ""
+}
+
+// parseRecurrenceRule parses an RRULE value, without the RRULE: name, for a series starting at start.
+// The occurrences are computed in the time zone of start, so they keep its local time across daylight saving
+// time changes. Only a single rule with a frequency of DAILY or less frequent is accepted.
+func parseRecurrenceRule(rule string, start time.Time) (*rrule.RRule, error) {
+	if strings.ContainsAny(rule, ":\r\n") {
+		return nil, errors.New("the recurrence rule must be a single RRULE value")
+	}
+
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, frequency := range recurrenceFrequencies {
+		allowed = allowed || option.Freq == frequency
+	}
+	if !allowed {
+		return nil, errors.New("the recurrence rule must recur daily or less frequently")
+	}
+
+	option.Dtstart = start.Truncate(time.Second)
+	return rrule.NewRRule(*option)
+}
+
+// recurrence returns the occurrences of the recurring event as a set: the starts produced by its rule,
+// without the exception dates.
+func (event Event) recurrence() (*rrule.Set, error) {
+	rule, err := parseRecurrenceRule(event.RecurrenceRule, event.DateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &rrule.Set{}
+	set.RRule(rule)
+	for _, exceptionDate := range event.ExceptionDates {
+		set.ExDate(exceptionDate.UTC().Truncate(time.Second))
+	}
+	return set, nil
+}
+
+// Occurrences returns the starts of the occurrences of the event within the window, both ends inclusive.
+// An event that does not recur has a single occurrence at its DateTime.
+func (event Event) Occurrences(from, to time.Time) ([]time.Time, error) {
+	if !event.IsRecurring() {
+		if event.DateTime.Before(from) || event.DateTime.After(to) {
+			return nil, nil
+		}
+		return []time.Time{event.DateTime}, nil
+	}
+
+	set, err := event.recurrence()
+	if err != nil {
+		return nil, err
+	}
+	return set.Between(from, to, true), nil
+}
+
+// Occurrence returns a copy of the event for the occurrence starting at start: its DateTime is start, in the
+// time zone of the event, and its EndDateTime keeps the duration of the event.
+func (event Event) Occurrence(start time.Time) Event {
+	occurrence := event
+	occurrence.DateTime = start.In(event.DateTime.Location())
+	if event.EndDateTime != nil {
+		end := occurrence.DateTime.Add(event.EndDateTime.Sub(event.DateTime))
+		occurrence.EndDateTime = &end
+	}
+	return occurrence
+}
+
+// occurrenceKey checks the occurrence given for the event and returns the key that identifies it in the
+// registrations table: the start of the occurrence in Unix seconds, or 0 for an event that does not recur.
+// It returns ErrOccurrenceRequired if the event recurs and no occurrence is given, ErrNotRecurring if an
+// occurrence is given for an event that does not recur, and ErrNotAnOccurrence if the event has no occurrence
+// starting at the given time.
+func (event Event) occurrenceKey(occurrence *time.Time) (int64, error) {
+	if !event.IsRecurring() {
+		if occurrence != nil {
+			return 0, ErrNotRecurring
+		}
+		return 0, nil
+	}
+	if occurrence == nil {
+		return 0, ErrOccurrenceRequired
+	}
+
+	start := occurrence.UTC().Truncate(time.Second)
+	occurrences, err := event.Occurrences(start, start)
+	if err != nil {
+		return 0, err
+	}
+	if len(occurrences) == 0 {
+		return 0, ErrNotAnOccurrence
+	}
+	return start.Unix(), nil
+}
+
+// occurrenceTime returns the start of the occurrence identified by a key returned by occurrenceKey,
+// or nil for the key 0 of events that do not recur.
+func occurrenceTime(key int64) *time.Time {
+	if key == 0 {
+		return nil
+	}
+	start := time.Unix(key, 0).UTC()
+	return &start
+}
+
+// endRecurrenceBefore returns the recurrence rule of the event changed to end right before the occurrence
+// starting at start. A COUNT of the rule is replaced by the UNTIL.
+func (event Event) endRecurrenceBefore(start time.Time) (string, error) {
+	rule, err := parseRecurrenceRule(event.RecurrenceRule, event.DateTime)
+	if err != nil {
+		return "", err
+	}
+
+	option := rule.OrigOptions
+	option.Dtstart = time.Time{}
+	option.Count = 0
+	option.Until = start.UTC().Add(-time.Second)
+	return option.RRuleString(), nil
+}
+
+// remainingRecurrenceRule returns the recurrence rule of the event for a series continuing it from the
+// occurrence starting at start. A COUNT of the rule is reduced by the occurrences before start, so the
+// continued series ends with the same occurrence as the original one.
+func (event Event) remainingRecurrenceRule(start time.Time) (string, error) {
+	rule, err := parseRecurrenceRule(event.RecurrenceRule, event.DateTime)
+	if err != nil {
+		return "", err
+	}
+
+	option := rule.OrigOptions
+	if option.Count == 0 {
+		return event.RecurrenceRule, nil
+	}
+
+	before := len(rule.Between(event.DateTime.UTC(), start.UTC(), true)) - 1
+	option.Dtstart = time.Time{}
+	option.Count = max(option.Count-before, 1)
+	return option.RRuleString(), nil
+}
+
+// exceptionDatesFrom splits the exception dates of the event into those before start and those at or after it.
+func (event Event) exceptionDatesFrom(start time.Time) ([]time.Time, []time.Time) {
+	var before, after []time.Time
+	for _, exceptionDate := range event.ExceptionDates {
+		if exceptionDate.Before(start) {
+			before = append(before, exceptionDate)
+		} else {
+			after = append(after, exceptionDate)
+		}
+	}
+	return before, after
+}
+
+// UpdateOccurrences applies the updated event to the occurrence of the recurring event starting at start and,
+// if following is set, to all occurrences after it. The updated event is saved as an event of its own, which is
+// returned, and the registrations of the changed occurrences move to it:
+//   - A single occurrence is detached: it is excluded from the series and the updated event does not recur.
+//   - The following occurrences are split off: the series ends before start and the updated event continues it,
+//     with the rule and exception dates of the series unless it has its own. Starting from the first occurrence,
+//     this updates the whole series in place instead.
+//
+// In both cases the updated event has the status of the series and keeps its time zone unless it has its own.
+// It returns ErrEventClosed if the series was cancelled or completed, and ErrVersionMismatch if the updated
+// event has a Version other than that of the series, see Event.Update.
+//
+// It returns ErrNotRecurring or ErrNotAnOccurrence if start is not an occurrence of the event.
+func (event Event) UpdateOccurrences(start time.Time, updated Event, following bool) (*Event, error) {
+	return Events.UpdateOccurrences(event.ID, start, updated, following)
+}
+
+// DeleteOccurrences removes the occurrence of the recurring event starting at start and, if following is set,
+// all occurrences after it, together with their registrations. Deleting the following occurrences from the
+// first occurrence moves the whole event to the trash, like Delete.
+// It returns ErrEventClosed if the series was cancelled or completed, and, like Delete, ErrEventHasRegistrations
+// if the series is published and users are registered for the occurrences, since they would not be notified.
+// It returns ErrNotRecurring or ErrNotAnOccurrence if start is not an occurrence of the event.
+func (event Event) DeleteOccurrences(start time.Time, following bool) error {
+	return Events.DeleteOccurrences(event.ID, start, following)
+}
+
+// UpdateOccurrences locks the series, splits it and moves the registrations in one transaction.
+func (sqlEventStore) UpdateOccurrences(id int64, start time.Time, updated Event, following bool) (*Event, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	series, err := selectEventForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if updated.Version != 0 && updated.Version != series.Version {
+		return nil, ErrVersionMismatch
+	}
+	if series.IsClosed() {
+		return nil, ErrEventClosed
+	}
+	key, err := series.occurrenceKey(&start)
+	if err != nil {
+		return nil, err
+	}
+	start = time.Unix(key, 0).UTC()
+	shift := updated.DateTime.Unix() - key
+
+	updated.ID = 0
+	updated.Version = 0
+	updated.UserID = series.UserID
+	updated.Status = series.Status
+	if updated.TimeZone == "" {
+		updated.TimeZone = series.TimeZone
+	}
+	if following {
+		before, after := series.exceptionDatesFrom(start)
+		if updated.RecurrenceRule == "" {
+			updated.RecurrenceRule, err = series.remainingRecurrenceRule(start)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if updated.ExceptionDates == nil {
+			for _, exceptionDate := range after {
+				updated.ExceptionDates = append(updated.ExceptionDates, exceptionDate.Add(time.Duration(shift)*time.Second))
+			}
+		}
+
+		if start.Equal(series.DateTime) {
+			updated.ID = series.ID
+			err = updateEventInTx(tx, series, updated)
+			if err != nil {
+				return nil, err
+			}
+			return &updated, tx.Commit()
+		}
+
+		series.RecurrenceRule, err = series.endRecurrenceBefore(start)
+		if err != nil {
+			return nil, err
+		}
+		series.ExceptionDates = before
+	} else {
+		updated.RecurrenceRule = ""
+		updated.ExceptionDates = nil
+		series.ExceptionDates = append(series.ExceptionDates, start)
+	}
+
+	err = updateRecurrenceInTx(tx, series)
+	if err != nil {
+		return nil, err
+	}
+	err = insertEvent(tx, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE registrations SET eventId = ?, occurrence = 0 WHERE eventId = ? AND occurrence = ?"
+	args := []any{updated.ID, series.ID, key}
+	if following {
+		query = "UPDATE registrations SET eventId = ?, occurrence = occurrence + ? WHERE eventId = ? AND occurrence >= ?"
+		args = []any{updated.ID, shift, series.ID, key}
+	}
+	_, err = tx.Exec(db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = promoteAllWaitlisted(tx, updated.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, tx.Commit()
+}
+
+// DeleteOccurrences locks the series, excludes or ends its occurrences and deletes their registrations in one transaction.
+func (sqlEventStore) DeleteOccurrences(id int64, start time.Time, following bool) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	series, err := selectEventForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+	if series.IsClosed() {
+		return ErrEventClosed
+	}
+	key, err := series.occurrenceKey(&start)
+	if err != nil {
+		return err
+	}
+	start = time.Unix(key, 0).UTC()
+
+	if series.Status == EventPublished {
+		query := "SELECT COUNT(*) FROM registrations WHERE eventId = ? AND occurrence = ?"
+		if following {
+			query = "SELECT COUNT(*) FROM registrations WHERE eventId = ? AND occurrence >= ?"
+		}
+		var registrations int
+		err = tx.QueryRow(db.Rebind(query), series.ID, key).Scan(&registrations)
+		if err != nil {
+			return err
+		}
+		if registrations > 0 {
+			return ErrEventHasRegistrations
+		}
+	}
+
+	if following && start.Equal(series.DateTime) {
+		err = trashEventInTx(tx, series.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	query := "DELETE FROM registrations WHERE eventId = ? AND occurrence = ?"
+	if following {
+		query = "DELETE FROM registrations WHERE eventId = ? AND occurrence >= ?"
+		series.RecurrenceRule, err = series.endRecurrenceBefore(start)
+		if err != nil {
+			return err
+		}
+		series.ExceptionDates, _ = series.exceptionDatesFrom(start)
+	} else {
+		series.ExceptionDates = append(series.ExceptionDates, start)
+	}
+
+	_, err = tx.Exec(db.Rebind(query), series.ID, key)
+	if err != nil {
+		return err
+	}
+
+	err = updateRecurrenceInTx(tx, series)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateRecurrenceInTx stores the recurrence rule and exception dates of the event.
+func updateRecurrenceInTx(tx *sql.Tx, event Event) error {
+	recurrenceRule, exceptionDates, err := recurrenceColumns(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(db.Rebind("UPDATE events SET recurrenceRule = ?, exceptionDates = ?, version = version + 1 WHERE id = ?"), recurrenceRule, exceptionDates, event.ID)
+	return err
+}
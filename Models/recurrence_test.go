@@ -0,0 +1,235 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recurringEvent returns an event starting at start that recurs by the rule, except on the exception dates.
+func recurringEvent(start time.Time, rule string, exceptionDates ...time.Time) Event {
+	return Event{DateTime: start, RecurrenceRule: rule, ExceptionDates: exceptionDates}
+}
+
+// days returns the starts of the days of March 2030 at 10:00 UTC.
+func days(days ...int) []time.Time {
+	starts := make([]time.Time, len(days))
+	for i, day := range days {
+		starts[i] = time.Date(2030, time.March, day, 10, 0, 0, 0, time.UTC)
+	}
+	return starts
+}
+
+// equalTimes reports whether both lists hold the same instants in the same order.
+func equalTimes(a, b []time.Time) bool {
+	return slices.EqualFunc(a, b, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+func TestOccurrencesWithCountUntilAndExceptionDates(t *testing.T) {
+	start := days(1)[0]
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	tests := []struct {
+		name  string
+		event Event
+		want  []time.Time
+	}{
+		{"count", recurringEvent(start, "FREQ=DAILY;COUNT=5"), days(1, 2, 3, 4, 5)},
+		// The exception dates are removed from the occurrences the COUNT produces, so they are not made up for.
+		{"count with exception dates", recurringEvent(start, "FREQ=DAILY;COUNT=5", days(2, 4)...), days(1, 3, 5)},
+		{"count excluding the first occurrence", recurringEvent(start, "FREQ=DAILY;COUNT=3", days(1)...), days(2, 3)},
+		// UNTIL is inclusive: an occurrence starting at UNTIL is part of the series.
+		{"until", recurringEvent(start, "FREQ=WEEKLY;UNTIL=20300322T100000Z"), days(1, 8, 15, 22)},
+		{"until before an occurrence", recurringEvent(start, "FREQ=WEEKLY;UNTIL=20300322T095959Z"), days(1, 8, 15)},
+		{"until with exception dates", recurringEvent(start, "FREQ=WEEKLY;UNTIL=20300322T100000Z", days(8, 22)...), days(1, 15)},
+		{"exception date in another time zone", recurringEvent(start, "FREQ=DAILY;COUNT=3", days(2)[0].In(berlin)), days(1, 3)},
+		{"exception date that is no occurrence", recurringEvent(start, "FREQ=WEEKLY;COUNT=2", days(2)...), days(1, 8)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			occurrences, err := test.event.Occurrences(start.AddDate(0, 0, -1), start.AddDate(1, 0, 0))
+			if err != nil {
+				t.Fatalf("Occurrences: %v", err)
+			}
+			if !equalTimes(occurrences, test.want) {
+				t.Errorf("Occurrences = %v, want %v", occurrences, test.want)
+			}
+		})
+	}
+}
+
+func TestOccurrencesWithinWindow(t *testing.T) {
+	event := recurringEvent(days(1)[0], "FREQ=DAILY;COUNT=10", days(4)...)
+	occurrences, err := event.Occurrences(days(3)[0], days(5)[0])
+	if err != nil {
+		t.Fatalf("Occurrences: %v", err)
+	}
+	if want := days(3, 5); !equalTimes(occurrences, want) {
+		t.Errorf("Occurrences = %v, want %v, both ends of the window included", occurrences, want)
+	}
+}
+
+func TestOccurrencesKeepLocalTimeAcrossDaylightSavingTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	// Daylight saving time starts in Berlin on March 31, 2030.
+	event := recurringEvent(time.Date(2030, time.March, 24, 10, 0, 0, 0, berlin), "FREQ=WEEKLY;COUNT=3", time.Date(2030, time.March, 31, 10, 0, 0, 0, berlin))
+	occurrences, err := event.Occurrences(event.DateTime, event.DateTime.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("Occurrences: %v", err)
+	}
+	want := []time.Time{time.Date(2030, time.March, 24, 10, 0, 0, 0, berlin), time.Date(2030, time.April, 7, 10, 0, 0, 0, berlin)}
+	if !equalTimes(occurrences, want) {
+		t.Errorf("Occurrences = %v, want %v", occurrences, want)
+	}
+}
+
+func TestOccurrenceKey(t *testing.T) {
+	event := recurringEvent(days(1)[0], "FREQ=DAILY;UNTIL=20300305T100000Z", days(3)...)
+	betweenOccurrences := days(2)[0].Add(time.Hour)
+	tests := []struct {
+		name       string
+		occurrence *time.Time
+		wantErr    error
+	}{
+		{"occurrence", &days(2)[0], nil},
+		{"last occurrence at UNTIL", &days(5)[0], nil},
+		{"exception date", &days(3)[0], ErrNotAnOccurrence},
+		{"after UNTIL", &days(6)[0], ErrNotAnOccurrence},
+		{"between occurrences", &betweenOccurrences, ErrNotAnOccurrence},
+		{"no occurrence", nil, ErrOccurrenceRequired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := event.occurrenceKey(test.occurrence)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("occurrenceKey = %d, %v, want %v", key, err, test.wantErr)
+			}
+			if err == nil && key != test.occurrence.Unix() {
+				t.Errorf("occurrenceKey = %d, want %d", key, test.occurrence.Unix())
+			}
+		})
+	}
+
+	_, err := Event{DateTime: days(1)[0]}.occurrenceKey(&days(1)[0])
+	if !errors.Is(err, ErrNotRecurring) {
+		t.Errorf("occurrenceKey of an event that does not recur: got %v, want ErrNotRecurring", err)
+	}
+}
+
+func TestSplitRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		name          string
+		event         Event
+		split         time.Time
+		wantBefore    []time.Time
+		wantContinued []time.Time
+	}{
+		{
+			name:          "count",
+			event:         recurringEvent(days(1)[0], "FREQ=DAILY;COUNT=5"),
+			split:         days(3)[0],
+			wantBefore:    days(1, 2),
+			wantContinued: days(3, 4, 5),
+		},
+		{
+			name:          "count with an exception date before the split",
+			event:         recurringEvent(days(1)[0], "FREQ=DAILY;COUNT=5", days(2)...),
+			split:         days(4)[0],
+			wantBefore:    days(1, 3),
+			wantContinued: days(4, 5),
+		},
+		{
+			name:          "until",
+			event:         recurringEvent(days(1)[0], "FREQ=DAILY;UNTIL=20300305T100000Z"),
+			split:         days(3)[0],
+			wantBefore:    days(1, 2),
+			wantContinued: days(3, 4, 5),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			beforeRule, err := test.event.endRecurrenceBefore(test.split)
+			if err != nil {
+				t.Fatalf("endRecurrenceBefore: %v", err)
+			}
+			remainingRule, err := test.event.remainingRecurrenceRule(test.split)
+			if err != nil {
+				t.Fatalf("remainingRecurrenceRule: %v", err)
+			}
+			before, after := test.event.exceptionDatesFrom(test.split)
+
+			series := recurringEvent(test.event.DateTime, beforeRule, before...)
+			continued := recurringEvent(test.split, remainingRule, after...)
+			window := []time.Time{days(1)[0], days(31)[0]}
+			occurrences, err := series.Occurrences(window[0], window[1])
+			if err != nil || !equalTimes(occurrences, test.wantBefore) {
+				t.Errorf("Occurrences of the series ending with %s = %v, %v, want %v", beforeRule, occurrences, err, test.wantBefore)
+			}
+			occurrences, err = continued.Occurrences(window[0], window[1])
+			if err != nil || !equalTimes(occurrences, test.wantContinued) {
+				t.Errorf("Occurrences of the series continuing with %s = %v, %v, want %v", remainingRule, occurrences, err, test.wantContinued)
+			}
+		})
+	}
+}
+
+func TestEventStoreUpdateFollowingOccurrences(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		event := testEvent(userId)
+		event.Status = EventPublished
+		event.TimeZone = "UTC"
+		event.RecurrenceRule = "FREQ=DAILY;COUNT=5"
+		first := event.DateTime
+		last := first.AddDate(0, 0, 4)
+		event.ExceptionDates = []time.Time{first.AddDate(0, 0, 1), first.AddDate(0, 0, 3)}
+		saveTestEvent(t, &event)
+		_, err := event.Register(userId, &last)
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+
+		split := first.AddDate(0, 0, 2)
+		updated := event
+		updated.Name = "Go Meetup, new location"
+		updated.DateTime = split.Add(time.Hour)
+		updated.RecurrenceRule = ""
+		updated.ExceptionDates = nil
+		continued, err := event.UpdateOccurrences(split, updated, true)
+		if err != nil {
+			t.Fatalf("UpdateOccurrences: %v", err)
+		}
+
+		series, err := Events.GetByID(event.ID)
+		if err != nil {
+			t.Fatalf("GetByID of the series: %v", err)
+		}
+		window := []time.Time{first, first.AddDate(0, 1, 0)}
+		occurrences, err := series.Occurrences(window[0], window[1])
+		if want := []time.Time{first}; err != nil || !equalTimes(occurrences, want) {
+			t.Errorf("Occurrences of the series = %v, %v, want %v", occurrences, err, want)
+		}
+
+		stored, err := Events.GetByID(continued.ID)
+		if err != nil {
+			t.Fatalf("GetByID of the continued series: %v", err)
+		}
+		occurrences, err = stored.Occurrences(window[0], window[1])
+		want := []time.Time{split.Add(time.Hour), last.Add(time.Hour)}
+		if err != nil || stored.Name != updated.Name || !equalTimes(occurrences, want) {
+			t.Errorf("Continued series %q has the occurrences %v, %v, want %q with %v", stored.Name, occurrences, err, updated.Name, want)
+		}
+
+		registered, err := Registrations.ListForUser(userId)
+		if err != nil || len(registered) != 1 || registered[0].Event.ID != continued.ID ||
+			registered[0].Occurrence == nil || !registered[0].Occurrence.Equal(want[1]) {
+			t.Errorf("ListForUser = %+v, %v, want the registration moved to %v of the continued series", registered, err, want[1])
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+// Registration statuses. A confirmed registration holds a seat of the event,
+// a waitlisted one waits for a seat to become free.
+const (
+	RegistrationConfirmed  = "confirmed"
+	RegistrationWaitlisted = "waitlisted"
+)
+
+// Registration represents a user's registration for an event.
+// Occurrence is the start of the occurrence of a recurring event the registration is for; every occurrence
+// has its own seats and waitlist. It is not set for events that do not recur.
+// Position is the 1-based place on the waitlist and only set for waitlisted registrations.
+type Registration struct {
+	EventID    int64
+	UserID     int64
+	Occurrence *time.Time `json:",omitempty"`
+	Status     string
+	Position   int64 `json:",omitempty"`
+}
+
+// RegisteredEvent is an event together with the status of a user's registration for it.
+// For a recurring event, Occurrence is the start of the occurrence the user registered for and Event is
+// that occurrence, see Event.Occurrence.
+type RegisteredEvent struct {
+	Event      Event
+	Occurrence *time.Time `json:",omitempty"`
+	Status     string
+}
+
+// GetRegisteredEvents returns every event the user with the given ID is registered or waitlisted for,
+// ordered by their dateTime. Registrations for occurrences of recurring events are ordered by the series' dateTime.
+// Events in the trash are left out.
+func GetRegisteredEvents(userId int64) ([]RegisteredEvent, error) {
+	return Registrations.ListForUser(userId)
+}
+
+// ListForUser joins the registrations of the user with their events.
+func (sqlRegistrationStore) ListForUser(userId int64) ([]RegisteredEvent, error) {
+	query := `
+	SELECT ` + prefixColumns("events.", eventColumns) + `, registrations.status, registrations.occurrence
+	FROM registrations JOIN events ON events.id = registrations.eventId
+	WHERE registrations.userId = ? AND events.deletedAt IS NULL
+	ORDER BY events.dateTime, events.id`
+	rows, err := db.DB.Query(db.Rebind(query), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	registered := []RegisteredEvent{}
+	for rows.Next() {
+		var result RegisteredEvent
+		var occurrence int64
+		result.Event, err = scanEvent(rows, &result.Status, &occurrence)
+		if err != nil {
+			return nil, err
+		}
+		result.Occurrence = occurrenceTime(occurrence)
+		if result.Occurrence != nil {
+			result.Event = result.Event.Occurrence(*result.Occurrence)
+		}
+		registered = append(registered, result)
+	}
+
+	return registered, rows.Err()
+}
+
+// registerInTx registers the user for the occurrence of the event within the transaction, putting the user on
+// the waitlist if all seats of the occurrence are taken. The occurrence is a key returned by Event.occurrenceKey.
+// The caller must make sure that concurrent registrations of the same event are serialized, or two transactions
+// could both see the last free seat.
+// If the user is already registered or waitlisted, the existing registration is returned.
+// It returns ErrEventNotOpen if the event is not published.
+func registerInTx(tx *sql.Tx, eventId, userId, occurrence int64) (*Registration, error) {
+	var eventStatus string
+	err := tx.QueryRow(db.Rebind("SELECT status FROM events WHERE id = ? AND deletedAt IS NULL"), eventId).Scan(&eventStatus)
+	if err != nil {
+		return nil, err
+	}
+	if eventStatus != EventPublished {
+		return nil, ErrEventNotOpen
+	}
+
+	existing, err := findRegistration(tx, eventId, userId, occurrence)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	freeSeats, err := countFreeSeats(tx, eventId, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	status := RegistrationConfirmed
+	if freeSeats == 0 {
+		status = RegistrationWaitlisted
+	}
+
+	query := "INSERT INTO registrations(eventId, userId, status, occurrence) VALUES (?,?,?,?)"
+	_, err = tx.Exec(db.Rebind(query), eventId, userId, status, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return findRegistration(tx, eventId, userId, occurrence)
+}
+
+// cancelInTx deletes the user's registration for the occurrence of the event within the transaction and gives
+// the freed seat to the next users on the waitlist of the occurrence.
+func cancelInTx(tx *sql.Tx, eventId, userId, occurrence int64) error {
+	query := "DELETE FROM registrations WHERE eventId = ? AND userId = ? AND occurrence = ?"
+	_, err := tx.Exec(db.Rebind(query), eventId, userId, occurrence)
+	if err != nil {
+		return err
+	}
+
+	return promoteWaitlisted(tx, eventId, occurrence)
+}
+
+// findRegistration loads the registration of the user for the occurrence of the event, including its waitlist position.
+// It returns sql.ErrNoRows if the user is not registered for the occurrence.
+func findRegistration(tx *sql.Tx, eventId, userId, occurrence int64) (*Registration, error) {
+	query := "SELECT id, status FROM registrations WHERE eventId = ? AND userId = ? AND occurrence = ? ORDER BY id LIMIT 1"
+
+	var id int64
+	registration := Registration{EventID: eventId, UserID: userId, Occurrence: occurrenceTime(occurrence)}
+	err := tx.QueryRow(db.Rebind(query), eventId, userId, occurrence).Scan(&id, &registration.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	if registration.Status == RegistrationWaitlisted {
+		query = "SELECT COUNT(*) FROM registrations WHERE eventId = ? AND occurrence = ? AND status = ? AND id <= ?"
+		err = tx.QueryRow(db.Rebind(query), eventId, occurrence, RegistrationWaitlisted, id).Scan(&registration.Position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &registration, nil
+}
+
+// countFreeSeats returns the number of seats of the occurrence of the event not held by a confirmed registration.
+// Events without a capacity have an unlimited number of seats, reported as math.MaxInt64.
+func countFreeSeats(tx *sql.Tx, eventId, occurrence int64) (int64, error) {
+	query := `
+	SELECT capacity, (SELECT COUNT(*) FROM registrations WHERE eventId = events.id AND occurrence = ? AND status = ?)
+	FROM events WHERE id = ?`
+
+	var capacity sql.NullInt64
+	var confirmed int64
+	err := tx.QueryRow(db.Rebind(query), occurrence, RegistrationConfirmed, eventId).Scan(&capacity, &confirmed)
+	if err != nil {
+		return 0, err
+	}
+
+	if !capacity.Valid {
+		return math.MaxInt64, nil
+	}
+	return max(capacity.Int64-confirmed, 0), nil
+}
+
+// promoteWaitlisted confirms as many waitlisted registrations of the occurrence of the event as there are free seats,
+// in the order the users joined the waitlist.
+func promoteWaitlisted(tx *sql.Tx, eventId, occurrence int64) error {
+	freeSeats, err := countFreeSeats(tx, eventId, occurrence)
+	if err != nil {
+		return err
+	}
+	if freeSeats == 0 {
+		return nil
+	}
+
+	query := `
+	UPDATE registrations SET status = ?
+	WHERE id IN (
+		SELECT id FROM registrations WHERE eventId = ? AND occurrence = ? AND status = ? ORDER BY id LIMIT ?
+	)`
+	_, err = tx.Exec(db.Rebind(query), RegistrationConfirmed, eventId, occurrence, RegistrationWaitlisted, freeSeats)
+	return err
+}
+
+// promoteAllWaitlisted promotes waitlisted registrations of every occurrence of the event that has any,
+// e.g. after the capacity of the event was raised.
+func promoteAllWaitlisted(tx *sql.Tx, eventId int64) error {
+	query := "SELECT DISTINCT occurrence FROM registrations WHERE eventId = ? AND status = ?"
+	rows, err := tx.Query(db.Rebind(query), eventId, RegistrationWaitlisted)
+	if err != nil {
+		return err
+	}
+
+	var occurrences []int64
+	for rows.Next() {
+		var occurrence int64
+		err = rows.Scan(&occurrence)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, occurrence := range occurrences {
+		err = promoteWaitlisted(tx, eventId, occurrence)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Role represents a named set of permissions that can be granted to users.
+// Permissions have the form "resource:action" or "resource:action:scope", e.g. "events:delete:any".
+// RequireTwoFactor is set if the users holding the role must enable two-factor authentication.
+type Role struct {
+	Name             string
+	Permissions      []string
+	RequireTwoFactor bool
+}
+
+// GetAllRoles retrieves every role together with the permissions it grants, ordered by role name.
+func GetAllRoles() ([]Role, error) {
+	query := `
+	SELECT roles.name, roles.requireTwoFactor, COALESCE(role_permissions.permission, '')
+	FROM roles LEFT JOIN role_permissions ON role_permissions.role = roles.name
+	ORDER BY roles.name, role_permissions.permission`
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var name, permission string
+		var requireTwoFactor bool
+		err := rows.Scan(&name, &requireTwoFactor, &permission)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, Role{Name: name, Permissions: []string{}, RequireTwoFactor: requireTwoFactor})
+		}
+		if permission != "" {
+			roles[len(roles)-1].Permissions = append(roles[len(roles)-1].Permissions, permission)
+		}
+	}
+
+	return roles, rows.Err()
+}
+
+// GetUserRoles returns the names of the roles held by the given user, ordered by name.
+// A user without roles gets an empty slice and no error.
+func GetUserRoles(userId int64) ([]string, error) {
+	rows, err := db.DB.Query(db.Rebind("SELECT role FROM user_roles WHERE userId = ? ORDER BY role"), userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetPermissionsForRoles returns the distinct permissions granted by any of the given roles.
+// Unknown role names are ignored.
+func GetPermissionsForRoles(roles []string) ([]string, error) {
+	permissions := []string{}
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+
+	args := make([]any, len(roles))
+	for i, role := range roles {
+		args[i] = role
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(roles)), ",")
+	query := "SELECT DISTINCT permission FROM role_permissions WHERE role IN (" + placeholders + ")"
+	rows, err := db.DB.Query(db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// RolesRequireTwoFactor reports whether any of the given roles requires two-factor authentication.
+// Unknown role names are ignored.
+func RolesRequireTwoFactor(roles []string) (bool, error) {
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	args := make([]any, len(roles))
+	for i, role := range roles {
+		args[i] = role
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(roles)), ",")
+	query := "SELECT COUNT(*) FROM roles WHERE requireTwoFactor AND name IN (" + placeholders + ")"
+	var count int
+	err := db.DB.QueryRow(db.Rebind(query), args...).Scan(&count)
+	return count > 0, err
+}
+
+// SetRoleTwoFactor sets whether the users holding the role must enable two-factor authentication and returns
+// whether the role required it before. Users who have not enabled it can then only enroll until they do.
+// It returns ErrUnknownRole if the role does not exist.
+func SetRoleTwoFactor(role string, required bool) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT requireTwoFactor FROM roles WHERE name = ?"
+	if db.Driver == db.Postgres {
+		query += " FOR UPDATE"
+	}
+	var previous bool
+	err = tx.QueryRow(db.Rebind(query), role).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUnknownRole
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(db.Rebind("UPDATE roles SET requireTwoFactor = ? WHERE name = ?"), required, role)
+	if err != nil {
+		return false, err
+	}
+	return previous, tx.Commit()
+}
+
+// ErrUnknownRole is returned by GrantRole and SetRoleTwoFactor if no role with the given name exists.
+var ErrUnknownRole = errors.New("unknown role")
+
+// GrantRole gives the role to the user. Granting a role the user already holds is not an error.
+// It returns ErrUnknownRole if the role does not exist.
+func GrantRole(userId int64, role string) error {
+	var exists int
+	err := db.DB.QueryRow(db.Rebind("SELECT COUNT(*) FROM roles WHERE name = ?"), role).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUnknownRole
+	}
+
+	_, err = db.DB.Exec(db.Rebind("INSERT INTO user_roles(userId, role) VALUES (?, ?) ON CONFLICT DO NOTHING"), userId, role)
+	return err
+}
+
+// RevokeRole removes the role from the user. Revoking a role the user does not hold is not an error.
+func RevokeRole(userId int64, role string) error {
+	_, err := db.DB.Exec(db.Rebind("DELETE FROM user_roles WHERE userId = ? AND role = ?"), userId, role)
+	return err
+}
+
+// EnsureAdmin grants the admin role to the user with the given email, compared case-insensitively, if such a
+// user exists.
+// It is used at startup to bootstrap the first administrator from the ADMIN_EMAIL setting.
+func EnsureAdmin(email string) error {
+	query := `
+	INSERT INTO user_roles(userId, role)
+	SELECT id, 'admin' FROM users WHERE LOWER(email) = LOWER(?)
+	ON CONFLICT DO NOTHING`
+	_, err := db.DB.Exec(db.Rebind(query), email)
+	return err
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetRoleTwoFactor(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		for _, test := range []struct {
+			required     bool
+			wantPrevious bool
+		}{{true, false}, {true, true}, {false, true}} {
+			previous, err := SetRoleTwoFactor("organizer", test.required)
+			if err != nil || previous != test.wantPrevious {
+				t.Errorf("SetRoleTwoFactor(%v) = %v, %v, want the previous value %v", test.required, previous, err, test.wantPrevious)
+			}
+		}
+
+		required, err := RolesRequireTwoFactor([]string{"organizer"})
+		if err != nil || required {
+			t.Errorf("RolesRequireTwoFactor = %v, %v, want false after disabling it", required, err)
+		}
+		_, err = SetRoleTwoFactor("unknown", true)
+		if !errors.Is(err, ErrUnknownRole) {
+			t.Errorf("SetRoleTwoFactor of an unknown role: got %v, want ErrUnknownRole", err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"RestAPI/db"
+	"strings"
+)
+
+// sqliteEventStore is the EventStore for SQLite. Full-text search uses the events_fts FTS5 index.
+type sqliteEventStore struct {
+	sqlEventStore
+}
+
+// sqliteRegistrationStore is the RegistrationStore for SQLite. SQLite transactions are opened with
+// BEGIN IMMEDIATE (see db.InitDB) and hold the database's write lock from their start, which serializes
+// concurrent registrations.
+type sqliteRegistrationStore struct {
+	sqlRegistrationStore
+}
+
+// Search runs an FTS5 MATCH query against events_fts, ranked with bm25 and highlighted with highlight and snippet.
+func (sqliteEventStore) Search(query string, limit int, viewerId int64) ([]SearchResult, error) {
+	if !db.FullTextSearch {
+		return nil, ErrSearchUnavailable
+	}
+
+	match := buildMatchExpression(parseSearchQuery(query))
+	if match == "" {
+		return nil, ErrEmptySearch
+	}
+
+	sqlQuery := `
+	SELECT ` + prefixColumns("events.", eventColumns) + `,
+		bm25(events_fts, 10.0, 1.0, 5.0) AS rank,
+		highlight(events_fts, 0, '<mark>', '</mark>'),
+		snippet(events_fts, 1, '<mark>', '</mark>', '…', 24),
+		highlight(events_fts, 2, '<mark>', '</mark>')
+	FROM events_fts JOIN events ON events.id = events_fts.rowid
+	WHERE events_fts MATCH ? AND events.deletedAt IS NULL AND (events.status <> ? OR events.user_id = ?)
+	ORDER BY rank
+	LIMIT ?`
+	rows, err := db.DB.Query(sqlQuery, match, EventDraft, viewerId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var name, description, location string
+		result.Event, err = scanEvent(rows, &result.Rank, &name, &description, &location)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Highlights = map[string]string{"name": name, "description": description, "location": location}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// Register registers the user for the event in an immediate transaction.
+func (sqliteRegistrationStore) Register(eventId, userId, occurrence int64) (*Registration, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	registration, err := registerInTx(tx, eventId, userId, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return registration, tx.Commit()
+}
+
+// Cancel removes the user's registration and promotes waitlisted users in an immediate transaction.
+func (sqliteRegistrationStore) Cancel(eventId, userId, occurrence int64) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = cancelInTx(tx, eventId, userId, occurrence)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// buildMatchExpression turns search terms into an FTS5 MATCH expression.
+// Every term is quoted as an FTS5 string, which matches its words as a phrase; prefix terms get a trailing *.
+// The quoted terms are joined with spaces, which FTS5 treats as AND.
+func buildMatchExpression(terms []searchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"RestAPI/db"
+	"time"
+)
+
+// EventStore persists events. The model functions and methods of Event, like GetEventByID and
+// Event.Save, go through the EventStore selected by InitStores. Deleted events stay in the trash until they are
+// restored or purged; only ListDeleted, GetDeletedByID, Restore and Purge return or touch them.
+type EventStore interface {
+	Save(event *Event) error
+	SaveAll(events []Event) error
+	GetAll() ([]Event, error)
+	GetByID(id int64) (*Event, error)
+	List(filter EventFilter) (*EventPage, error)
+	Search(query string, limit int, viewerId int64) ([]SearchResult, error)
+	Update(event Event) error
+	UpdateOccurrences(id int64, start time.Time, updated Event, following bool) (*Event, error)
+	Delete(id int64) error
+	DeleteOccurrences(id int64, start time.Time, following bool) error
+	SetStatus(id int64, status, reason string) (*Event, error)
+	ListDeleted(userId int64) ([]Event, error)
+	GetDeletedByID(id int64) (*Event, error)
+	Restore(id int64) error
+	Purge(before time.Time) (int64, error)
+}
+
+// UserStore persists user accounts. Passwords reach the store already hashed.
+// Emails are unique regardless of case; Create fails with a constraint violation (see db.IsConstraintViolation) for a
+// taken email, and GetCredentials finds a user by its email in any case.
+type UserStore interface {
+	Create(email, passwordHash string, roles []string) (int64, error)
+	GetCredentials(email string) (int64, string, error)
+	GetByID(id int64) (*User, error)
+}
+
+// RegistrationStore persists the registrations of users for events, including the waitlist.
+// Registrations are made per occurrence, identified by the key returned by Event.occurrenceKey.
+// Implementations must serialize concurrent registrations of the same event so its capacity is never exceeded.
+type RegistrationStore interface {
+	Register(eventId, userId, occurrence int64) (*Registration, error)
+	Cancel(eventId, userId, occurrence int64) error
+	ListForUser(userId int64) ([]RegisteredEvent, error)
+}
+
+// The stores used by the models, set by InitStores.
+var (
+	Events        EventStore
+	Users         UserStore
+	Registrations RegistrationStore
+)
+
+// sqlEventStore implements the parts of EventStore that are plain SQL and work unchanged on SQLite and
+// PostgreSQL; queries are written with ? placeholders and passed through db.Rebind.
+// The database specific stores embed it and add full-text search.
+type sqlEventStore struct{}
+
+// sqlRegistrationStore implements the parts of RegistrationStore that need no locking and work unchanged on
+// SQLite and PostgreSQL. The database specific stores embed it and add registering and cancelling.
+type sqlRegistrationStore struct{}
+
+// sqlUserStore implements UserStore for both SQLite and PostgreSQL.
+type sqlUserStore struct{}
+
+// InitStores selects the store implementations matching the database opened by db.InitDB.
+// It must be called after db.InitDB and before any model function is used.
+func InitStores() {
+	Users = sqlUserStore{}
+
+	switch db.Driver {
+	case db.Postgres:
+		Events = postgresEventStore{}
+		Registrations = postgresRegistrationStore{}
+	default:
+		Events = sqliteEventStore{}
+		Registrations = sqliteRegistrationStore{}
+	}
+}
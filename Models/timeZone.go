@@ -0,0 +1,55 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTimeZone is the time zone of events created without one.
+const DefaultTimeZone = "UTC"
+
+// timeZones caches the time zones loaded by loadTimeZone by their name, since time.LoadLocation reads the
+// zone from the time zone database on every call.
+var timeZones sync.Map
+
+// loadTimeZone returns the IANA time zone with the given name, e.g. Europe/Berlin. An empty name and unknown
+// zones return UTC; zone names are checked by the "timezone" binding rule before they are stored.
+func loadTimeZone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if zone, ok := timeZones.Load(name); ok {
+		return zone.(*time.Location)
+	}
+
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	timeZones.Store(name, zone)
+	return zone
+}
+
+// InZone returns a copy of the event with its datetimes and exception dates expressed in the IANA time zone
+// with the given name, or in the event's own TimeZone if the name is empty. Only the rendering changes;
+// the times still denote the same instants.
+func (event Event) InZone(name string) Event {
+	if name == "" {
+		name = event.TimeZone
+	}
+	zone := loadTimeZone(name)
+
+	event.DateTime = event.DateTime.In(zone)
+	if event.EndDateTime != nil {
+		end := event.EndDateTime.In(zone)
+		event.EndDateTime = &end
+	}
+	if event.ExceptionDates != nil {
+		exceptionDates := make([]time.Time, len(event.ExceptionDates))
+		for i, exceptionDate := range event.ExceptionDates {
+			exceptionDates[i] = exceptionDate.In(zone)
+		}
+		event.ExceptionDates = exceptionDates
+	}
+	return event
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RefreshTokenTTL is the lifetime of a refresh token. Every rotation issues a new token
+// with a fresh lifetime, so an active client is never forced to log in again.
+const RefreshTokenTTL = time.Hour * 24 * 30
+
+// IssueRefreshToken creates a new refresh token for the given user and starts a new token family.
+// A family groups a refresh token with every token that was later rotated from it, which lets
+// RotateRefreshToken revoke the whole login session when an already rotated token is reused.
+// Only the hash of the token is stored; the plain token is returned so it can be sent to the client once.
+func IssueRefreshToken(userId int64) (string, error) {
+	familyId, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	token, err := insertRefreshToken(tx, userId, familyId)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one from the same family.
+// The presented token is revoked and a new token is issued in a single transaction.
+// If the presented token was already revoked, it has either been rotated before or the user
+// logged out, so the token is treated as stolen and every token of its family is revoked.
+// It returns the ID of the user the token belongs to together with the new plain token.
+func RotateRefreshToken(token string) (int64, string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var id, userId int64
+	var familyId string
+	var expiresAt time.Time
+	var revoked bool
+	query := "SELECT id, userId, familyId, expiresAt, revoked FROM refresh_tokens WHERE tokenHash = ?"
+	err = tx.QueryRow(db.Rebind(query), utils.HashToken(token)).Scan(&id, &userId, &familyId, &expiresAt, &revoked)
+	if err != nil {
+		return 0, "", errors.New("invalid refresh token")
+	}
+
+	if revoked {
+		err = revokeRefreshTokenFamily(tx, familyId)
+		if err != nil {
+			return 0, "", err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return 0, "", err
+		}
+		return 0, "", errors.New("refresh token reuse detected")
+	}
+
+	if time.Now().After(expiresAt) {
+		return 0, "", errors.New("refresh token expired")
+	}
+
+	// Only one concurrent rotation of the same token may succeed; the loser sees no affected row.
+	result, err := tx.Exec(db.Rebind("UPDATE refresh_tokens SET revoked = TRUE WHERE id = ? AND NOT revoked"), id)
+	if err != nil {
+		return 0, "", err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, "", err
+	}
+	if affected != 1 {
+		return 0, "", errors.New("refresh token reuse detected")
+	}
+
+	newToken, err := insertRefreshToken(tx, userId, familyId)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return userId, newToken, tx.Commit()
+}
+
+// RevokeRefreshToken revokes the family the given refresh token belongs to, ending the login session.
+// Revoking an unknown token is not an error, so logging out twice is harmless.
+func RevokeRefreshToken(token string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familyId string
+	err = tx.QueryRow(db.Rebind("SELECT familyId FROM refresh_tokens WHERE tokenHash = ?"), utils.HashToken(token)).Scan(&familyId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = revokeRefreshTokenFamily(tx, familyId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertRefreshToken generates a new refresh token, stores its hash in the given family and returns the plain token.
+func insertRefreshToken(tx *sql.Tx, userId int64, familyId string) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	INSERT INTO refresh_tokens(userId, familyId, tokenHash, expiresAt, createdAt)
+	VALUES (?, ?, ?, ?, ?)`
+	now := time.Now()
+	_, err = tx.Exec(db.Rebind(query), userId, familyId, utils.HashToken(token), now.Add(RefreshTokenTTL), now)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// revokeRefreshTokenFamily marks every refresh token of the given family as revoked.
+func revokeRefreshTokenFamily(tx *sql.Tx, familyId string) error {
+	_, err := tx.Exec(db.Rebind("UPDATE refresh_tokens SET revoked = TRUE WHERE familyId = ?"), familyId)
+	return err
+}
+
+// revokeUserRefreshTokens marks every refresh token of the user as revoked, ending all of the user's login sessions.
+func revokeUserRefreshTokens(tx *sql.Tx, userId int64) error {
+	_, err := tx.Exec(db.Rebind("UPDATE refresh_tokens SET revoked = TRUE WHERE userId = ?"), userId)
+	return err
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"RestAPI/db"
+	"RestAPI/utils"
+	"testing"
+	"time"
+)
+
+// issueTestRefreshToken issues a refresh token for the user and fails the test if that is not possible.
+func issueTestRefreshToken(t *testing.T, userId int64) string {
+	t.Helper()
+	token, err := IssueRefreshToken(userId)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+	return token
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		token := issueTestRefreshToken(t, userId)
+
+		seen := map[string]bool{token: true}
+		for i := 0; i < 3; i++ {
+			rotatedUserId, rotated, err := RotateRefreshToken(token)
+			if err != nil {
+				t.Fatalf("RotateRefreshToken #%d: %v", i+1, err)
+			}
+			if rotatedUserId != userId || rotated == "" || seen[rotated] {
+				t.Fatalf("RotateRefreshToken #%d = %d, %q, want the user %d and a token not issued before", i+1, rotatedUserId, rotated, userId)
+			}
+			seen[rotated] = true
+			token = rotated
+		}
+
+		var families int
+		err := db.DB.QueryRow("SELECT COUNT(DISTINCT familyId) FROM refresh_tokens").Scan(&families)
+		if err != nil || families != 1 {
+			t.Errorf("The rotated tokens belong to %d families, %v, want one", families, err)
+		}
+	})
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		stolen := issueTestRefreshToken(t, userId)
+		otherSession := issueTestRefreshToken(t, userId)
+		_, current, err := RotateRefreshToken(stolen)
+		if err != nil {
+			t.Fatalf("RotateRefreshToken: %v", err)
+		}
+
+		_, _, err = RotateRefreshToken(stolen)
+		if err == nil {
+			t.Fatal("RotateRefreshToken of a rotated token succeeded, want the reuse to be detected")
+		}
+		_, _, err = RotateRefreshToken(current)
+		if err == nil {
+			t.Error("RotateRefreshToken of the latest token after a reuse succeeded, want its family to be revoked")
+		}
+		_, _, err = RotateRefreshToken(otherSession)
+		if err != nil {
+			t.Errorf("RotateRefreshToken of another session: %v, want only the reused family to be revoked", err)
+		}
+	})
+}
+
+func TestExpiredRefreshToken(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		token := issueTestRefreshToken(t, userId)
+		_, err := db.DB.Exec(db.Rebind("UPDATE refresh_tokens SET expiresAt = ? WHERE tokenHash = ?"), time.Now().Add(-time.Second), utils.HashToken(token))
+		if err != nil {
+			t.Fatalf("Could not backdate the expiry: %v", err)
+		}
+
+		_, _, err = RotateRefreshToken(token)
+		if err == nil {
+			t.Error("RotateRefreshToken of an expired token succeeded, want an error")
+		}
+	})
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+		token := issueTestRefreshToken(t, userId)
+		_, rotated, err := RotateRefreshToken(token)
+		if err != nil {
+			t.Fatalf("RotateRefreshToken: %v", err)
+		}
+
+		err = RevokeRefreshToken(rotated)
+		if err != nil {
+			t.Fatalf("RevokeRefreshToken: %v", err)
+		}
+		_, _, err = RotateRefreshToken(rotated)
+		if err == nil {
+			t.Error("RotateRefreshToken after logging out succeeded, want an error")
+		}
+		err = RevokeRefreshToken(rotated)
+		if err != nil {
+			t.Errorf("RevokeRefreshToken of a revoked token: %v, want logging out twice to be harmless", err)
+		}
+		err = RevokeRefreshToken("unknown")
+		if err != nil {
+			t.Errorf("RevokeRefreshToken of an unknown token: %v", err)
+		}
+	})
+}
@@ -0,0 +1,344 @@
+package models
+
+import (
+	"RestAPI/config"
+	"RestAPI/db"
+	"RestAPI/utils"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount is the number of recovery codes a user gets when enabling two-factor authentication.
+const RecoveryCodeCount = 10
+
+// totpPeriod is the number of seconds a TOTP code is valid for. Codes of the previous and the next period are
+// accepted too, to allow for clocks that are slightly off.
+const totpPeriod = 30
+
+// Errors of the two-factor authentication.
+var (
+	// ErrTwoFactorEnabled is returned when enrolling or confirming while two-factor authentication is enabled.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnabled is returned when disabling two-factor authentication or regenerating recovery
+	// codes while it is not enabled.
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTwoFactorNotEnrolled is returned by ConfirmTwoFactor and GetTwoFactorEnrollment if the user has not
+	// enrolled, see EnrollTwoFactor.
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication has not been enrolled")
+	// ErrInvalidTwoFactorCode is returned if a TOTP or recovery code is wrong or was already used.
+	ErrInvalidTwoFactorCode = errors.New("the two-factor code is invalid")
+	// ErrTwoFactorRequired is returned by DisableTwoFactor if a role of the user requires two-factor
+	// authentication.
+	ErrTwoFactorRequired = errors.New("a role of the user requires two-factor authentication")
+)
+
+// TwoFactorIssuer returns the name authenticator apps show for the account, configured by TWO_FACTOR_ISSUER.
+func TwoFactorIssuer() string {
+	return config.String("TWO_FACTOR_ISSUER", "RestAPI")
+}
+
+// TwoFactorLoginTTL returns how long a user has to enter the two-factor code after the password, configured by
+// TWO_FACTOR_LOGIN_TTL.
+func TwoFactorLoginTTL() time.Duration {
+	return config.Duration("TWO_FACTOR_LOGIN_TTL", 5*time.Minute)
+}
+
+// EnrollTwoFactor creates a new TOTP secret for the user and returns its key, which holds the secret and the
+// otpauth:// URI to add it to an authenticator app. The secret only takes effect once the user confirmed it with
+// a code, see ConfirmTwoFactor; enrolling again replaces an unconfirmed secret.
+// It returns sql.ErrNoRows if the user does not exist and ErrTwoFactorEnabled if two-factor authentication is
+// already enabled.
+func EnrollTwoFactor(userId int64) (*otp.Key, error) {
+	user, err := GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: TwoFactorIssuer(), AccountName: user.Email, Period: totpPeriod})
+	if err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE users SET totpSecret = ?, totpLastStep = NULL WHERE id = ? AND totpEnabledAt IS NULL"
+	result, err := db.DB.Exec(db.Rebind(query), key.Secret(), userId)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+	return key, nil
+}
+
+// GetTwoFactorEnrollment returns the key of the unconfirmed secret of the user, e.g. to render it as a QR code.
+// It returns ErrTwoFactorNotEnrolled if the user has not enrolled and ErrTwoFactorEnabled if the secret was
+// already confirmed; a confirmed secret is never handed out again.
+func GetTwoFactorEnrollment(userId int64) (*otp.Key, error) {
+	var email string
+	var secret *string
+	var enabledAt *time.Time
+	query := "SELECT email, totpSecret, totpEnabledAt FROM users WHERE id = ?"
+	err := db.DB.QueryRow(db.Rebind(query), userId).Scan(&email, &secret, &enabledAt)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if secret == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	rawSecret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(*secret)
+	if err != nil {
+		return nil, err
+	}
+	return totp.Generate(totp.GenerateOpts{Issuer: TwoFactorIssuer(), AccountName: email, Period: totpPeriod, Secret: rawSecret})
+}
+
+// ConfirmTwoFactor enables two-factor authentication for the user if the code is valid for the secret created by
+// EnrollTwoFactor, which proves the authenticator app was set up. It returns the recovery codes of the user; only
+// their hashes are stored, so they can not be shown again.
+// It returns ErrTwoFactorNotEnrolled if the user has not enrolled, ErrTwoFactorEnabled if two-factor
+// authentication is already enabled and ErrInvalidTwoFactorCode if the code is wrong.
+func ConfirmTwoFactor(userId int64, code string) ([]string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret *string
+	var enabledAt *time.Time
+	err = tx.QueryRow(db.Rebind("SELECT totpSecret, totpEnabledAt FROM users WHERE id = ?"), userId).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if secret == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := matchTOTP(*secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	query := "UPDATE users SET totpEnabledAt = ?, totpLastStep = ? WHERE id = ? AND totpEnabledAt IS NULL"
+	result, err := tx.Exec(db.Rebind(query), time.Now().UTC(), step, userId)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// VerifyTwoFactor checks the second factor of a login: a TOTP code of the authenticator app of the user, or one
+// of their recovery codes. Every code is accepted only once; a used recovery code is gone for good.
+// It returns ErrTwoFactorNotEnabled if the user has not enabled two-factor authentication and
+// ErrInvalidTwoFactorCode if the code is wrong or was already used.
+func VerifyTwoFactor(userId int64, code string) error {
+	var secret *string
+	var enabledAt *time.Time
+	err := db.DB.QueryRow(db.Rebind("SELECT totpSecret, totpEnabledAt FROM users WHERE id = ?"), userId).Scan(&secret, &enabledAt)
+	if err != nil {
+		return err
+	}
+	if enabledAt == nil || secret == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := matchTOTP(*secret, code, time.Now()); ok {
+		// Moving the last step forward in one statement lets only one of concurrent logins use the code.
+		query := "UPDATE users SET totpLastStep = ? WHERE id = ? AND (totpLastStep IS NULL OR totpLastStep < ?)"
+		result, err := db.DB.Exec(db.Rebind(query), step, userId, step)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	query := "UPDATE recovery_codes SET usedAt = ? WHERE userId = ? AND codeHash = ? AND usedAt IS NULL"
+	result, err := db.DB.Exec(db.Rebind(query), time.Now().UTC(), userId, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	used, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// DisableTwoFactor turns two-factor authentication off for the user with the given roles after checking a
+// current code, see VerifyTwoFactor. The secret and the recovery codes are removed.
+// It returns ErrTwoFactorRequired if one of the roles requires two-factor authentication, and the errors of
+// VerifyTwoFactor.
+func DisableTwoFactor(userId int64, roles []string, code string) error {
+	required, err := RolesRequireTwoFactor(roles)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+	err = VerifyTwoFactor(userId, code)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET totpSecret = NULL, totpEnabledAt = NULL, totpLastStep = NULL WHERE id = ?"
+	_, err = tx.Exec(db.Rebind(query), userId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(db.Rebind("DELETE FROM recovery_codes WHERE userId = ?"), userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user with new ones after checking a current code,
+// see VerifyTwoFactor, and returns them, e.g. once most of them are used up. It returns the errors of
+// VerifyTwoFactor.
+func RegenerateRecoveryCodes(userId int64, code string) ([]string, error) {
+	err := VerifyTwoFactor(userId, code)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// IsTwoFactorEnabled reports whether the user with the given ID has enabled two-factor authentication.
+// It returns sql.ErrNoRows if the user does not exist.
+func IsTwoFactorEnabled(userId int64) (bool, error) {
+	var enabledAt *time.Time
+	err := db.DB.QueryRow(db.Rebind("SELECT totpEnabledAt FROM users WHERE id = ?"), userId).Scan(&enabledAt)
+	return enabledAt != nil, err
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of the user.
+func CountRecoveryCodes(userId int64) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM recovery_codes WHERE userId = ? AND usedAt IS NULL"
+	err := db.DB.QueryRow(db.Rebind(query), userId).Scan(&count)
+	return count, err
+}
+
+// TwoFactorEnrollmentRequired reports whether the user with the given ID and roles must enable two-factor
+// authentication before using the API, because one of the roles requires it and the user has not enabled it.
+func TwoFactorEnrollmentRequired(userId int64, roles []string) (bool, error) {
+	required, err := RolesRequireTwoFactor(roles)
+	if err != nil || !required {
+		return false, err
+	}
+	enabled, err := IsTwoFactorEnabled(userId)
+	return !enabled, err
+}
+
+// matchTOTP reports whether the code is the TOTP code of the secret for the time step of t, or the step before
+// or after it, and returns the matching step.
+func matchTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	step := t.Unix() / totpPeriod
+	for _, candidate := range []int64{step, step - 1, step + 1} {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(candidate*totpPeriod, 0), totp.ValidateOpts{Period: totpPeriod})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
+
+// replaceRecoveryCodes deletes the recovery codes of the user and creates RecoveryCodeCount new ones.
+func replaceRecoveryCodes(tx *sql.Tx, userId int64) ([]string, error) {
+	_, err := tx.Exec(db.Rebind("DELETE FROM recovery_codes WHERE userId = ?"), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(db.Rebind("INSERT INTO recovery_codes(userId, codeHash) VALUES (?, ?)"), userId, utils.HashToken(normalizeRecoveryCode(codes[i])))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random recovery code of 80 bits, written as four groups of four characters
+// like "ABCD-EFGH-IJKL-MNOP" so it can be typed in.
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	code := base32.StdEncoding.EncodeToString(buf)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeRecoveryCode removes the dashes and spaces users may type with a recovery code and upper-cases it.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"github.com/pquerna/otp/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// totpCode returns the TOTP code of the secret for the time step steps away from the one of at.
+func totpCode(t *testing.T, secret string, at time.Time, steps int) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at.Add(time.Duration(steps*totpPeriod)*time.Second), totp.ValidateOpts{Period: totpPeriod})
+	if err != nil {
+		t.Fatalf("GenerateCodeCustom: %v", err)
+	}
+	return code
+}
+
+func TestMatchTOTPSkewWindow(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1_900_000_000, 0)
+	step := now.Unix() / totpPeriod
+	tests := []struct {
+		name   string
+		code   string
+		wantOk bool
+	}{
+		{"current step", totpCode(t, secret, now, 0), true},
+		{"previous step", totpCode(t, secret, now, -1), true},
+		{"next step", totpCode(t, secret, now, 1), true},
+		{"two steps before", totpCode(t, secret, now, -2), false},
+		{"two steps after", totpCode(t, secret, now, 2), false},
+		{"surrounding spaces", " " + totpCode(t, secret, now, 0) + " ", true},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, ok := matchTOTP(secret, test.code, now)
+			if ok != test.wantOk {
+				t.Fatalf("matchTOTP = %d, %v, want %v", matched, ok, test.wantOk)
+			}
+			if ok && (matched < step-1 || matched > step+1) {
+				t.Errorf("matchTOTP matched the step %d, want a step of %d ± 1", matched, step)
+			}
+		})
+	}
+
+	matched, ok := matchTOTP(secret, totpCode(t, secret, now, -1), now)
+	if !ok || matched != step-1 {
+		t.Errorf("matchTOTP of the previous code = %d, %v, want the step %d", matched, ok, step-1)
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		userId := createTestUser(t, "ada@example.com")
+
+		_, err := ConfirmTwoFactor(userId, "123456")
+		if !errors.Is(err, ErrTwoFactorNotEnrolled) {
+			t.Errorf("ConfirmTwoFactor before enrolling: got %v, want ErrTwoFactorNotEnrolled", err)
+		}
+		key, err := EnrollTwoFactor(userId)
+		if err != nil {
+			t.Fatalf("EnrollTwoFactor: %v", err)
+		}
+		secret := key.Secret()
+		now := time.Now()
+		wrongCode := "000000"
+		if wrongCode == totpCode(t, secret, now, 0) {
+			wrongCode = "111111"
+		}
+		_, err = ConfirmTwoFactor(userId, wrongCode)
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("ConfirmTwoFactor with a wrong code: got %v, want ErrInvalidTwoFactorCode", err)
+		}
+
+		codes, err := ConfirmTwoFactor(userId, totpCode(t, secret, now, 0))
+		if err != nil || len(codes) != RecoveryCodeCount {
+			t.Fatalf("ConfirmTwoFactor = %d codes, %v, want %d recovery codes", len(codes), err, RecoveryCodeCount)
+		}
+		enabled, err := IsTwoFactorEnabled(userId)
+		if err != nil || !enabled {
+			t.Errorf("IsTwoFactorEnabled = %v, %v, want true", enabled, err)
+		}
+		_, err = EnrollTwoFactor(userId)
+		if !errors.Is(err, ErrTwoFactorEnabled) {
+			t.Errorf("EnrollTwoFactor while enabled: got %v, want ErrTwoFactorEnabled", err)
+		}
+
+		err = VerifyTwoFactor(userId, totpCode(t, secret, now, 0))
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("VerifyTwoFactor with the code used to confirm: got %v, want ErrInvalidTwoFactorCode", err)
+		}
+		err = VerifyTwoFactor(userId, totpCode(t, secret, now, 1))
+		if err != nil {
+			t.Errorf("VerifyTwoFactor with the code of the next step: %v", err)
+		}
+		err = VerifyTwoFactor(userId, totpCode(t, secret, now, -1))
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("VerifyTwoFactor with a code older than the last one used: got %v, want ErrInvalidTwoFactorCode", err)
+		}
+
+		typed := strings.ToLower(strings.ReplaceAll(codes[0], "-", " "))
+		err = VerifyTwoFactor(userId, typed)
+		if err != nil {
+			t.Errorf("VerifyTwoFactor with the recovery code %q: %v", typed, err)
+		}
+		err = VerifyTwoFactor(userId, codes[0])
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("VerifyTwoFactor with a used recovery code: got %v, want ErrInvalidTwoFactorCode", err)
+		}
+		count, err := CountRecoveryCodes(userId)
+		if err != nil || count != RecoveryCodeCount-1 {
+			t.Errorf("CountRecoveryCodes = %d, %v, want %d", count, err, RecoveryCodeCount-1)
+		}
+
+		err = DisableTwoFactor(userId, []string{"organizer"}, codes[1])
+		if err != nil {
+			t.Fatalf("DisableTwoFactor: %v", err)
+		}
+		enabled, err = IsTwoFactorEnabled(userId)
+		if err != nil || enabled {
+			t.Errorf("IsTwoFactorEnabled after disabling = %v, %v, want false", enabled, err)
+		}
+		count, err = CountRecoveryCodes(userId)
+		if err != nil || count != 0 {
+			t.Errorf("CountRecoveryCodes after disabling = %d, %v, want 0", count, err)
+		}
+		err = VerifyTwoFactor(userId, codes[2])
+		if !errors.Is(err, ErrTwoFactorNotEnabled) {
+			t.Errorf("VerifyTwoFactor after disabling: got %v, want ErrTwoFactorNotEnabled", err)
+		}
+	})
+}
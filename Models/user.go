@@ -1,56 +1,65 @@
 package models
 
 import (
+	"RestAPI/config"
 	"RestAPI/db"
 	"RestAPI/utils"
 	"errors"
+	"strings"
+	"sync"
 )
 
 // User represents a user with an ID, email, and password.
 // - ID: The unique identifier of the user.
-// - Email: The email address of the user. (required)
-// - Password: The password of the user. (required)
+// - Email: The email address of the user. (required, a valid email address)
+// - Password: The password of the user. (required, following the password policy, see InitValidation)
 type User struct {
 	ID       int64
-	Email    string `binding:"required"`
-	Password string `binding:"required"`
+	Email    string `binding:"required,email,max=254"`
+	Password string `binding:"required,password"`
 }
 
-// Save saves the user to the database by inserting a new row into the "users" table.
-// It hashes the user's password using the HashPassword function from the utils package.
-// The user's email and hashed password are then used as parameters for the database query.
-// If the query execution is successful, the last inserted row ID is retrieved and assigned to the user's ID.
-// If any error occurs during the preparation, execution, or retrieval of the query, it is returned.
-// Otherwise, nil is returned.
-func (u User) Save() error {
-	query := "INSERT INTO users(email, password) VALUES (?,?)"
-	stmt, err := db.DB.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+// ErrEmailTaken is returned by User.Save if another user already signed up with the same email.
+var ErrEmailTaken = errors.New("email already registered")
+
+// Save saves the user through the configured UserStore.
+// It hashes the user's password using the HashPassword function from the utils package,
+// so only the hash reaches the store.
+// The new user is given the role configured by DEFAULT_ROLE ("organizer" unless configured otherwise),
+// and additionally the admin role if the email equals ADMIN_EMAIL. The user and its roles are saved together.
+// If the email is already registered, in any case, ErrEmailTaken is returned.
+// If hashing or saving fails, the error is returned. Otherwise, nil is returned and the ID of the user is set.
+func (u *User) Save() error {
 	HashedPassword, err := utils.HashPassword(u.Password)
 	if err != nil {
 		return err
 	}
-	result, err := stmt.Exec(u.Email, HashedPassword)
-	if err != nil {
-		return err
-	}
-	userID, err := result.LastInsertId()
-	u.ID = userID
 
+	u.ID, err = Users.Create(u.Email, HashedPassword, newUserRoles(u.Email))
+	if db.IsConstraintViolation(err) {
+		return ErrEmailTaken
+	}
 	return err
 }
 
+// newUserRoles returns the roles of a new user with the email: the role configured by DEFAULT_ROLE, and the
+// admin role if the email equals ADMIN_EMAIL, ignoring case.
+func newUserRoles(email string) []string {
+	roles := []string{config.String("DEFAULT_ROLE", "organizer")}
+	if adminEmail := config.String("ADMIN_EMAIL", ""); adminEmail != "" && strings.EqualFold(adminEmail, email) {
+		roles = append(roles, "admin")
+	}
+	return roles
+}
+
 // ValidateCredentials validates the credentials of a User by checking if the email exists in the database
 // and if the password matches the hashed password stored in the database.
-// It performs a SELECT query to retrieve the user's ID and hashed password from the database based on the email.
+// It retrieves the user's ID and hashed password from the configured UserStore based on the email.
 // If the query fails or the password is invalid, it returns an error indicating that the credentials are invalid.
 // Otherwise, it returns nil to indicate that the credentials are valid.
 //
 // The ValidateCredentials method should be called on an instance of the User struct.
-// It relies on the global variable db.DB, which is an instance of *sql.DB that is used for database operations.
+// It relies on the global variable Users, the UserStore selected by InitStores.
 // It also uses the utils.CheckPasswordHash function to check if the provided password matches the hashed password.
 //
 // Example usage:
@@ -66,20 +75,24 @@ func (u User) Save() error {
 //	    context.JSON(http.StatusUnauthorized, gin.H{"message": "Could not authenticate user."})
 //	    return
 //	}
-//	token, err := utils.GenerateToken(user.Email, user.ID)
+//	roles, err := models.GetUserRoles(user.ID)
+//	if err != nil {
+//	    context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user."})
+//	    return
+//	}
+//	token, err := utils.GenerateToken(user.Email, user.ID, roles)
 //	if err != nil {
 //	    context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user."})
 //	    return
 //	}
 //	context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "token": token})
 func (u *User) ValidateCredentials() error {
-	query := "SELECT id, password FROM users WHERE email = ?"
-	row := db.DB.QueryRow(query, u.Email)
-
-	var retrievedPassword string
-	err := row.Scan(&u.ID, &retrievedPassword)
+	userID, retrievedPassword, err := Users.GetCredentials(u.Email)
 
 	if err != nil {
+		// Checking the password against a dummy hash takes as long as for an existing user, so the response
+		// time does not reveal whether the email is registered.
+		utils.CheckPasswordHash(u.Password, dummyPasswordHash())
 		return errors.New("Credentials invalid")
 	}
 
@@ -89,5 +102,78 @@ func (u *User) ValidateCredentials() error {
 		return errors.New("Credentials invalid")
 	}
 
+	u.ID = userID
 	return nil
 }
+
+// dummyPasswordHash returns the hash ValidateCredentials checks passwords of unknown emails against. It is
+// computed once, with the same cost as the hashes of real passwords.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not the password of any user")
+	return hash
+})
+
+// GetUserByID retrieves the user with the given ID from the configured UserStore.
+// Only the ID and email are loaded; the password hash never leaves the store through this function.
+// It returns an error if no user with that ID exists.
+func GetUserByID(id int64) (*User, error) {
+	return Users.GetByID(id)
+}
+
+// Create inserts a new row into the "users" table with the email and password hash and gives the user
+// the roles, skipping names that are not a role. The user and its roles are saved in one transaction.
+// It returns the ID of the new user.
+func (sqlUserStore) Create(email, passwordHash string, roles []string) (int64, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	query := "INSERT INTO users(email, password) VALUES (?,?) RETURNING id"
+	err = tx.QueryRow(db.Rebind(query), email, passwordHash).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+	INSERT INTO user_roles(userId, role)
+	SELECT CAST(? AS BIGINT), name FROM roles WHERE name = ?
+	ON CONFLICT DO NOTHING`
+	for _, role := range roles {
+		_, err = tx.Exec(db.Rebind(query), userID, role)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return userID, tx.Commit()
+}
+
+// GetCredentials performs a SELECT query to retrieve the ID and password hash of the user with the given email,
+// compared case-insensitively like the unique index on emails does.
+func (sqlUserStore) GetCredentials(email string) (int64, string, error) {
+	query := "SELECT id, password FROM users WHERE LOWER(email) = LOWER(?)"
+	row := db.DB.QueryRow(db.Rebind(query), email)
+
+	var id int64
+	var passwordHash string
+	err := row.Scan(&id, &passwordHash)
+	return id, passwordHash, err
+}
+
+// GetByID performs a SELECT query for the ID and email of the user with the given ID.
+// It returns sql.ErrNoRows if no user with that ID exists.
+func (sqlUserStore) GetByID(id int64) (*User, error) {
+	query := "SELECT id, email FROM users WHERE id = ?"
+	row := db.DB.QueryRow(db.Rebind(query), id)
+
+	var user User
+	err := row.Scan(&user.ID, &user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
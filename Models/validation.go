@@ -0,0 +1,103 @@
+package models
+
+import (
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+// The password policy checked by the "password" validation rule. The maximum is in bytes, since bcrypt
+// ignores everything after the first 72 bytes of a password.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// futureReason is the reason reported for the rule "future".
+const futureReason = "must be in the future"
+
+// InitValidation registers the custom validation rules used in the binding tags of the models with the
+// validator of gin, together with the reasons reported for them (see problems.RegisterReason):
+//   - "future" requires a time after the current time,
+//   - "password" requires a password following the password policy, see isStrongPassword,
+//   - "rrule" requires a recurrence rule accepted for recurring events, see parseRecurrenceRule.
+//
+// It also registers the reason of the built-in rule "timezone", which requires an IANA time zone name.
+// It must be called before the routes are served.
+func InitValidation() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("Could not initialize validation.")
+	}
+
+	rules := map[string]validator.Func{
+		"future":   isFuture,
+		"password": isStrongPassword,
+		"rrule":    isRecurrenceRule,
+	}
+	for tag, rule := range rules {
+		err := validate.RegisterValidation(tag, rule)
+		if err != nil {
+			panic("Could not register validation rule " + tag + ".")
+		}
+	}
+
+	problems.RegisterReason("future", func(validator.FieldError) string { return futureReason })
+	problems.RegisterReason("password", func(validator.FieldError) string {
+		return "must be " + strconv.Itoa(MinPasswordLength) + " to " + strconv.Itoa(MaxPasswordLength) +
+			" characters long and contain at least one letter and one digit"
+	})
+	problems.RegisterReason("timezone", func(validator.FieldError) string { return "must be an IANA time zone like Europe/Berlin" })
+	problems.RegisterReason("rrule", func(validator.FieldError) string {
+		return "must be an RRULE value like FREQ=WEEKLY;COUNT=10 recurring daily or less frequently"
+	})
+}
+
+// isFuture reports whether the field is a time after the current time.
+func isFuture(field validator.FieldLevel) bool {
+	t, ok := field.Field().Interface().(time.Time)
+	return ok && isFutureTime(t)
+}
+
+// isFutureTime reports whether the time is after the current time.
+func isFutureTime(t time.Time) bool {
+	return t.After(time.Now())
+}
+
+// ValidateEventStart checks the rule of events that is not part of their binding rules, since it depends on what
+// the event was before: a new event must start in the future, and so must an updated event whose start moves.
+// An event that started already can still be changed otherwise, e.g. to fix a typo or to add its end.
+// previous is the DateTime of the event, or of the occurrence, before the update, or the zero time for a new event.
+// It returns the error of the DateTime field if the rule is violated, and nil otherwise. A missing DateTime is
+// left to the "required" rule.
+func ValidateEventStart(event Event, previous time.Time) *problems.FieldError {
+	if event.DateTime.IsZero() || event.DateTime.Equal(previous) || isFutureTime(event.DateTime) {
+		return nil
+	}
+	return &problems.FieldError{Field: "DateTime", Rule: "future", Reason: futureReason}
+}
+
+// isStrongPassword reports whether the field is a password of MinPasswordLength characters up to
+// MaxPasswordLength bytes that contains at least one letter and one digit.
+func isStrongPassword(field validator.FieldLevel) bool {
+	password := field.Field().String()
+	if len([]rune(password)) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	return hasLetter && hasDigit
+}
+
+// isRecurrenceRule reports whether the field is a recurrence rule accepted by parseRecurrenceRule.
+func isRecurrenceRule(field validator.FieldLevel) bool {
+	_, err := parseRecurrenceRule(field.Field().String(), time.Now())
+	return err == nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"testing"
+	"time"
+)
+
+func TestValidateEventStart(t *testing.T) {
+	past := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	future := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name      string
+		start     time.Time
+		previous  time.Time
+		wantError bool
+	}{
+		{"new event in the future", future, time.Time{}, false},
+		{"new event in the past", past, time.Time{}, true},
+		{"new event without a start", time.Time{}, time.Time{}, false},
+		{"started event keeps its start", past, past, false},
+		{"started event keeps its start in another zone", past.In(time.FixedZone("UTC+2", 2*60*60)), past, false},
+		{"started event moves to the future", future, past, false},
+		{"event moves to the past", past, future, true},
+		{"started event moves within the past", past.Add(-time.Hour), past, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fieldError := ValidateEventStart(Event{DateTime: test.start}, test.previous)
+			if (fieldError != nil) != test.wantError {
+				t.Fatalf("ValidateEventStart = %+v, want an error: %v", fieldError, test.wantError)
+			}
+			if fieldError != nil && (fieldError.Field != "DateTime" || fieldError.Rule != "future") {
+				t.Errorf("ValidateEventStart = %+v, want the rule future of DateTime", fieldError)
+			}
+		})
+	}
+}
+
+func TestEventBindingRulesAllowStartedEvents(t *testing.T) {
+	InitValidation()
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	end := start.Add(2 * time.Hour)
+	event := Event{Name: "Go Meetup", Description: "Talks about Go", Location: "Berlin", DateTime: start, EndDateTime: &end}
+
+	err := binding.Validator.ValidateStruct(&event)
+	if err != nil {
+		t.Errorf("ValidateStruct of an event that started: %v, want no error", err)
+	}
+
+	end = start.Add(-time.Minute)
+	err = binding.Validator.ValidateStruct(&event)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 1 ||
+		validationErrors[0].Field() != "EndDateTime" || validationErrors[0].Tag() != "gtfield" {
+		t.Errorf("ValidateStruct of an event ending before it starts: %v, want EndDateTime to fail gtfield", err)
+	}
+}
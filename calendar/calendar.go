@@ -0,0 +1,231 @@
+package calendar
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of iCalendar documents.
+const ContentType = "text/calendar; charset=utf-8"
+
+// The values of Event.Status, as defined for VEVENT components by RFC 5545.
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineLength is the maximum length of a content line in octets, excluding the line break.
+const maxLineLength = 75
+
+// Event is a VEVENT component of a calendar.
+// UID identifies the event across updates, so calendar applications replace their copy instead of adding
+// another one. End and Status are optional. A recurring event has an RRULE value as RecurrenceRule, e.g.
+// FREQ=WEEKLY;COUNT=10, and lists the starts of excluded occurrences as ExceptionDates.
+// TimeZone is the IANA time zone of the event. Its times are written in that zone, so calendar applications
+// expand the recurrence in local time; without it, or for UTC, they are written in UTC.
+type Event struct {
+	UID            string
+	Summary        string
+	Description    string
+	Location       string
+	Start          time.Time
+	End            *time.Time
+	Status         string
+	RecurrenceRule string
+	ExceptionDates []time.Time
+	TimeZone       string
+}
+
+// Calendar is an RFC 5545 iCalendar object holding events.
+// Name is shown by calendar applications for subscribed calendars, and RefreshInterval suggests how often
+// they should poll for updates; both are optional.
+type Calendar struct {
+	Name            string
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Encode renders the calendar as an iCalendar document.
+// Times are written in UTC or with the TZID of the event's time zone, text values are escaped and lines longer
+// than 75 octets are folded. Every time zone used gets a VTIMEZONE component with its offsets, named by its
+// IANA name, see writeTimeZone.
+// DTSTAMP is set to the current time, since the document is generated on every request.
+func (c Calendar) Encode() []byte {
+	var builder strings.Builder
+	stamp := formatTime(time.Now())
+
+	writeLine(&builder, "BEGIN:VCALENDAR")
+	writeLine(&builder, "VERSION:2.0")
+	writeLine(&builder, "PRODID:-//RestAPI//Events//EN")
+	writeLine(&builder, "CALSCALE:GREGORIAN")
+	writeLine(&builder, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&builder, "NAME:"+escapeText(c.Name))
+		writeLine(&builder, "X-WR-CALNAME:"+escapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		writeLine(&builder, "REFRESH-INTERVAL;VALUE=DURATION:"+formatDuration(c.RefreshInterval))
+		writeLine(&builder, "X-PUBLISHED-TTL:"+formatDuration(c.RefreshInterval))
+	}
+	c.writeTimeZones(&builder)
+
+	for _, event := range c.Events {
+		writeLine(&builder, "BEGIN:VEVENT")
+		writeLine(&builder, "UID:"+escapeText(event.UID))
+		writeLine(&builder, "DTSTAMP:"+stamp)
+		writeLine(&builder, "DTSTART"+formatZonedTimes(event.TimeZone, event.Start))
+		if event.End != nil {
+			writeLine(&builder, "DTEND"+formatZonedTimes(event.TimeZone, *event.End))
+		}
+		if event.RecurrenceRule != "" {
+			writeLine(&builder, "RRULE:"+event.RecurrenceRule)
+		}
+		if len(event.ExceptionDates) > 0 {
+			writeLine(&builder, "EXDATE"+formatZonedTimes(event.TimeZone, event.ExceptionDates...))
+		}
+		writeLine(&builder, "SUMMARY:"+escapeText(event.Summary))
+		if event.Description != "" {
+			writeLine(&builder, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.Location != "" {
+			writeLine(&builder, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.Status != "" {
+			writeLine(&builder, "STATUS:"+event.Status)
+		}
+		writeLine(&builder, "END:VEVENT")
+	}
+
+	writeLine(&builder, "END:VCALENDAR")
+	return []byte(builder.String())
+}
+
+// writeTimeZones writes a VTIMEZONE component for every time zone the events are written in, sorted by name,
+// covering the times of its events.
+func (c Calendar) writeTimeZones(builder *strings.Builder) {
+	type span struct {
+		location    *time.Location
+		first, last time.Time
+	}
+	spans := map[string]*span{}
+	for _, event := range c.Events {
+		location := eventLocation(event.TimeZone)
+		if location == nil {
+			continue
+		}
+		times := append([]time.Time{event.Start}, event.ExceptionDates...)
+		if event.End != nil {
+			times = append(times, *event.End)
+		}
+		zone, ok := spans[location.String()]
+		if !ok {
+			zone = &span{location: location, first: event.Start, last: event.Start}
+			spans[location.String()] = zone
+		}
+		for _, t := range times {
+			if t.Before(zone.first) {
+				zone.first = t
+			}
+			if t.After(zone.last) {
+				zone.last = t
+			}
+		}
+	}
+
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		zone := spans[name]
+		writeTimeZone(builder, zone.location, zone.first, zone.last)
+	}
+}
+
+// eventLocation returns the location the times of an event in the zone are written in, or nil if they are
+// written in UTC because the zone is empty, UTC or unknown.
+func eventLocation(zone string) *time.Location {
+	if zone == "" || zone == "UTC" {
+		return nil
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// formatTime formats t as a UTC DATE-TIME value.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatZonedTimes formats the parameters and value of a property holding the DATE-TIME values, like DTSTART:
+// ;TZID=zone:local times if the zone is an IANA time zone other than UTC, or :UTC times otherwise.
+func formatZonedTimes(zone string, times ...time.Time) string {
+	location := eventLocation(zone)
+	if location == nil {
+		values := make([]string, len(times))
+		for i, t := range times {
+			values[i] = formatTime(t)
+		}
+		return ":" + strings.Join(values, ",")
+	}
+
+	values := make([]string, len(times))
+	for i, t := range times {
+		values[i] = t.In(location).Format("20060102T150405")
+	}
+	return ";TZID=" + zone + ":" + strings.Join(values, ",")
+}
+
+// formatDuration formats d as a DURATION value with a precision of seconds, e.g. PT1H30M.
+func formatDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	value := "PT"
+	for _, unit := range []struct {
+		seconds int64
+		suffix  string
+	}{{3600, "H"}, {60, "M"}, {1, "S"}} {
+		if seconds >= unit.seconds {
+			value += strconv.FormatInt(seconds/unit.seconds, 10) + unit.suffix
+			seconds %= unit.seconds
+		}
+	}
+	if value == "PT" {
+		value += "0S"
+	}
+	return value
+}
+
+// escapeText escapes a TEXT value: backslashes, semicolons and commas are escaped with a backslash and
+// line breaks are written as \n.
+func escapeText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return replacer.Replace(text)
+}
+
+// writeLine writes a content line terminated by CRLF, folding it into several lines of at most 75 octets.
+// Continuation lines start with a space. Lines are only folded between characters, never within a
+// multi-byte UTF-8 sequence.
+func writeLine(builder *strings.Builder, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		builder.WriteString(line[:cut])
+		builder.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length.
+		limit = maxLineLength - 1
+	}
+	builder.WriteString(line)
+	builder.WriteString("\r\n")
+}
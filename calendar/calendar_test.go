@@ -0,0 +1,119 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// timeZones returns the VTIMEZONE components of the document, by TZID.
+func timeZones(document string) map[string]string {
+	zones := map[string]string{}
+	for _, part := range strings.Split(document, "BEGIN:VTIMEZONE\r\n")[1:] {
+		component, _, _ := strings.Cut(part, "END:VTIMEZONE\r\n")
+		id, _, _ := strings.Cut(strings.TrimPrefix(component, "TZID:"), "\r\n")
+		zones[id] = component
+	}
+	return zones
+}
+
+func TestEncodeTimeZones(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	start := time.Date(2030, 1, 7, 18, 0, 0, 0, berlin)
+	end := start.Add(2 * time.Hour)
+	document := string(Calendar{Events: []Event{
+		{UID: "1", Start: start, End: &end, RecurrenceRule: "FREQ=WEEKLY", TimeZone: "Europe/Berlin"},
+		{UID: "2", Start: start.AddDate(0, 6, 0), TimeZone: "Europe/Berlin"},
+		{UID: "3", Start: start, TimeZone: "UTC"},
+		{UID: "4", Start: start},
+	}}.Encode())
+
+	zones := timeZones(document)
+	if len(zones) != 1 {
+		t.Fatalf("The document has VTIMEZONE components for %d zones, want one for Europe/Berlin:\n%s", len(zones), document)
+	}
+	berlinZone := zones["Europe/Berlin"]
+	for _, want := range []string{
+		"BEGIN:DAYLIGHT\r\nDTSTART:20300331T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nEND:DAYLIGHT",
+		"BEGIN:STANDARD\r\nDTSTART:20291028T030000\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD",
+	} {
+		if !strings.Contains(berlinZone, want) {
+			t.Errorf("The VTIMEZONE of Europe/Berlin lacks\n%s\nin\n%s", want, berlinZone)
+		}
+	}
+	if strings.Index(document, "BEGIN:VTIMEZONE") > strings.Index(document, "BEGIN:VEVENT") {
+		t.Error("The VTIMEZONE follows the events, want it before them")
+	}
+	if !strings.Contains(document, "DTSTART;TZID=Europe/Berlin:20300107T180000\r\n") || !strings.Contains(document, "DTSTART:20300107T170000Z\r\n") {
+		t.Errorf("The events are not written in their time zones:\n%s", document)
+	}
+}
+
+func TestWriteTimeZone(t *testing.T) {
+	tests := []struct {
+		zone     string
+		from     time.Time
+		want     []string
+		wantNone []string
+	}{
+		{
+			// Moscow kept daylight saving time in 2011 and went back to standard time in 2014 for good.
+			zone: "Europe/Moscow",
+			from: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"BEGIN:STANDARD\r\nDTSTART:20141026T020000\r\nTZOFFSETFROM:+0400\r\nTZOFFSETTO:+0300\r\nTZNAME:MSK\r\nEND:STANDARD",
+			},
+			wantNone: []string{"RRULE", "DAYLIGHT"},
+		},
+		{
+			zone:     "Asia/Tokyo",
+			from:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:     []string{"BEGIN:STANDARD\r\n", "TZOFFSETTO:+0900\r\nTZNAME:JST\r\n"},
+			wantNone: []string{"RRULE", "DAYLIGHT"},
+		},
+		{
+			// New York moved the start of daylight saving time from April to March and the end from October to
+			// November in 2007.
+			zone: "America/New_York",
+			from: time.Date(2005, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"DTSTART:20050403T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T070000Z\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400",
+				"DTSTART:20051030T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20061029T060000Z\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500",
+				"DTSTART:20070311T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400",
+				"DTSTART:20071104T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			location, err := time.LoadLocation(test.zone)
+			if err != nil {
+				t.Fatalf("LoadLocation: %v", err)
+			}
+			var builder strings.Builder
+			writeTimeZone(&builder, location, test.from, test.from)
+			component := builder.String()
+			for _, want := range test.want {
+				if !strings.Contains(component, want) {
+					t.Errorf("The VTIMEZONE lacks\n%s\nin\n%s", want, component)
+				}
+			}
+			for _, unwanted := range test.wantNone {
+				if strings.Contains(component, unwanted) {
+					t.Errorf("The VTIMEZONE holds %s:\n%s", unwanted, component)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	for offset, want := range map[int]string{0: "+0000", 3600: "+0100", -12600: "-0330", 20700: "+0545", 4*3600 + 61: "+040101"} {
+		if got := formatOffset(offset); got != want {
+			t.Errorf("formatOffset(%d) = %q, want %q", offset, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCalendar is returned by Decode if the input is not an iCalendar document.
+var ErrInvalidCalendar = errors.New("invalid iCalendar document")
+
+// Property is a property of a calendar component, e.g. DTSTART;TZID=Europe/Berlin:20300101T090000.
+// Parameter names are upper case; the value is kept as written, see Text and Time to interpret it.
+type Property struct {
+	Params map[string]string
+	Value  string
+}
+
+// Component is a VEVENT read by Decode. Line is the line of the input the component starts on, and
+// Properties holds the first occurrence of every property by its upper case name. Properties that may
+// occur more than once, like EXDATE, are listed in full by All.
+type Component struct {
+	Line       int
+	Properties map[string]Property
+	All        map[string][]Property
+}
+
+// Decode reads the VEVENT components of an iCalendar document. Folded lines are unfolded; components
+// nested in a VEVENT, like VALARM, are skipped. Properties are not interpreted, so a component with an
+// invalid value is still returned and can be reported by the caller.
+// It returns ErrInvalidCalendar if the input does not start with BEGIN:VCALENDAR.
+func Decode(r io.Reader) ([]Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0].text, "BEGIN:VCALENDAR") {
+		return nil, ErrInvalidCalendar
+	}
+
+	var components []Component
+	var current *Component
+	depth := 0
+	for _, line := range lines {
+		name, property := parseLine(line.text)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(property.Value, "VEVENT") && current == nil:
+			current = &Component{Line: line.number, Properties: map[string]Property{}, All: map[string][]Property{}}
+		case current == nil:
+		case name == "BEGIN":
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END" && strings.EqualFold(property.Value, "VEVENT"):
+			components = append(components, *current)
+			current = nil
+		case depth == 0:
+			if _, seen := current.Properties[name]; !seen {
+				current.Properties[name] = property
+			}
+			current.All[name] = append(current.All[name], property)
+		}
+	}
+
+	return components, nil
+}
+
+// numberedLine is an unfolded content line with the number of the line it starts on.
+type numberedLine struct {
+	number int
+	text   string
+}
+
+// unfold reads the content lines of r, joining continuation lines, which start with a space or tab,
+// to the line before them. Empty lines are skipped.
+func unfold(r io.Reader) ([]numberedLine, error) {
+	var lines []numberedLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		if text != "" {
+			lines = append(lines, numberedLine{number: number, text: text})
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its upper case name and the property.
+// Quoted parameter values may contain the separators ; : and ,.
+func parseLine(line string) (string, Property) {
+	property := Property{Params: map[string]string{}}
+
+	inQuotes := false
+	end := len(line)
+	var parts []string
+	start := 0
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if inQuotes {
+			continue
+		}
+		if r == ';' {
+			parts = append(parts, line[start:i])
+			start = i + 1
+		}
+		if r == ':' {
+			end = i
+			break
+		}
+	}
+	parts = append(parts, line[start:end])
+	if end < len(line) {
+		property.Value = line[end+1:]
+	}
+
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		property.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return strings.ToUpper(parts[0]), property
+}
+
+// Text returns the value of a TEXT property with its escapes resolved.
+func (p Property) Text() string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(p.Value)
+}
+
+// Times returns the values of a property holding a comma separated list of DATE-TIME or DATE values,
+// like EXDATE, interpreted like Time.
+func (p Property) Times() ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range strings.Split(p.Value, ",") {
+		t, err := Property{Params: p.Params, Value: value}.Time()
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// Time returns the value of a DATE-TIME or DATE property, like DTSTART.
+// A time in UTC (ending in Z) and a time with a TZID parameter naming an IANA time zone are exact; a floating
+// time without either is interpreted as UTC. A DATE value (VALUE=DATE or just eight digits) is midnight UTC of that day.
+// It returns an error if the value can not be parsed or the time zone is unknown.
+func (p Property) Time() (time.Time, error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == len("20060102") {
+		return time.Parse("20060102", p.Value)
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		return time.Parse("20060102T150405Z", p.Value)
+	}
+
+	location := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		var err error
+		location, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.ParseInLocation("20060102T150405", p.Value, location)
+}
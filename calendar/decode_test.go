@@ -0,0 +1,152 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	document := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"SUMMARY:Go Meetup",
+		"DESCRIPTION:Talks about Go\\, generics and",
+		"  the new iterators",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"LOCATION:Berlin",
+		"EXDATE:20300108T100000Z",
+		"EXDATE:20300115T100000Z",
+		"END:VEVENT",
+		"",
+		"BEGIN:VEVENT",
+		"SUMMARY:Rust",
+		"\t Meetup",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	components, err := Decode(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(components) != 2 {
+		t.Fatalf("Decode returned %d components, want 2", len(components))
+	}
+
+	first := components[0]
+	if first.Line != 3 {
+		t.Errorf("The first component starts on line %d, want 3", first.Line)
+	}
+	if got := first.Properties["DESCRIPTION"].Text(); got != "Talks about Go, generics and the new iterators" {
+		t.Errorf("DESCRIPTION = %q, want the unfolded description of the event, not of its VALARM", got)
+	}
+	if _, ok := first.Properties["ACTION"]; ok {
+		t.Error("The ACTION of the VALARM became a property of the event")
+	}
+	if first.Properties["LOCATION"].Value != "Berlin" {
+		t.Errorf("LOCATION = %q, want Berlin after the VALARM", first.Properties["LOCATION"].Value)
+	}
+	if len(first.All["EXDATE"]) != 2 || first.Properties["EXDATE"].Value != "20300108T100000Z" {
+		t.Errorf("EXDATE = %+v, want both, the first one in Properties", first.All["EXDATE"])
+	}
+
+	second := components[1]
+	if second.Line != 16 || second.Properties["SUMMARY"].Value != "Rust Meetup" {
+		t.Errorf("The second component = %+v, want the unfolded SUMMARY on line 16", second)
+	}
+}
+
+func TestDecodeRejectsOtherDocuments(t *testing.T) {
+	for _, document := range []string{"", "Name,Location\nGo Meetup,Berlin\n", "BEGIN:VEVENT\nEND:VEVENT\n"} {
+		_, err := Decode(strings.NewReader(document))
+		if !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("Decode(%q): got %v, want ErrInvalidCalendar", document, err)
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantName   string
+		wantParams map[string]string
+		wantValue  string
+	}{
+		{"SUMMARY:Go Meetup", "SUMMARY", map[string]string{}, "Go Meetup"},
+		{"dtstart;tzid=Europe/Berlin:20300101T090000", "DTSTART", map[string]string{"TZID": "Europe/Berlin"}, "20300101T090000"},
+		{"LOCATION:Room 1: Main Hall", "LOCATION", map[string]string{}, "Room 1: Main Hall"},
+		{`DTSTART;X-A="a;b:c,d";TZID=Europe/Berlin:20300101T090000`, "DTSTART",
+			map[string]string{"X-A": "a;b:c,d", "TZID": "Europe/Berlin"}, "20300101T090000"},
+		{`ATTENDEE;CN="Doe; Jane";ROLE=CHAIR:mailto:jane@example.com`, "ATTENDEE",
+			map[string]string{"CN": "Doe; Jane", "ROLE": "CHAIR"}, "mailto:jane@example.com"},
+		{"END", "END", map[string]string{}, ""},
+	}
+	for _, test := range tests {
+		name, property := parseLine(test.line)
+		if name != test.wantName || property.Value != test.wantValue || len(property.Params) != len(test.wantParams) {
+			t.Errorf("parseLine(%q) = %q, %+v, want %q, %v and %q", test.line, name, property, test.wantName, test.wantParams, test.wantValue)
+			continue
+		}
+		for key, value := range test.wantParams {
+			if property.Params[key] != value {
+				t.Errorf("parseLine(%q) has the parameter %s=%q, want %q", test.line, key, property.Params[key], value)
+			}
+		}
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	tests := []struct {
+		name     string
+		property Property
+		want     time.Time
+		wantErr  bool
+	}{
+		{"UTC", Property{Value: "20300101T090000Z"}, time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC), false},
+		{"TZID", Property{Params: map[string]string{"TZID": "Europe/Berlin"}, Value: "20300101T090000"},
+			time.Date(2030, 1, 1, 9, 0, 0, 0, berlin), false},
+		{"floating", Property{Value: "20300101T090000"}, time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC), false},
+		{"DATE", Property{Params: map[string]string{"VALUE": "DATE"}, Value: "20300101"}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"DATE without VALUE", Property{Value: "20300101"}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"unknown TZID", Property{Params: map[string]string{"TZID": "Mars/Olympus"}, Value: "20300101T090000"}, time.Time{}, true},
+		{"invalid", Property{Value: "tomorrow"}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.property.Time()
+			if (err != nil) != test.wantErr || !got.Equal(test.want) {
+				t.Errorf("Time = %v, %v, want %v and an error: %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPropertyTimes(t *testing.T) {
+	property := Property{Params: map[string]string{"TZID": "Europe/Berlin"}, Value: "20300108T100000,20300115T100000"}
+	times, err := property.Times()
+	if err != nil || len(times) != 2 || times[1].Location().String() != "Europe/Berlin" || times[1].Day() != 15 {
+		t.Errorf("Times = %v, %v, want both dates in Europe/Berlin", times, err)
+	}
+
+	_, err = Property{Value: "20300108T100000Z,never"}.Times()
+	if err == nil {
+		t.Error("Times with an invalid value succeeded, want an error")
+	}
+}
+
+func TestPropertyText(t *testing.T) {
+	property := Property{Value: `Talks\, food\; drinks\nand a \\ backslash`}
+	if got := property.Text(); got != "Talks, food; drinks\nand a \\ backslash" {
+		t.Errorf("Text = %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeZoneHorizon is how long after the last time of its events a VTIMEZONE covers the transitions of the zone.
+// It spans more than a year, so the yearly daylight saving time rules in use then are recognized.
+const timeZoneHorizon = 2 * 366 * 24 * time.Hour
+
+// transition is a change of the UTC offset or the abbreviation of a time zone, e.g. the start of daylight
+// saving time.
+type transition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+// kind returns what a transition changes to and from; transitions of one kind form a single observance.
+func (t transition) kind() string {
+	return fmt.Sprint(t.dst, t.offsetFrom, t.offsetTo, t.name)
+}
+
+// local returns the local time of the transition in the offset before it, as VTIMEZONE observances give it.
+func (t transition) local() time.Time {
+	return t.at.In(time.FixedZone("", t.offsetFrom))
+}
+
+// yearlyRule returns the BYMONTH and BYDAY parts of a yearly RRULE the transition follows, e.g.
+// BYMONTH=3;BYDAY=-1SU for the last Sunday of March, together with its local time of day.
+func (t transition) yearlyRule() string {
+	local := t.local()
+	daysInMonth := time.Date(local.Year(), local.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	week := (local.Day()-1)/7 + 1
+	if local.Day()+7 > daysInMonth {
+		week = -1
+	}
+	weekday := strings.ToUpper(local.Weekday().String()[:2])
+	return fmt.Sprintf("BYMONTH=%d;BYDAY=%d%s %s", local.Month(), week, weekday, local.Format("150405"))
+}
+
+// zoneTransitions returns the transitions of the location from the one that started the offset in effect at
+// from until the last one before until. A location without any transition yields a single one at the zero time
+// from and to its only offset.
+func zoneTransitions(location *time.Location, from, until time.Time) []transition {
+	start, _ := from.In(location).ZoneBounds()
+	if start.IsZero() {
+		name, offset := from.In(location).Zone()
+		return []transition{{offsetFrom: offset, offsetTo: offset, name: name, dst: from.In(location).IsDST()}}
+	}
+
+	var transitions []transition
+	for !start.IsZero() && start.Before(until) {
+		_, offsetFrom := start.Add(-time.Second).In(location).Zone()
+		current := start.In(location)
+		name, offsetTo := current.Zone()
+		transitions = append(transitions, transition{at: start, offsetFrom: offsetFrom, offsetTo: offsetTo, name: name, dst: current.IsDST()})
+		_, start = current.ZoneBounds()
+	}
+	return transitions
+}
+
+// writeTimeZone writes the VTIMEZONE component RFC 5545 requires for every TZID a document uses, for the
+// IANA time zone of the location, covering the times from from until at least timeZoneHorizon after until.
+// Transitions that recur every year on the same weekday of a month are written as one observance with a yearly
+// RRULE; the ones still in use at the end recur without end, so recurring events are expanded with the right
+// offset later, too. The transitions of two more years are looked at to tell whether the last ones recur.
+func writeTimeZone(builder *strings.Builder, location *time.Location, from, until time.Time) {
+	writeLine(builder, "BEGIN:VTIMEZONE")
+	writeLine(builder, "TZID:"+location.String())
+
+	year := 366 * 24 * time.Hour
+	lastYear := until.Add(timeZoneHorizon + year)
+	transitions := zoneTransitions(location, from, lastYear.Add(year))
+	byKind := map[string][]transition{}
+	var kinds []string
+	for _, t := range transitions {
+		if _, seen := byKind[t.kind()]; !seen {
+			kinds = append(kinds, t.kind())
+		}
+		byKind[t.kind()] = append(byKind[t.kind()], t)
+	}
+
+	type observance struct {
+		first transition
+		rule  string
+		last  transition
+	}
+	var observances []observance
+	for _, kind := range kinds {
+		var runs [][]transition
+		for _, t := range byKind[kind] {
+			if n := len(runs); n > 0 {
+				previous := runs[n-1][len(runs[n-1])-1]
+				if previous.yearlyRule() == t.yearlyRule() && previous.local().Year()+1 == t.local().Year() {
+					runs[n-1] = append(runs[n-1], t)
+					continue
+				}
+			}
+			runs = append(runs, []transition{t})
+		}
+
+		for i, run := range runs {
+			first, last := run[0], run[len(run)-1]
+			switch {
+			case first.at.After(lastYear):
+				// The run starts too late to tell whether it recurs.
+			case len(run) == 1:
+				observances = append(observances, observance{first: first})
+			case i == len(runs)-1 && last.at.After(lastYear):
+				observances = append(observances, observance{first: first, rule: first.yearlyRule()})
+			default:
+				observances = append(observances, observance{first: first, rule: first.yearlyRule(), last: last})
+			}
+		}
+	}
+	sort.SliceStable(observances, func(i, j int) bool { return observances[i].first.at.Before(observances[j].first.at) })
+
+	for _, o := range observances {
+		component := "STANDARD"
+		if o.first.dst {
+			component = "DAYLIGHT"
+		}
+		writeLine(builder, "BEGIN:"+component)
+		if o.first.at.IsZero() {
+			writeLine(builder, "DTSTART:19700101T000000")
+		} else {
+			writeLine(builder, "DTSTART:"+o.first.local().Format("20060102T150405"))
+		}
+		if o.rule != "" {
+			rule, _, _ := strings.Cut(o.rule, " ")
+			if o.last.at.IsZero() {
+				writeLine(builder, "RRULE:FREQ=YEARLY;"+rule)
+			} else {
+				writeLine(builder, "RRULE:FREQ=YEARLY;"+rule+";UNTIL="+formatTime(o.last.at))
+			}
+		}
+		writeLine(builder, "TZOFFSETFROM:"+formatOffset(o.first.offsetFrom))
+		writeLine(builder, "TZOFFSETTO:"+formatOffset(o.first.offsetTo))
+		writeLine(builder, "TZNAME:"+escapeText(o.first.name))
+		writeLine(builder, "END:"+component)
+	}
+
+	writeLine(builder, "END:VTIMEZONE")
+}
+
+// formatOffset formats a UTC offset in seconds as a UTC-OFFSET value, e.g. +0100 or -0330.
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		value += fmt.Sprintf("%02d", offset%60)
+	}
+	return value
+}
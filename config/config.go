@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// String returns the value of the environment variable with the given name.
+// If the variable is not set or empty, the fallback value is returned.
+func String(name, fallback string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// Bool returns the value of the environment variable with the given name parsed as a boolean.
+// Accepted values are the ones understood by strconv.ParseBool, e.g. "1", "true", "0" or "false".
+// If the variable is not set or can not be parsed, the fallback value is returned.
+func Bool(name string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Int returns the value of the environment variable with the given name parsed as an integer.
+// If the variable is not set or can not be parsed, the fallback value is returned.
+func Int(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Duration returns the value of the environment variable with the given name parsed with time.ParseDuration,
+// e.g. "15m" or "720h". If the variable is not set or can not be parsed, the fallback value is returned.
+func Duration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
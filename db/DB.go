@@ -1,18 +1,56 @@
 package db
 
 import (
+	"RestAPI/config"
 	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"log"
+	"strconv"
+	"strings"
 )
 
-// DB is a global variable of type *sql.DB used for connecting and interacting with a SQLite database. It is initialized and configured in the InitDB() function. It is used in various functions for executing SQL queries, retrieving and saving data to the database.
+// FullTextSearch reports whether the events search index is available. With SQLite it is false if the
+// binary was built without the sqlite_fts5 build tag; PostgreSQL always has full-text search.
+var FullTextSearch bool
+
+// DB is a global variable of type *sql.DB used for connecting and interacting with the database. It is initialized and configured in the InitDB() function. It is used in various functions for executing SQL queries, retrieving and saving data to the database.
 var DB *sql.DB
 
+// Driver names the database/sql driver DB was opened with, either SQLite or Postgres.
+var Driver string
+
+// The supported values of Driver.
+const (
+	SQLite   = "sqlite3"
+	Postgres = "postgres"
+)
+
 // InitDB initializes the database connection and sets the maximum number of open and idle connections.
-// It creates the necessary tables by calling the createTables function.
+// The database is selected by DATABASE_URL: a postgres:// or postgresql:// URL connects to PostgreSQL,
+// anything else is used as the path of a SQLite database file, "api.db" by default.
+// SQLite transactions are started with BEGIN IMMEDIATE, so a transaction that reads and then writes (e.g.
+// checking the free seats of an event before registering) holds the write lock from the start and can not
+// be interleaved with another one. Concurrent writers wait up to five seconds for the lock instead of failing.
+// SQLite enforces foreign keys only when asked to, so they are enabled for every connection.
+// It creates the schema_migrations table that tracks the applied migrations; the schema itself is
+// created and updated by MigrateUp.
 func InitDB() {
+	dsn := config.String("DATABASE_URL", "api.db")
+
 	var err error
-	DB, err = sql.Open("sqlite3", "api.db")
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		Driver = Postgres
+		DB, err = sql.Open(Postgres, dsn)
+	} else {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		Driver = SQLite
+		DB, err = sql.Open(SQLite, dsn+separator+"_txlock=immediate&_busy_timeout=5000&_foreign_keys=on")
+	}
 
 	if err != nil {
 		panic("Could not connect to database.")
@@ -21,50 +59,119 @@ func InitDB() {
 	DB.SetMaxOpenConns(10)
 	DB.SetMaxIdleConns(5)
 
-	createTables()
+	createMigrationsTable()
 }
 
-// createTables creates necessary database tables if they do not exist.
-func createTables() {
-	createUserTable := `CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT NOT NULL,
-		password TEXT NOT NULL
-	)`
-	_, err := DB.Exec(createUserTable)
+// Rebind rewrites the ? placeholders of a query into the placeholder syntax of the driver.
+// Queries are written with ? placeholders, which SQLite understands; PostgreSQL needs $1, $2, ...
+// Question marks inside single quoted string literals are left untouched.
+func Rebind(query string) string {
+	if Driver != Postgres {
+		return query
+	}
 
-	if err != nil {
-		panic("Could not create events table.")
+	var builder strings.Builder
+	inString := false
+	placeholder := 0
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			placeholder++
+			builder.WriteString("$" + strconv.Itoa(placeholder))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// IsConstraintViolation reports whether err was caused by a violated constraint, like a duplicate value
+// in a unique column or a foreign key pointing to a missing row. Both drivers are recognized, regardless of Driver.
+func IsConstraintViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrConstraint
 	}
 
-	createEventsTable := `
-	CREATE TABLE IF NOT EXISTS events (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT NOT NULL,
-		location TEXT NOT NULL,
-		dateTime DATETIME NOT NULL,
-		user_id INTEGER,
-	                                  
-	   FOREIGN KEY(user_id) REFERENCES users(id)
-	)
-	`
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 23 holds the integrity constraint violations.
+		return strings.HasPrefix(pqErr.SQLState(), "23")
+	}
+	return false
+}
+
+// InitSearchIndex prepares full-text search over events. PostgreSQL searches a generated tsvector column
+// created by the migrations, so there is nothing to do besides enabling FullTextSearch.
+// For SQLite, it creates the events_fts full-text index over the name, description and location of events.
+// It is an external content FTS5 table kept in sync with the events table by triggers, so every insert,
+// update and delete of an event, whichever code path issues it, is reflected in the index.
+// When the triggers are created, the index is rebuilt from the events table.
+// The index is not part of the migrations because it depends on how SQLite was built; it must be
+// initialized after the migrations have been applied.
+// If SQLite was built without FTS5, the triggers are dropped (they would make every write to events fail)
+// and FullTextSearch stays false. The index is rebuilt once FTS5 is available again.
+func InitSearchIndex() {
+	if Driver == Postgres {
+		FullTextSearch = true
+		return
+	}
+
+	var available bool
+	err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available)
+	if err != nil {
+		panic("Could not inspect SQLite build options.")
+	}
 
-	_, err = DB.Exec(createEventsTable)
+	if !available {
+		log.Println("SQLite was built without FTS5, event search is disabled. Build with -tags sqlite_fts5 to enable it.")
+		_, err = DB.Exec(`
+		DROP TRIGGER IF EXISTS events_fts_insert;
+		DROP TRIGGER IF EXISTS events_fts_delete;
+		DROP TRIGGER IF EXISTS events_fts_update;
+		`)
+		if err != nil {
+			panic("Could not drop search index triggers.")
+		}
+		return
+	}
 
+	var triggers int
+	err = DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'events_fts_%'").Scan(&triggers)
 	if err != nil {
-		panic("Could not create events table.")
+		panic("Could not inspect database schema.")
 	}
 
-	registrations := `CREATE TABLE IF NOT EXISTS registrations (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    eventId INTEGER,
-    userId INTEGER,
-    FOREIGN KEY(userId) REFERENCES users(id),
-    FOREIGN KEY(eventId) REFERENCES events(id)
-)`
-	_, err = DB.Exec(registrations)
+	searchIndex := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(
+		name, description, location,
+		content='events', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER IF NOT EXISTS events_fts_insert AFTER INSERT ON events BEGIN
+		INSERT INTO events_fts(rowid, name, description, location) VALUES (new.id, new.name, new.description, new.location);
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_fts_delete AFTER DELETE ON events BEGIN
+		INSERT INTO events_fts(events_fts, rowid, name, description, location) VALUES ('delete', old.id, old.name, old.description, old.location);
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_fts_update AFTER UPDATE OF name, description, location ON events BEGIN
+		INSERT INTO events_fts(events_fts, rowid, name, description, location) VALUES ('delete', old.id, old.name, old.description, old.location);
+		INSERT INTO events_fts(rowid, name, description, location) VALUES (new.id, new.name, new.description, new.location);
+	END;
+	`
+	_, err = DB.Exec(searchIndex)
 	if err != nil {
-		panic("Could not create registration table.")
+		panic("Could not create search index.")
 	}
+
+	if triggers < 3 {
+		_, err = DB.Exec("INSERT INTO events_fts(events_fts) VALUES ('rebuild')")
+		if err != nil {
+			panic("Could not build search index.")
+		}
+	}
+
+	FullTextSearch = true
 }
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the SQL migrations compiled into the binary, in one directory per driver:
+// migrations/sqlite and migrations/postgres. Both directories hold the same migrations, with the same
+// versions and names, so a schema change is made by adding a migration with the next version to each of them.
+// Where the databases need different changes, like 0006_search_indexes, each script explains its part.
+// Each migration is a pair of files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// e.g. 0004_event_capacity.up.sql. Versions are applied in ascending order and must never be
+// renumbered or edited once released.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationChecks holds the checks that must pass before the migration with the version is applied. They guard
+// migrations that fail on some existing data, and return an error that tells the operator how to fix the data
+// instead of the bare constraint violation the migration would fail with.
+var migrationChecks = map[int]func(tx *sql.Tx) error{
+	7:  checkUniqueEmails,
+	22: checkUniqueEmails,
+}
+
+// Migration is a numbered schema change with the SQL to apply (Up) and to revert it (Down).
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// createMigrationsTable creates the schema_migrations table, which records every applied migration version.
+func createMigrationsTable() {
+	migrationsTable := `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    appliedAt TIMESTAMP NOT NULL
+)`
+	_, err := DB.Exec(migrationsTable)
+	if err != nil {
+		panic("Could not create schema migrations table.")
+	}
+}
+
+// Migrations returns every migration of the current driver compiled into the binary, ordered by version.
+// It returns an error if a file name does not follow the naming scheme, a version is used twice
+// or a migration lacks its up or down script.
+func Migrations() ([]Migration, error) {
+	dir := "migrations/sqlite"
+	if Driver == Postgres {
+		dir = "migrations/postgres"
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(dir + "/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an empty database.
+func SchemaVersion() (int, error) {
+	var version int
+	err := DB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// PendingMigrations returns the migrations that have not been applied yet, ordered by version.
+func PendingMigrations() ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp applies every pending migration in order. Each migration runs in its own transaction
+// together with the insert into schema_migrations, so a failing migration leaves no partial changes
+// and the migrations before it stay applied. It returns the migrations that were applied.
+func MigrateUp() ([]Migration, error) {
+	pending, err := PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range pending {
+		err = runMigration(migrationChecks[migration.Version], migration.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(Rebind("INSERT INTO schema_migrations(version, name, appliedAt) VALUES (?, ?, ?)"),
+				migration.Version, migration.Name, time.Now())
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations, newest first.
+// It returns the migrations that were reverted.
+func MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+
+		err = runMigration(nil, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+			return err
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+
+	if len(reverted) < steps {
+		return reverted, errors.New("no more migrations to revert")
+	}
+	return reverted, nil
+}
+
+// runMigration runs the check, if not nil, executes a migration script and records the change with the given
+// function in one transaction.
+func runMigration(check func(tx *sql.Tx) error, script string, record func(tx *sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if check != nil {
+		err = check(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions returns the set of applied migration versions.
+func appliedVersions() (map[int]bool, error) {
+	rows, err := DB.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// checkUniqueEmails fails if users share an email, compared case-insensitively like the unique index on the
+// emails of users does since migration 22. The error lists the shared emails with the IDs of their users.
+func checkUniqueEmails(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+	SELECT id, email FROM users
+	WHERE LOWER(email) IN (SELECT LOWER(email) FROM users GROUP BY LOWER(email) HAVING COUNT(*) > 1)
+	ORDER BY LOWER(email), id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var emails []string
+	ids := map[string][]string{}
+	for rows.Next() {
+		var id int64
+		var email string
+		err = rows.Scan(&id, &email)
+		if err != nil {
+			return err
+		}
+		key := strings.ToLower(email)
+		if ids[key] == nil {
+			emails = append(emails, key)
+		}
+		ids[key] = append(ids[key], strconv.FormatInt(id, 10))
+	}
+	err = rows.Err()
+	if err != nil || len(emails) == 0 {
+		return err
+	}
+
+	duplicates := make([]string, len(emails))
+	for i, email := range emails {
+		duplicates[i] = email + " (users " + strings.Join(ids[email], ", ") + ")"
+	}
+	return fmt.Errorf("emails must be unique regardless of case, but %d are used by more than one user: %s. "+
+		"Merge those accounts or change the email of all but one of them, e.g. UPDATE users SET email = 'old-2-' || email WHERE id = 2, "+
+		"then migrate again", len(emails), strings.Join(duplicates, "; "))
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// openTestDB opens a fresh SQLite database as DB and applies every migration.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("DATABASE_URL", filepath.Join(t.TempDir(), "test.db"))
+	InitDB()
+	t.Cleanup(func() { DB.Close() })
+
+	_, err := MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+// migrateDownTo reverts migrations until the schema version is the given one.
+func migrateDownTo(t *testing.T, version int) {
+	t.Helper()
+	current, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	steps := 0
+	for _, migration := range migrations {
+		if migration.Version > version && migration.Version <= current {
+			steps++
+		}
+	}
+	_, err = MigrateDown(steps)
+	if err != nil {
+		t.Fatalf("MigrateDown(%d): %v", steps, err)
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	openTestDB(t)
+	migrateDownTo(t, 0)
+
+	applied, err := MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp after reverting every migration: %v", err)
+	}
+	migrations, err := Migrations()
+	if err != nil || len(applied) != len(migrations) {
+		t.Errorf("MigrateUp applied %d migrations, want all %d, %v", len(applied), len(migrations), err)
+	}
+}
+
+func TestMigrationVersionsMatchAcrossDrivers(t *testing.T) {
+	names := map[string][]string{}
+	for _, driver := range []string{SQLite, Postgres} {
+		Driver = driver
+		migrations, err := Migrations()
+		if err != nil {
+			t.Fatalf("Migrations of %s: %v", driver, err)
+		}
+		for _, migration := range migrations {
+			names[driver] = append(names[driver], fmt.Sprintf("%04d_%s", migration.Version, migration.Name))
+		}
+	}
+	Driver = SQLite
+
+	if !slices.Equal(names[SQLite], names[Postgres]) {
+		t.Errorf("SQLite has the migrations %v, PostgreSQL %v, want the same versions and names", names[SQLite], names[Postgres])
+	}
+}
+
+func TestUniqueEmailMigrationsReportDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		emails  []string
+	}{
+		{"exact duplicates", 6, []string{"ada@example.com", "grace@example.com", "ada@example.com"}},
+		{"case variants", 21, []string{"ada@example.com", "grace@example.com", "Ada@Example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openTestDB(t)
+			migrateDownTo(t, test.version)
+			for _, email := range test.emails {
+				_, err := DB.Exec("INSERT INTO users(email, password) VALUES (?, 'hash')", email)
+				if err != nil {
+					t.Fatalf("Could not insert user %s: %v", email, err)
+				}
+			}
+
+			_, err := MigrateUp()
+			if err == nil {
+				t.Fatal("MigrateUp succeeded, want it to report the duplicate emails")
+			}
+			for _, want := range []string{"ada@example.com (users 1, 3)", "UPDATE users SET email"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("MigrateUp error %q does not contain %q", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "grace@example.com") {
+				t.Errorf("MigrateUp error %q lists an email that is not shared", err)
+			}
+			version, _ := SchemaVersion()
+			if version != test.version {
+				t.Errorf("Schema version is %d after the failed migration, want %d", version, test.version)
+			}
+
+			_, err = DB.Exec("UPDATE users SET email = 'old-3-' || email WHERE id = 3")
+			if err != nil {
+				t.Fatalf("Could not rename the duplicate: %v", err)
+			}
+			_, err = MigrateUp()
+			if err != nil {
+				t.Errorf("MigrateUp after fixing the duplicates: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    password TEXT NOT NULL
+);
+
+CREATE TABLE events (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    location TEXT NOT NULL,
+    dateTime TIMESTAMPTZ NOT NULL,
+    user_id BIGINT REFERENCES users(id)
+);
+
+CREATE TABLE registrations (
+    id BIGSERIAL PRIMARY KEY,
+    eventId BIGINT REFERENCES events(id),
+    userId BIGINT REFERENCES users(id)
+);
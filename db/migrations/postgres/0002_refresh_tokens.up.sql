@@ -0,0 +1,9 @@
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users(id),
+    familyId TEXT NOT NULL,
+    tokenHash TEXT NOT NULL UNIQUE,
+    expiresAt TIMESTAMPTZ NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE registrations DROP COLUMN status;
+ALTER TABLE events DROP COLUMN capacity;
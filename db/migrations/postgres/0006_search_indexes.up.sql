@@ -0,0 +1,11 @@
+-- Full-text search over events. SQLite uses an FTS5 table instead, created outside the migrations
+-- because it depends on how SQLite was built (see db.InitSearchIndex).
+-- The weights rank matches in the name highest, followed by the location and the description.
+-- Locations are already indexed by lower(location) since 0005_event_indexes.
+ALTER TABLE events ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', location), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+
+CREATE INDEX idx_events_search ON events USING GIN (search);
@@ -0,0 +1,2 @@
+-- Signing up twice with the same email must fail with a constraint violation instead of creating a second account.
+CREATE UNIQUE INDEX idx_users_email ON users(email);
@@ -0,0 +1,2 @@
+-- The end of an event is optional; events without one have no known duration.
+ALTER TABLE events ADD COLUMN endDateTime TIMESTAMPTZ;
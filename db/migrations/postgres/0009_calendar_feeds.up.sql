@@ -0,0 +1,5 @@
+CREATE TABLE calendar_feeds (
+    userId BIGINT PRIMARY KEY REFERENCES users(id),
+    tokenHash TEXT NOT NULL UNIQUE,
+    createdAt TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+-- exceptionDates holds a JSON array of RFC 3339 times. The occurrence of a registration is the start of the
+-- occurrence as Unix seconds, or 0 for events that do not recur.
+ALTER TABLE events ADD COLUMN recurrenceRule TEXT;
+ALTER TABLE events ADD COLUMN exceptionDates TEXT;
+ALTER TABLE registrations ADD COLUMN occurrence BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX idx_registrations_event_occurrence ON registrations(eventId, occurrence);
@@ -0,0 +1,3 @@
+-- timeZone is the IANA name of the zone an event takes place in. The datetimes stay stored in UTC; the zone
+-- renders them and keeps the local time of recurring events across daylight saving time changes.
+ALTER TABLE events ADD COLUMN timeZone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,16 @@
+-- status is the lifecycle state of an event: draft, published, cancelled or completed. Events created before
+-- were public, so they are published.
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+CREATE INDEX idx_events_status ON events(status);
+-- Notifications tell users about changes to events they registered for, like a cancellation. They keep the
+-- event's ID without a foreign key, so they outlive the event.
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users(id),
+    eventId BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    message TEXT NOT NULL,
+    createdAt TIMESTAMPTZ NOT NULL,
+    readAt TIMESTAMPTZ
+);
+CREATE INDEX idx_notifications_user ON notifications(userId, id);
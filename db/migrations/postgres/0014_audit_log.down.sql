@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only();
@@ -0,0 +1,28 @@
+-- The audit log records every change made through the API: who made it, to what, the changed values before and
+-- after, and the request it was made by. It is append-only; the trigger rejects updates and deletes.
+-- Actors and targets are kept without foreign keys, so entries outlive the users and events they mention.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actorId BIGINT,
+    action TEXT NOT NULL,
+    targetType TEXT NOT NULL,
+    targetId BIGINT NOT NULL,
+    before JSONB,
+    after JSONB,
+    requestId TEXT NOT NULL,
+    createdAt TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_log_actor ON audit_log(actorId, id);
+CREATE INDEX idx_audit_log_target ON audit_log(targetType, targetId, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(createdAt);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'the audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+INSERT INTO role_permissions(role, permission) VALUES ('admin', 'audit:read');
@@ -0,0 +1,11 @@
+-- Password reset tokens are mailed to users who forgot their password. Like refresh tokens, only their hash is
+-- stored. A token can be used once, until usedAt is set, and only before it expires.
+CREATE TABLE password_reset_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    userId BIGINT NOT NULL REFERENCES users(id),
+    tokenHash TEXT NOT NULL UNIQUE,
+    expiresAt TIMESTAMPTZ NOT NULL,
+    usedAt TIMESTAMPTZ,
+    createdAt TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens(userId);
@@ -0,0 +1,10 @@
+CREATE TABLE refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    familyId TEXT NOT NULL,
+    tokenHash TEXT NOT NULL UNIQUE,
+    expiresAt DATETIME NOT NULL,
+    revoked INTEGER NOT NULL DEFAULT 0,
+    createdAt DATETIME NOT NULL,
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
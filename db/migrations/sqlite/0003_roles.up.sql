@@ -0,0 +1,37 @@
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY(role, permission),
+    FOREIGN KEY(role) REFERENCES roles(name)
+);
+
+CREATE TABLE user_roles (
+    userId INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    PRIMARY KEY(userId, role),
+    FOREIGN KEY(userId) REFERENCES users(id),
+    FOREIGN KEY(role) REFERENCES roles(name)
+);
+
+INSERT INTO roles(name) VALUES ('admin'), ('organizer'), ('attendee');
+
+INSERT INTO role_permissions(role, permission) VALUES
+    ('admin', 'events:create'),
+    ('admin', 'events:update:own'),
+    ('admin', 'events:update:any'),
+    ('admin', 'events:delete:own'),
+    ('admin', 'events:delete:any'),
+    ('admin', 'events:register'),
+    ('admin', 'roles:manage'),
+    ('organizer', 'events:create'),
+    ('organizer', 'events:update:own'),
+    ('organizer', 'events:delete:own'),
+    ('organizer', 'events:register'),
+    ('attendee', 'events:register');
+
+-- Accounts created before roles existed keep the access they had.
+INSERT INTO user_roles(userId, role) SELECT id, 'organizer' FROM users;
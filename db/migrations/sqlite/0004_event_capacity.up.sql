@@ -0,0 +1,2 @@
+ALTER TABLE events ADD COLUMN capacity INTEGER;
+ALTER TABLE registrations ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
@@ -0,0 +1,5 @@
+DROP INDEX idx_registrations_event;
+DROP INDEX idx_events_user_id;
+DROP INDEX idx_events_location;
+DROP INDEX idx_events_name;
+DROP INDEX idx_events_dateTime;
@@ -0,0 +1,6 @@
+-- The listing of events pages by (sort column, id), so each sortable column is indexed together with id.
+CREATE INDEX idx_events_dateTime ON events(dateTime, id);
+CREATE INDEX idx_events_name ON events(name, id);
+CREATE INDEX idx_events_location ON events(location COLLATE NOCASE);
+CREATE INDEX idx_events_user_id ON events(user_id);
+CREATE INDEX idx_registrations_event ON registrations(eventId, status);
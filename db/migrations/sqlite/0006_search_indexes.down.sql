@@ -0,0 +1,2 @@
+DROP INDEX idx_events_location;
+CREATE INDEX idx_events_location ON events(location COLLATE NOCASE);
@@ -0,0 +1,5 @@
+-- Full-text search over events. SQLite uses an FTS5 table, created outside the migrations because it
+-- depends on how SQLite was built (see db.InitSearchIndex).
+-- Locations are compared with lower() on every database, which the NOCASE index can not serve.
+DROP INDEX idx_events_location;
+CREATE INDEX idx_events_location ON events(lower(location));
@@ -0,0 +1,6 @@
+CREATE TABLE calendar_feeds (
+    userId INTEGER PRIMARY KEY,
+    tokenHash TEXT NOT NULL UNIQUE,
+    createdAt DATETIME NOT NULL,
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
@@ -0,0 +1,4 @@
+DROP INDEX idx_registrations_event_occurrence;
+ALTER TABLE registrations DROP COLUMN occurrence;
+ALTER TABLE events DROP COLUMN exceptionDates;
+ALTER TABLE events DROP COLUMN recurrenceRule;
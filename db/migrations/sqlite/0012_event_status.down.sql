@@ -0,0 +1,4 @@
+DROP INDEX idx_notifications_user;
+DROP TABLE notifications;
+DROP INDEX idx_events_status;
+ALTER TABLE events DROP COLUMN status;
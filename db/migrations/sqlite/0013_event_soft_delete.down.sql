@@ -0,0 +1,2 @@
+DROP INDEX idx_events_deleted_at;
+ALTER TABLE events DROP COLUMN deletedAt;
@@ -0,0 +1,5 @@
+-- Deleted events are kept in the trash with the time of their deletion until they are purged.
+ALTER TABLE events ADD COLUMN deletedAt DATETIME;
+CREATE INDEX idx_events_deleted_at ON events(deletedAt);
+-- Hard deletes used to leave the registrations of deleted events behind.
+DELETE FROM registrations WHERE eventId NOT IN (SELECT id FROM events);
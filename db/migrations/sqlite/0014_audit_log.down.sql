@@ -0,0 +1,7 @@
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+DROP TRIGGER audit_log_no_delete;
+DROP TRIGGER audit_log_no_update;
+DROP INDEX idx_audit_log_created_at;
+DROP INDEX idx_audit_log_target;
+DROP INDEX idx_audit_log_actor;
+DROP TABLE audit_log;
@@ -0,0 +1,29 @@
+-- The audit log records every change made through the API: who made it, to what, the changed values before and
+-- after, and the request it was made by. It is append-only; the triggers reject updates and deletes.
+-- Actors and targets are kept without foreign keys, so entries outlive the users and events they mention.
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actorId INTEGER,
+    action TEXT NOT NULL,
+    targetType TEXT NOT NULL,
+    targetId INTEGER NOT NULL,
+    before TEXT,
+    after TEXT,
+    requestId TEXT NOT NULL,
+    createdAt DATETIME NOT NULL
+);
+CREATE INDEX idx_audit_log_actor ON audit_log(actorId, id);
+CREATE INDEX idx_audit_log_target ON audit_log(targetType, targetId, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(createdAt);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;
+
+INSERT INTO role_permissions(role, permission) VALUES ('admin', 'audit:read');
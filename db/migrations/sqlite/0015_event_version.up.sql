@@ -0,0 +1,3 @@
+-- version counts the writes to an event. It is the ETag of the event, so updates can be made conditional on
+-- the version the client has seen.
+ALTER TABLE events ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
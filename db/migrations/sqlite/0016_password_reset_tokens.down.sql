@@ -0,0 +1,2 @@
+DROP INDEX idx_password_reset_tokens_user;
+DROP TABLE password_reset_tokens;
@@ -0,0 +1,12 @@
+-- Password reset tokens are mailed to users who forgot their password. Like refresh tokens, only their hash is
+-- stored. A token can be used once, until usedAt is set, and only before it expires.
+CREATE TABLE password_reset_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    tokenHash TEXT NOT NULL UNIQUE,
+    expiresAt DATETIME NOT NULL,
+    usedAt DATETIME,
+    createdAt DATETIME NOT NULL,
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens(userId);
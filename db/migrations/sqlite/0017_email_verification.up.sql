@@ -0,0 +1,6 @@
+-- emailVerifiedAt is set once the user followed the link of the verification email. verificationSentAt is the
+-- time the last verification email was sent, to throttle resending it.
+ALTER TABLE users ADD COLUMN emailVerifiedAt DATETIME;
+ALTER TABLE users ADD COLUMN verificationSentAt DATETIME;
+-- Accounts created before emails were verified keep the access they had.
+UPDATE users SET emailVerifiedAt = CURRENT_TIMESTAMP;
@@ -0,0 +1,6 @@
+ALTER TABLE roles DROP COLUMN requireTwoFactor;
+DROP INDEX idx_recovery_codes_user;
+DROP TABLE recovery_codes;
+ALTER TABLE users DROP COLUMN totpLastStep;
+ALTER TABLE users DROP COLUMN totpEnabledAt;
+ALTER TABLE users DROP COLUMN totpSecret;
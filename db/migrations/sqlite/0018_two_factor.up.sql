@@ -0,0 +1,19 @@
+-- totpSecret is the shared secret of the authenticator app of the user. It is set when enrolling and only takes
+-- effect once the user confirmed it with a first code, which sets totpEnabledAt. totpLastStep is the time step of
+-- the last accepted code, so a code can not be used twice.
+ALTER TABLE users ADD COLUMN totpSecret TEXT;
+ALTER TABLE users ADD COLUMN totpEnabledAt DATETIME;
+ALTER TABLE users ADD COLUMN totpLastStep INTEGER;
+-- Recovery codes log in without the authenticator app. Like refresh tokens, only their hash is stored, and each
+-- can be used once.
+CREATE TABLE recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    codeHash TEXT NOT NULL UNIQUE,
+    usedAt DATETIME,
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(userId);
+-- Administrators can require two-factor authentication for a role; its users must enroll before they can use the
+-- API. No role requires it initially, so existing accounts keep the access they had.
+ALTER TABLE roles ADD COLUMN requireTwoFactor BOOLEAN NOT NULL DEFAULT FALSE;
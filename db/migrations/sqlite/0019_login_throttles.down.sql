@@ -0,0 +1,2 @@
+DROP INDEX idx_login_throttles_expires;
+DROP TABLE login_throttles;
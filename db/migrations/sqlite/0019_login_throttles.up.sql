@@ -0,0 +1,10 @@
+-- Failed logins are counted per account and per client IP, under the keys "account:<email>" and "ip:<address>".
+-- A key is locked until lockedUntil once it failed too often. The count starts over after expiresAt, which is
+-- also when the row can be removed.
+CREATE TABLE login_throttles (
+    throttleKey TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    lockedUntil DATETIME,
+    expiresAt DATETIME NOT NULL
+);
+CREATE INDEX idx_login_throttles_expires ON login_throttles(expiresAt);
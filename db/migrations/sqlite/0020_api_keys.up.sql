@@ -0,0 +1,17 @@
+-- API keys authenticate scripts on behalf of their user. Like refresh tokens, only their hash is stored; prefix
+-- is the start of the key, to tell keys apart. scopes lists the permissions the key is limited to, separated by
+-- spaces, or is empty for all permissions of the user.
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    keyHash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expiresAt DATETIME,
+    lastUsedAt DATETIME,
+    createdAt DATETIME NOT NULL,
+    revokedAt DATETIME,
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
+CREATE INDEX idx_api_keys_user ON api_keys(userId);
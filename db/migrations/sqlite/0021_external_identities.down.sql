@@ -0,0 +1,4 @@
+DROP INDEX idx_oidc_logins_expires;
+DROP TABLE oidc_logins;
+DROP INDEX idx_external_identities_user;
+DROP TABLE external_identities;
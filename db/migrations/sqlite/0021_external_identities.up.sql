@@ -0,0 +1,23 @@
+-- External identities link users to their account at an OpenID Connect identity provider, which is identified
+-- by the issuer and subject of its ID tokens. email is the email the provider last reported.
+CREATE TABLE external_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    userId INTEGER NOT NULL,
+    email TEXT NOT NULL,
+    createdAt DATETIME NOT NULL,
+    lastLoginAt DATETIME NOT NULL,
+    PRIMARY KEY(issuer, subject),
+    FOREIGN KEY(userId) REFERENCES users(id)
+);
+CREATE INDEX idx_external_identities_user ON external_identities(userId);
+
+-- OIDC logins hold the state of logins sent to the identity provider until it redirects back: the hash of the
+-- state parameter, the nonce expected in the ID token and the PKCE code verifier.
+CREATE TABLE oidc_logins (
+    stateHash TEXT PRIMARY KEY,
+    nonce TEXT NOT NULL,
+    codeVerifier TEXT NOT NULL,
+    expiresAt DATETIME NOT NULL
+);
+CREATE INDEX idx_oidc_logins_expires ON oidc_logins(expiresAt);
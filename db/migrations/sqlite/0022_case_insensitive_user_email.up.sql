@@ -0,0 +1,4 @@
+-- Emails are looked up case-insensitively, so ADA@example.com and ada@example.com must not be two accounts.
+-- db.checkUniqueEmails reports accounts that would violate the index before it is created.
+DROP INDEX idx_users_email;
+CREATE UNIQUE INDEX idx_users_email ON users(lower(email));
@@ -0,0 +1,77 @@
+// Package jsonpatch applies changes to JSON documents, described either as a JSON Merge Patch (RFC 7396) or as
+// a JSON Patch (RFC 6902). Documents are decoded with their numbers kept as json.Number, so large integers survive
+// a round trip unchanged.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The media types of the two patch formats.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// ErrInvalidPatch is returned if the patch is not a well-formed patch document.
+var ErrInvalidPatch = errors.New("invalid patch document")
+
+// ErrPatchFailed is returned if a well-formed JSON Patch can not be applied to the document, because a location
+// it refers to does not exist or a test operation failed.
+var ErrPatchFailed = errors.New("the patch can not be applied")
+
+// Merge applies the JSON Merge Patch to the document and returns the patched document.
+// Members of the patch replace those of the document, objects are merged recursively, and members set to null
+// are removed. A patch that is not an object replaces the whole document.
+func Merge(document, patch []byte) ([]byte, error) {
+	target, err := decode(document)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergeValue(target, changes))
+}
+
+// mergeValue implements the MergePatch function of RFC 7396, section 2.
+func mergeValue(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergeValue(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// decode parses a single JSON value, keeping numbers as json.Number.
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,76 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "replace members",
+			patch: `{"Name": "Gophers", "Capacity": 30}`,
+			want:  `{"Name": "Gophers", "Capacity": 30, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}}`,
+		},
+		{
+			name:  "null removes members",
+			patch: `{"Capacity": null, "Venue": {"City": null}, "Location": null}`,
+			want:  `{"Name": "Go Meetup", "Tags": ["go", "berlin"], "Venue": {}}`,
+		},
+		{
+			name:  "objects merge recursively",
+			patch: `{"Venue": {"Street": "Main Street"}}`,
+			want:  `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin", "Street": "Main Street"}}`,
+		},
+		{
+			name:  "arrays are replaced",
+			patch: `{"Tags": ["talks"]}`,
+			want:  `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["talks"], "Venue": {"City": "Berlin"}}`,
+		},
+		{
+			name:  "nulls in new objects are left out",
+			patch: `{"Organizer": {"Name": "Ada", "Email": null}}`,
+			want:  `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}, "Organizer": {"Name": "Ada"}}`,
+		},
+		{
+			name:  "a patch that is no object replaces the document",
+			patch: `["go"]`,
+			want:  `["go"]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Merge([]byte(testDocument), []byte(test.patch))
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if !sameJSON(t, got, test.want) {
+				t.Errorf("Merge = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsLargeIntegers(t *testing.T) {
+	got, err := Merge([]byte(`{"ID": 9007199254740993}`), []byte(`{"Capacity": 9007199254740995}`))
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if !bytes.Contains(got, []byte(`"ID":9007199254740993`)) || !bytes.Contains(got, []byte(`"Capacity":9007199254740995`)) {
+		t.Errorf("Merge = %s, want the integers unchanged", got)
+	}
+}
+
+func TestMergeRejectsInvalidPatches(t *testing.T) {
+	for _, patch := range []string{`{"Name": `, `{"Name": "Gophers"} {}`, ``} {
+		got, err := Merge([]byte(testDocument), []byte(patch))
+		if !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("Merge with the patch %q = %s, %v, want ErrInvalidPatch", patch, got, err)
+		}
+	}
+}
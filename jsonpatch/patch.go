@@ -0,0 +1,313 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// operation is a single operation of a JSON Patch. Value is nil if the member is missing, and the JSON null
+// literal if it is null.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies the JSON Patch, a JSON array of operations, to the document and returns the patched document.
+// The operations add, remove, replace, move, copy and test are applied in order; if one of them fails, the
+// whole patch fails. Locations are JSON Pointers (RFC 6901), and "-" adds to the end of an array.
+// It returns ErrInvalidPatch if the patch is malformed and ErrPatchFailed if it can not be applied.
+func Apply(document, patch []byte) ([]byte, error) {
+	target, err := decode(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []operation
+	err = json.Unmarshal(patch, &operations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch must be an array of operations", ErrInvalidPatch)
+	}
+
+	for i, op := range operations {
+		target, err = op.apply(target)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// apply applies the operation to the document and returns the changed document. Objects and arrays of the
+// document may be changed in place.
+func (op operation) apply(document any) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: %s without path", ErrInvalidPatch, op.Op)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: %s without value", ErrInvalidPatch, op.Op)
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		switch op.Op {
+		case "add":
+			return add(document, path, value)
+		case "replace":
+			document, err = remove(document, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(document, path, value)
+		default:
+			current, err := get(document, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, fmt.Errorf("%w: the value at %q is not the tested value", ErrPatchFailed, *op.Path)
+			}
+			return document, nil
+		}
+
+	case "remove":
+		return remove(document, path)
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: %s without from", ErrInvalidPatch, op.Op)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(document, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+				return nil, fmt.Errorf("%w: can not move %q into itself", ErrPatchFailed, *op.From)
+			}
+			document, err = remove(document, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// The copy must not share objects or arrays with the original.
+			value, err = deepCopy(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return add(document, path, value)
+	}
+
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens. The empty pointer refers to the
+// whole document and has no tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %q is not a JSON Pointer", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get returns the value at the path.
+func get(document any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(token)
+			}
+			document = value
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			document = node[index]
+		default:
+			return nil, notFound(token)
+		}
+	}
+	return document, nil
+}
+
+// add sets the member of an object at the path, or inserts the value into an array at the path, and returns
+// the changed document. The parent of the location must exist.
+func add(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch node := document.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, notFound(token)
+		}
+		child, err := add(child, rest, value)
+		node[token] = child
+		return node, err
+
+	case []any:
+		if len(rest) == 0 {
+			if token == "-" {
+				return append(node, value), nil
+			}
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		index, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := add(node[index], rest, value)
+		node[index] = child
+		return node, err
+	}
+
+	return nil, notFound(token)
+}
+
+// remove removes the member of an object or the element of an array at the path, which must exist, and
+// returns the changed document. Removing the whole document leaves null.
+func remove(document any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch node := document.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, notFound(token)
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, nil
+		}
+		child, err := remove(child, rest)
+		node[token] = child
+		return node, err
+
+	case []any:
+		index, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			return append(node[:index], node[index+1:]...), nil
+		}
+		child, err := remove(node[index], rest)
+		node[index] = child
+		return node, err
+	}
+
+	return nil, notFound(token)
+}
+
+// arrayIndex parses the reference token as an array index of at most maxIndex. RFC 6901 forbids leading zeros.
+func arrayIndex(token string, maxIndex int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrPatchFailed, token)
+	}
+	if index > maxIndex {
+		return 0, fmt.Errorf("%w: array index %d is out of range", ErrPatchFailed, index)
+	}
+	return index, nil
+}
+
+// notFound returns the error for a location that does not exist.
+func notFound(token string) error {
+	return fmt.Errorf("%w: %q does not exist", ErrPatchFailed, token)
+}
+
+// equal reports whether two decoded JSON values are equal as defined for the test operation: numbers are
+// compared by value, objects regardless of the order of their members, and arrays element by element.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for name, value := range a {
+			other, ok := b[name]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// deepCopy returns a copy of the decoded JSON value that shares no objects or arrays with it.
+func deepCopy(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
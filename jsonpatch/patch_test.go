@@ -0,0 +1,111 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+)
+
+// sameJSON reports whether both documents hold equal JSON values, regardless of the order of object members.
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	a, err := decode(got)
+	if err != nil {
+		t.Fatalf("Could not decode %s: %v", got, err)
+	}
+	b, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("Could not decode %s: %v", want, err)
+	}
+	return equal(a, b)
+}
+
+const testDocument = `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}}`
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "add and replace",
+			patch: `[{"op": "add", "path": "/Location", "value": "Berlin"}, {"op": "replace", "path": "/Capacity", "value": 30}]`,
+			want:  `{"Name": "Go Meetup", "Capacity": 30, "Location": "Berlin", "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}}`,
+		},
+		{
+			name:  "add to arrays",
+			patch: `[{"op": "add", "path": "/Tags/0", "value": "meetup"}, {"op": "add", "path": "/Tags/-", "value": "talks"}]`,
+			want:  `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["meetup", "go", "berlin", "talks"], "Venue": {"City": "Berlin"}}`,
+		},
+		{
+			name:  "remove",
+			patch: `[{"op": "remove", "path": "/Tags/1"}, {"op": "remove", "path": "/Venue/City"}]`,
+			want:  `{"Name": "Go Meetup", "Capacity": 20, "Tags": ["go"], "Venue": {}}`,
+		},
+		{
+			name:  "passing test",
+			patch: `[{"op": "test", "path": "/Capacity", "value": 20.0}, {"op": "test", "path": "/Venue", "value": {"City": "Berlin"}}, {"op": "replace", "path": "/Name", "value": "Gophers"}]`,
+			want:  `{"Name": "Gophers", "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}}`,
+		},
+		{
+			name:  "move and copy",
+			patch: `[{"op": "move", "path": "/City", "from": "/Venue/City"}, {"op": "copy", "path": "/Title", "from": "/Name"}]`,
+			want:  `{"Name": "Go Meetup", "Title": "Go Meetup", "City": "Berlin", "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {}}`,
+		},
+		{
+			name:  "escaped pointer",
+			patch: `[{"op": "add", "path": "/a~1b~0c", "value": 1}]`,
+			want:  `{"Name": "Go Meetup", "a/b~c": 1, "Capacity": 20, "Tags": ["go", "berlin"], "Venue": {"City": "Berlin"}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Apply([]byte(testDocument), []byte(test.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, test.want) {
+				t.Errorf("Apply = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr error
+	}{
+		{"failing test", `[{"op": "test", "path": "/Capacity", "value": 21}]`, ErrPatchFailed},
+		{"test of another type", `[{"op": "test", "path": "/Capacity", "value": "20"}]`, ErrPatchFailed},
+		{"test of a missing member", `[{"op": "test", "path": "/Location", "value": "Berlin"}]`, ErrPatchFailed},
+		{"failing test after a change", `[{"op": "replace", "path": "/Name", "value": "Gophers"}, {"op": "test", "path": "/Name", "value": "Go Meetup"}]`, ErrPatchFailed},
+		{"move from a missing member", `[{"op": "move", "path": "/City", "from": "/Venue/Street"}]`, ErrPatchFailed},
+		{"move into itself", `[{"op": "move", "path": "/Venue/Inner", "from": "/Venue"}]`, ErrPatchFailed},
+		{"move to a missing parent", `[{"op": "move", "path": "/Missing/City", "from": "/Venue/City"}]`, ErrPatchFailed},
+		{"copy from an index out of range", `[{"op": "copy", "path": "/Tag", "from": "/Tags/2"}]`, ErrPatchFailed},
+		{"remove a missing member", `[{"op": "remove", "path": "/Location"}]`, ErrPatchFailed},
+		{"replace a missing member", `[{"op": "replace", "path": "/Location", "value": "Berlin"}]`, ErrPatchFailed},
+		{"add at an index out of range", `[{"op": "add", "path": "/Tags/3", "value": "talks"}]`, ErrPatchFailed},
+		{"add at an index with a leading zero", `[{"op": "add", "path": "/Tags/01", "value": "talks"}]`, ErrPatchFailed},
+		{"add below a string", `[{"op": "add", "path": "/Name/First", "value": "Go"}]`, ErrPatchFailed},
+		{"not an array", `{"op": "add", "path": "/Location", "value": "Berlin"}`, ErrInvalidPatch},
+		{"unknown operation", `[{"op": "rename", "path": "/Name"}]`, ErrInvalidPatch},
+		{"missing path", `[{"op": "remove"}]`, ErrInvalidPatch},
+		{"missing value", `[{"op": "test", "path": "/Name"}]`, ErrInvalidPatch},
+		{"missing from", `[{"op": "move", "path": "/Title"}]`, ErrInvalidPatch},
+		{"path without a leading slash", `[{"op": "remove", "path": "Name"}]`, ErrInvalidPatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Apply([]byte(testDocument), []byte(test.patch))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Apply = %s, %v, want %v", got, err, test.wantErr)
+			}
+			if got != nil {
+				t.Errorf("Apply returned %s with the error, want no document", got)
+			}
+		})
+	}
+}
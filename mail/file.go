@@ -0,0 +1,25 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileSender writes every message as an .eml file into Dir instead of delivering it, for local testing.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// Send writes the message into a new file named after the current time, creating Dir if needed.
+func (sender FileSender) Send(message Message) error {
+	err := os.MkdirAll(sender.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	return os.WriteFile(filepath.Join(sender.Dir, name), encode(sender.From, message), 0o600)
+}
@@ -0,0 +1,99 @@
+// Package mail sends emails to users, like password reset links. Emails go through the Sender selected by
+// InitSender: an SMTP server in production, or the log or a directory of .eml files for local testing.
+package mail
+
+import (
+	"RestAPI/config"
+	"log"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(message Message) error
+}
+
+// DefaultMailDir is the directory a FileSender writes to unless MAIL_DIR is set. It is relative to the working
+// directory, like the default database, and outside the Go packages; git ignores it, see .gitignore.
+const DefaultMailDir = "var/mail"
+
+// Default is the Sender used by Send, set by InitSender.
+var Default Sender = LogSender{}
+
+// InitSender selects the Sender configured by MAIL_SENDER:
+//   - "smtp": an SMTPSender for SMTP_HOST and SMTP_PORT (587 by default), authenticating with SMTP_USERNAME and
+//     SMTP_PASSWORD if set.
+//   - "file": a FileSender writing to MAIL_DIR, DefaultMailDir by default.
+//   - "log", the default: a LogSender.
+//
+// Messages are sent from MAIL_FROM, "no-reply@localhost" by default.
+func InitSender() {
+	from := config.String("MAIL_FROM", "no-reply@localhost")
+
+	switch config.String("MAIL_SENDER", "log") {
+	case "smtp":
+		Default = SMTPSender{
+			Host:     config.String("SMTP_HOST", "localhost"),
+			Port:     config.Int("SMTP_PORT", 587),
+			Username: config.String("SMTP_USERNAME", ""),
+			Password: config.String("SMTP_PASSWORD", ""),
+			From:     from,
+		}
+	case "file":
+		Default = FileSender{Dir: config.String("MAIL_DIR", DefaultMailDir), From: from}
+	default:
+		Default = LogSender{}
+	}
+}
+
+// Send delivers the message through the Default sender.
+func Send(message Message) error {
+	return Default.Send(message)
+}
+
+// SendAsync delivers the message through the Default sender in the background and logs a failure. Handlers use
+// it so the time a response takes does not reveal whether an email was sent.
+func SendAsync(message Message) {
+	go func() {
+		err := Send(message)
+		if err != nil {
+			log.Printf("Could not send %q to %s: %v", message.Subject, message.To, err)
+		}
+	}()
+}
+
+// LogSender writes messages to the log instead of delivering them.
+type LogSender struct{}
+
+// Send logs the message.
+func (LogSender) Send(message Message) error {
+	log.Printf("Mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// encode renders the message as an RFC 5322 document with CRLF line endings, as sent over SMTP.
+// Line breaks in the headers are removed, so a recipient or subject can not inject further headers.
+func encode(from string, message Message) []byte {
+	header := func(value string) string {
+		return strings.NewReplacer("\r", "", "\n", " ").Replace(value)
+	}
+
+	var document strings.Builder
+	document.WriteString("From: " + header(from) + "\r\n")
+	document.WriteString("To: " + header(message.To) + "\r\n")
+	document.WriteString("Subject: " + header(message.Subject) + "\r\n")
+	document.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	document.WriteString("MIME-Version: 1.0\r\n")
+	document.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	document.WriteString("\r\n")
+	document.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(document.String())
+}
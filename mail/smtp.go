@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPSender delivers messages through an SMTP server. The connection is upgraded with STARTTLS if the server
+// supports it; credentials are only sent over TLS or to localhost, see smtp.PlainAuth.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message to the SMTP server.
+func (sender SMTPSender) Send(message Message) error {
+	var auth smtp.Auth
+	if sender.Username != "" {
+		auth = smtp.PlainAuth("", sender.Username, sender.Password, sender.Host)
+	}
+
+	address := net.JoinHostPort(sender.Host, strconv.Itoa(sender.Port))
+	return smtp.SendMail(address, auth, sender.From, []string{message.To}, encode(sender.From, message))
+}
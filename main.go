@@ -1,25 +1,88 @@
 package main
 
 import (
+	"RestAPI/Models"
+	"RestAPI/config"
 	"RestAPI/db"
+	"RestAPI/mail"
 	"RestAPI/routes"
+	"RestAPI/utils"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
 )
 
-// main is the entry point of the application. It initializes the database connection,
-// creates an instance of the Gin web framework, registers all routes, and starts the server.
+// main is the entry point of the application. It initializes the database connection, applies pending
+// schema migrations if -auto-migrate is set (the default, see AUTO_MIGRATE), and initializes the stores
+// matching the database, the validation rules, the JWT keys and the mail sender, and starts purging the trash in the background
+// (see TRASH_RETENTION and TRASH_PURGE_INTERVAL).
+// When started with the "migrate" command it manages the migrations instead of starting the server.
+// It then grants the admin role to the account configured by ADMIN_EMAIL, creates an instance of the Gin web framework,
+// trusts the proxies configured by TRUSTED_PROXIES to report the client IP, registers all routes, and starts the server.
 // If any error occurs during the server startup, it prints an error message and exits the function.
 // The server runs on http://localhost:8080.
 func main() {
+	autoMigrate := flag.Bool("auto-migrate", config.Bool("AUTO_MIGRATE", true), "apply pending schema migrations on startup")
+	flag.Parse()
+
 	db.InitDB()
+
+	if flag.Arg(0) == "migrate" {
+		err := runMigrateCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	err := applyMigrationsOnStartup(*autoMigrate)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	db.InitSearchIndex()
+	models.InitStores()
+	models.InitValidation()
+	utils.InitKeys()
+	mail.InitSender()
+	models.StartTrashPurge(config.Duration("TRASH_RETENTION", 30*24*time.Hour), config.Duration("TRASH_PURGE_INTERVAL", time.Hour))
+
+	if adminEmail := config.String("ADMIN_EMAIL", ""); adminEmail != "" {
+		err := models.EnsureAdmin(adminEmail)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
 	server := gin.Default()
+	err = server.SetTrustedProxies(trustedProxies())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	routes.RegisterRoutes(server)
 
-	err := server.Run(":8080")
+	err = server.Run(":8080")
 	if err != nil {
 		fmt.Println(err)
 		return
 	} // localhost:8080
 }
+
+// trustedProxies returns the addresses or CIDR ranges of the reverse proxies configured by TRUSTED_PROXIES,
+// separated by commas. Only those proxies are trusted to report the client IP in X-Forwarded-For, which login
+// throttling relies on; by default no proxy is trusted and the client IP is the address of the connection.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(config.String("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
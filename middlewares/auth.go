@@ -1,30 +1,182 @@
 package middlewares
 
 import (
+	"RestAPI/Models"
+	"RestAPI/problems"
 	"RestAPI/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"slices"
+	"strings"
 )
 
 // Authenticate function takes a gin.Context as input and performs token authentication.
-// It retrieves the token from the "Authorization" header of the HTTP request.
-// If the token is empty, it aborts the request with a Unauthorized response.
-// It then calls the VerifyToken function from utils package to validate the token and extract the userId.
-// If token verification fails, it aborts the request with a Unauthorized response.
-// If token verification succeeds, it sets the "userId" key in the request context with the extracted userId.
+// It retrieves the token from the "Authorization" header of the HTTP request, optionally after "Bearer ".
+// A header of the form "ApiKey <key>" authenticates with an API key instead, see authenticateAPIKey.
+// If the token is empty, it aborts the request with an Unauthorized problem.
+// It then calls the VerifyToken function from utils package to validate the token and extract the userId and roles.
+// If token verification fails, it aborts the request with an Unauthorized problem.
+// If token verification succeeds, it sets the "userId" and "roles" keys in the request context.
 // Finally, it calls the Next method of the gin.Context to proceed to the next middleware or handler.
 func Authenticate(context *gin.Context) {
 	token := context.Request.Header.Get("Authorization")
 	if token == "" {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized"})
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeUnauthorized, "Not Authorized"))
 		return
 	}
-	userId, err := utils.VerifyToken(token)
+	if key, ok := strings.CutPrefix(token, "ApiKey "); ok {
+		authenticateAPIKey(context, strings.TrimSpace(key))
+		return
+	}
+	userId, roles, err := utils.VerifyToken(strings.TrimPrefix(token, "Bearer "))
 
 	if err != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized"})
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeUnauthorized, "Not Authorized"))
 		return
 	}
 	context.Set("userId", userId)
+	context.Set("roles", roles)
+	context.Next()
+}
+
+// authenticateAPIKey authenticates the request with the API key, see models.AuthenticateAPIKey. Like Authenticate
+// it sets the "userId" and "roles" keys, with the current roles of the user, and additionally "apiKeyId" and the
+// "scopes" of the key, which limit the permissions (see HasPermission). It aborts with an Unauthorized problem if
+// the key is invalid, revoked or expired.
+func authenticateAPIKey(context *gin.Context, key string) {
+	apiKey, err := models.AuthenticateAPIKey(key)
+	if errors.Is(err, models.ErrInvalidAPIKey) {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeUnauthorized, "Not Authorized"))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate the API key."))
+		return
+	}
+
+	roles, err := models.GetUserRoles(apiKey.UserID)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate the API key."))
+		return
+	}
+	context.Set("userId", apiKey.UserID)
+	context.Set("roles", roles)
+	context.Set("apiKeyId", apiKey.ID)
+	context.Set("scopes", apiKey.Scopes)
+	context.Next()
+}
+
+// RequireSession only lets the request through if it was authenticated by logging in rather than with an API
+// key. It must run after Authenticate and protects the routes that manage the account's credentials, so a leaked
+// API key can not be used to create further keys or to change two-factor authentication. It aborts with a
+// Forbidden problem for API keys.
+func RequireSession(context *gin.Context) {
+	if _, ok := context.Get("apiKeyId"); ok {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "This endpoint can not be used with an API key."))
+		return
+	}
+	context.Next()
+}
+
+// IdentifyUser authenticates the request like Authenticate if it carries an Authorization header, and lets
+// anonymous requests through without setting "userId". It is used by public endpoints whose response depends
+// on the caller, e.g. to show users their own drafts. A request with an invalid token is aborted with an
+// Unauthorized problem rather than treated as anonymous, so clients notice expired tokens.
+func IdentifyUser(context *gin.Context) {
+	if context.Request.Header.Get("Authorization") == "" {
+		context.Next()
+		return
+	}
+	Authenticate(context)
+}
+
+// RequirePermission returns a middleware that only lets the request through if the authenticated user
+// holds at least one of the given permissions through one of its roles. It must run after Authenticate.
+// If the permissions can not be resolved it aborts with an Internal Server Error problem,
+// and if the user lacks all of the permissions it aborts with a Forbidden problem.
+//
+// Example usage:
+//
+//	authenticated.DELETE("/events/:id", middlewares.RequirePermission("events:delete:own", "events:delete:any"), deleteEvent)
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		for _, permission := range permissions {
+			granted, err := HasPermission(context, permission)
+			if err != nil {
+				problems.Respond(context, problems.FromError(err, "Could not check permissions."))
+				return
+			}
+			if granted {
+				context.Next()
+				return
+			}
+		}
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Forbidden"))
+	}
+}
+
+// HasPermission reports whether the authenticated user holds the given permission through one of its roles.
+// For a request authenticated with an API key that has scopes, the permission must also be one of the scopes.
+// The permissions of the roles are resolved once per request and kept in the "permissions" context key.
+// Handlers use it for checks that depend on the resource, e.g. whether a user may edit an event it does not own.
+func HasPermission(context *gin.Context, permission string) (bool, error) {
+	permissions, ok := context.Get("permissions")
+	if !ok {
+		resolved, err := models.GetPermissionsForRoles(context.GetStringSlice("roles"))
+		if err != nil {
+			return false, err
+		}
+		if scopes := context.GetStringSlice("scopes"); len(scopes) > 0 {
+			resolved = slices.DeleteFunc(resolved, func(permission string) bool { return !slices.Contains(scopes, permission) })
+		}
+		context.Set("permissions", resolved)
+		permissions = resolved
+	}
+
+	for _, granted := range permissions.([]string) {
+		if granted == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequireVerifiedEmail only lets the request through if the authenticated user has verified their email, when
+// REQUIRE_VERIFIED_EMAIL is set (see models.EmailVerificationRequired); otherwise it lets every request through.
+// It must run after Authenticate. It aborts with a Forbidden problem with the code "email_not_verified" if the
+// email is not verified, and with an Internal Server Error problem if that can not be checked.
+func RequireVerifiedEmail(context *gin.Context) {
+	if !models.EmailVerificationRequired() {
+		context.Next()
+		return
+	}
+
+	verified, err := models.IsEmailVerified(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check the email verification."))
+		return
+	}
+	if !verified {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeEmailNotVerified, "Verify your email first."))
+		return
+	}
+	context.Next()
+}
+
+// RequireTwoFactor only lets the request through if the authenticated user has enabled two-factor authentication
+// or none of their roles requires it (see models.SetRoleTwoFactor). It must run after Authenticate. Users who
+// still have to enroll are aborted with a Forbidden problem with the code "two_factor_required", so the routes
+// to enroll must not use it. It aborts with an Internal Server Error problem if the requirement can not be checked.
+func RequireTwoFactor(context *gin.Context) {
+	required, err := models.TwoFactorEnrollmentRequired(context.GetInt64("userId"), context.GetStringSlice("roles"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check two-factor authentication."))
+		return
+	}
+	if required {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeTwoFactorRequired, "Your role requires two-factor authentication, enable it first."))
+		return
+	}
 	context.Next()
 }
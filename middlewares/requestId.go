@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"regexp"
+)
+
+// RequestIDHeader is the header that carries the ID of a request, both in the request and in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs accepted from clients and proxies.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID gives every request an ID, so the entries it adds to the audit log can be matched with logs of the
+// client or a proxy. It keeps the ID from the X-Request-ID header of the request if it is a short token,
+// and otherwise generates a random one. The ID is set in the "requestId" context key and the X-Request-ID
+// header of the response.
+func RequestID(context *gin.Context) {
+	requestId := context.Request.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(requestId) {
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		requestId = hex.EncodeToString(buf)
+	}
+
+	context.Set("requestId", requestId)
+	context.Header(RequestIDHeader, requestId)
+	context.Next()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"RestAPI/db"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// runMigrateCommand implements the "migrate" command line command:
+//
+//	migrate status     prints the current schema version and the pending migrations
+//	migrate up         applies every pending migration
+//	migrate down [n]   reverts the n most recently applied migrations (1 if n is omitted)
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate status | up | down [n]")
+	}
+
+	switch args[0] {
+	case "status":
+		version, err := db.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		pending, err := db.PendingMigrations()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Schema version: %d\n", version)
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations.")
+		}
+		for _, migration := range pending {
+			fmt.Printf("Pending: %04d_%s\n", migration.Version, migration.Name)
+		}
+		return nil
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, migration := range applied {
+			fmt.Printf("Applied: %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New("usage: migrate down [n]")
+			}
+		}
+		reverted, err := db.MigrateDown(steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted: %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	default:
+		return errors.New("usage: migrate status | up | down [n]")
+	}
+}
+
+// applyMigrationsOnStartup brings the schema up to date before the server starts.
+// With autoMigrate, every pending migration is applied. Otherwise the server refuses to start while
+// migrations are pending, since the code would run against a schema it does not expect.
+func applyMigrationsOnStartup(autoMigrate bool) error {
+	if !autoMigrate {
+		pending, err := db.PendingMigrations()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, run \"migrate up\" or start with -auto-migrate", len(pending))
+		}
+		return nil
+	}
+
+	applied, err := db.MigrateUp()
+	for _, migration := range applied {
+		fmt.Printf("Applied migration %04d_%s\n", migration.Version, migration.Name)
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package problems
+
+import (
+	"RestAPI/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+)
+
+// ContentType is the media type of problem details documents as defined by RFC 7807.
+const ContentType = "application/problem+json"
+
+// The error codes of the API. A code identifies the kind of error independent of the wording of the detail,
+// so clients can branch on it; codes are never changed or reused once published.
+const (
+	CodeInvalidRequest           = "invalid_request"
+	CodeValidationFailed         = "validation_failed"
+	CodeInvalidCursor            = "invalid_cursor"
+	CodeInvalidWindow            = "invalid_window"
+	CodeInvalidOccurrence        = "invalid_occurrence"
+	CodeEmptySearch              = "empty_search"
+	CodeUnauthorized             = "unauthorized"
+	CodeInvalidCredentials       = "invalid_credentials"
+	CodeAccountLocked            = "account_locked"
+	CodeOIDCLoginFailed          = "oidc_login_failed"
+	CodeInvalidToken             = "invalid_refresh_token"
+	CodeInvalidResetToken        = "invalid_reset_token"
+	CodeForbidden                = "forbidden"
+	CodeNotFound                 = "not_found"
+	CodeConflict                 = "conflict"
+	CodeInvalidTransition        = "invalid_transition"
+	CodeEventNotOpen             = "event_not_open"
+	CodeEventClosed              = "event_closed"
+	CodeHasRegistrations         = "has_registrations"
+	CodePreconditionFailed       = "precondition_failed"
+	CodeMissingPrecondition      = "precondition_required"
+	CodeEmailTaken               = "email_taken"
+	CodeEmailNotVerified         = "email_not_verified"
+	CodeInvalidVerificationToken = "invalid_verification_token"
+	CodeInvalidTwoFactorCode     = "invalid_two_factor_code"
+	CodeTwoFactorRequired        = "two_factor_required"
+	CodeTooManyRequests          = "too_many_requests"
+	CodeUnknownRole              = "unknown_role"
+	CodePatchFailed              = "patch_failed"
+	CodeUnsupportedMediaType     = "unsupported_media_type"
+	CodeSearchUnavailable        = "search_unavailable"
+	CodeProviderUnavailable      = "identity_provider_unavailable"
+	CodeInternal                 = "internal_error"
+)
+
+// FieldError describes why the value of a single field of the request was rejected.
+// Rule is the name of the violated validation rule, e.g. "required" or "max", and Reason explains it in human readable form.
+// Row is the line of an uploaded file the rejected value was read from, if the request carried a file.
+type FieldError struct {
+	Row    int    `json:"row,omitempty"`
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// Reason builds the human readable reason of a violated validation rule.
+type Reason func(fieldError validator.FieldError) string
+
+// reasons holds the Reason of every validation rule by its tag. Rules without one are explained as "is invalid".
+var reasons = map[string]Reason{
+	"required": func(validator.FieldError) string { return "is required" },
+	"email":    func(validator.FieldError) string { return "must be a valid email address" },
+	"min": func(fieldError validator.FieldError) string {
+		if fieldError.Kind() == reflect.String {
+			return "must be at least " + fieldError.Param() + " characters long"
+		}
+		return "must be at least " + fieldError.Param()
+	},
+	"max": func(fieldError validator.FieldError) string {
+		if fieldError.Kind() == reflect.String {
+			return "must be at most " + fieldError.Param() + " characters long"
+		}
+		return "must be at most " + fieldError.Param()
+	},
+	"gtfield": func(fieldError validator.FieldError) string { return "must be after " + fieldError.Param() },
+	"oneof":   func(fieldError validator.FieldError) string { return "must be one of " + fieldError.Param() },
+}
+
+// RegisterReason sets the Reason used to explain violations of the validation rule with the given tag.
+// Custom validation rules register their reason together with the rule; it must be called before serving requests.
+func RegisterReason(tag string, reason Reason) {
+	reasons[tag] = reason
+}
+
+// Problem is the error type of the API handlers. It is rendered as an RFC 7807 problem details document:
+// Type is always "about:blank", so Title is the text of the HTTP status, while Detail explains this
+// occurrence of the problem in human readable form. Code is the machine readable error code and Errors
+// lists the rejected fields of the request, if the problem is caused by invalid input.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// New creates a Problem with the given HTTP status, error code and detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Error returns the code and detail of the problem, so a Problem can be passed around as an error.
+func (p *Problem) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+// WithFields adds the given field errors to the problem and returns it.
+func (p *Problem) WithFields(fields ...FieldError) *Problem {
+	p.Errors = append(p.Errors, fields...)
+	return p
+}
+
+// FromError turns an error returned by the models into a Problem:
+//   - a *Problem is returned as is,
+//   - sql.ErrNoRows becomes a 404 Not Found with the code "not_found",
+//   - a violated database constraint (see db.IsConstraintViolation) becomes a 409 Conflict with the code "conflict",
+//   - every other error becomes a 500 Internal Server Error with the code "internal_error".
+//
+// The given detail is used for every status; the message of the original error is never exposed to the client.
+func FromError(err error, detail string) *Problem {
+	var problem *Problem
+	switch {
+	case errors.As(err, &problem):
+		return problem
+	case errors.Is(err, sql.ErrNoRows):
+		return New(http.StatusNotFound, CodeNotFound, detail)
+	case db.IsConstraintViolation(err):
+		return New(http.StatusConflict, CodeConflict, detail)
+	default:
+		return New(http.StatusInternalServerError, CodeInternal, detail)
+	}
+}
+
+// FromBindError turns an error of binding the request body or query string into a 400 Bad Request Problem.
+// If the request could be parsed but failed the binding rules of the target struct, the problem has the code
+// "validation_failed" and lists every rejected field with the violated rule; the same applies to a JSON value
+// of the wrong type, reported with the rule "type". Otherwise the request was malformed and the code is "invalid_request".
+func FromBindError(err error) *Problem {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return New(http.StatusBadRequest, CodeValidationFailed, "The request contains invalid fields.").
+			WithFields(FieldError{Field: typeError.Field, Rule: "type", Reason: "must be of type " + typeError.Type.String()})
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return New(http.StatusBadRequest, CodeInvalidRequest, "Could not parse request data.")
+	}
+
+	return New(http.StatusBadRequest, CodeValidationFailed, "The request contains invalid fields.").
+		WithFields(FieldErrors(validationErrors)...)
+}
+
+// FieldErrors converts the errors of the validator into field errors, one for every violated rule,
+// explained by the Reason registered for the rule.
+func FieldErrors(validationErrors validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		reason := "is invalid"
+		if explain, ok := reasons[fieldError.Tag()]; ok {
+			reason = explain(fieldError)
+		}
+		fields = append(fields, FieldError{Field: fieldError.Field(), Rule: fieldError.Tag(), Reason: reason})
+	}
+	return fields
+}
+
+// Respond aborts the request and writes the problem as the response, with the Content-Type application/problem+json.
+// The path of the request is set as the instance of the problem.
+//
+// Example usage:
+//
+//	event, err := models.GetEventByID(eventId)
+//	if err != nil {
+//	    problems.Respond(context, problems.FromError(err, "Could not fetch event."))
+//	    return
+//	}
+func Respond(context *gin.Context, problem *Problem) {
+	problem.Instance = context.Request.URL.Path
+	// gin only sets the Content-Type of a JSON response if the header is not set yet.
+	context.Header("Content-Type", ContentType)
+	context.AbortWithStatusJSON(problem.Status, problem)
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// apiKeyRequest is the JSON body expected by createAPIKey.
+type apiKeyRequest struct {
+	Name      string     `binding:"required,max=100"`
+	Scopes    []string   `binding:"max=50"`
+	ExpiresAt *time.Time `binding:"omitempty,future"`
+}
+
+// getAPIKeys returns the API keys of the authenticated user that were not revoked, without the keys themselves.
+// It returns a 500 Internal Server Error problem if the keys can not be fetched.
+func getAPIKeys(context *gin.Context) {
+	apiKeys, err := models.GetAPIKeys(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch API keys."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"apiKeys": apiKeys})
+}
+
+// createAPIKey creates an API key for the authenticated user with the Name, the optional Scopes and the optional
+// ExpiresAt given in the JSON body. Scopes must be permissions the user holds; without scopes the key has every
+// permission of the user. The key is returned as "key" only in this response, together with the stored "apiKey".
+// It returns a 400 Bad Request problem listing the invalid fields if the body is invalid or a scope is not a
+// permission of the user, and a 500 Internal Server Error problem if the key can not be created.
+func createAPIKey(context *gin.Context) {
+	var request apiKeyRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	permissions, err := models.GetPermissionsForRoles(context.GetStringSlice("roles"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not create API key."))
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !slices.Contains(permissions, scope) {
+			problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeValidationFailed, "The request contains invalid fields.").
+				WithFields(problems.FieldError{Field: "Scopes", Rule: "scope", Reason: "must only contain permissions you hold, " + scope + " is not one of them"}))
+			return
+		}
+	}
+	slices.Sort(request.Scopes)
+	request.Scopes = slices.Compact(request.Scopes)
+
+	userId := context.GetInt64("userId")
+	apiKey, key, err := models.CreateAPIKey(userId, request.Name, request.Scopes, request.ExpiresAt)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not create API key."))
+		return
+	}
+
+	recordAudit(context, "api_key.create", models.AuditTargetUser, userId, nil, apiKey)
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusCreated, gin.H{"message": "API key created", "key": key, "apiKey": apiKey})
+}
+
+// revokeAPIKey revokes the API key of the authenticated user whose ID is given in the URL; it stops working at once.
+// It returns a 400 Bad Request problem if the ID can not be parsed, a 404 Not Found problem if the user has no
+// such key or it was already revoked, and a 500 Internal Server Error problem if the key can not be revoked.
+func revokeAPIKey(context *gin.Context) {
+	id, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse API key id."))
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	err = models.RevokeAPIKey(userId, id)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not revoke API key."))
+		return
+	}
+
+	recordAudit(context, "api_key.revoke", models.AuditTargetUser, userId, gin.H{"APIKeyID": id}, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// getAuditLog returns the entries of the audit log, newest first, filtered by the query parameters "actorId",
+// "targetType" and "targetId", and "from" and "to" (RFC 3339) for the time range, see models.AuditFilter.
+// At most "limit" entries are returned; the next page is fetched by passing the ID of the last entry as "before".
+// It returns a 400 Bad Request problem listing the invalid parameters if the query string can not be bound,
+// and a 500 Internal Server Error problem if the entries can not be fetched.
+func getAuditLog(context *gin.Context) {
+	var filter models.AuditFilter
+	err := context.ShouldBindQuery(&filter)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	entries, err := models.GetAuditLog(filter)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the audit log."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// recordAudit appends a change made by the request to the audit log, with the authenticated user as the actor
+// and the ID set by middlewares.RequestID. before and after are the target before and after the change, nil if
+// it was created or removed; only the fields that differ are recorded, see models.RecordAudit.
+// The change has already been made when it is recorded, so a failure to record it is logged rather than
+// failing the request.
+func recordAudit(context *gin.Context, action, targetType string, targetId int64, before, after any) {
+	entry := models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		RequestID:  context.GetString("requestId"),
+	}
+	if userId, ok := context.Get("userId"); ok {
+		actorId := userId.(int64)
+		entry.ActorID = &actorId
+	}
+
+	err := models.RecordAudit(entry, before, after)
+	if err != nil {
+		log.Printf("Could not record %s of %s %d in the audit log: %v", action, targetType, targetId, err)
+	}
+}
+
+// eventAfter reloads the event with the given ID after a change to record it in the audit log, from the trash
+// if it was moved there. It returns nil if the event no longer exists or can not be loaded.
+func eventAfter(eventId int64) *models.Event {
+	event, err := models.GetEventByID(eventId)
+	if err != nil {
+		event, err = models.GetDeletedEventByID(eventId)
+	}
+	if err != nil {
+		return nil
+	}
+	return event
+}
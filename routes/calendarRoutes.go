@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/calendar"
+	"RestAPI/config"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedRefreshInterval is how often calendar applications are asked to poll a calendar feed.
+const feedRefreshInterval = time.Hour
+
+// publicURL returns the URL the API is reachable at from the outside, configured by PUBLIC_URL.
+// It is used for links handed out to clients, like the calendar feed URL, and never ends with a slash.
+func publicURL() string {
+	return strings.TrimSuffix(config.String("PUBLIC_URL", "http://localhost:8080"), "/")
+}
+
+// toCalendarEvent converts an event into a VEVENT, including the recurrence of a recurring event. The UID is
+// derived from the event ID and the host of publicURL, so it stays the same across exports and calendar
+// applications update their copy of the event. Cancelled events are marked as cancelled and drafts as tentative.
+func toCalendarEvent(event models.Event) calendar.Event {
+	return calendar.Event{
+		UID:            "event-" + strconv.FormatInt(event.ID, 10) + "@" + calendarHost(),
+		Summary:        event.Name,
+		Description:    event.Description,
+		Location:       event.Location,
+		Start:          event.DateTime,
+		End:            event.EndDateTime,
+		RecurrenceRule: event.RecurrenceRule,
+		ExceptionDates: event.ExceptionDates,
+		TimeZone:       event.TimeZone,
+		Status:         calendarStatus(event.Status),
+	}
+}
+
+// calendarStatus returns the VEVENT status of an event in the given lifecycle state.
+func calendarStatus(status string) string {
+	switch status {
+	case models.EventCancelled:
+		return calendar.StatusCancelled
+	case models.EventDraft:
+		return calendar.StatusTentative
+	}
+	return calendar.StatusConfirmed
+}
+
+// toCalendarOccurrence converts a single occurrence of a recurring event, see models.Event.Occurrence, into a
+// VEVENT of its own. Its UID adds the start of the occurrence to the UID of the event.
+func toCalendarOccurrence(occurrence models.Event) calendar.Event {
+	event := toCalendarEvent(occurrence)
+	event.UID = "event-" + strconv.FormatInt(occurrence.ID, 10) + "-" + strconv.FormatInt(occurrence.DateTime.Unix(), 10) + "@" + calendarHost()
+	event.RecurrenceRule = ""
+	event.ExceptionDates = nil
+	return event
+}
+
+// calendarHost returns the host of publicURL used in the UIDs of calendar events.
+func calendarHost() string {
+	if parsed, err := url.Parse(publicURL()); err == nil && parsed.Hostname() != "" {
+		return parsed.Hostname()
+	}
+	return "localhost"
+}
+
+// getEventCalendar responds with the event as an iCalendar document holding a single VEVENT.
+// It is served by getEvent for GET /events/:id.ics.
+func getEventCalendar(context *gin.Context, event models.Event) {
+	document := calendar.Calendar{Events: []calendar.Event{toCalendarEvent(event)}}
+	context.Header("Content-Disposition", `attachment; filename="event-`+strconv.FormatInt(event.ID, 10)+`.ics"`)
+	context.Data(http.StatusOK, calendar.ContentType, document.Encode())
+}
+
+// createCalendarFeed creates the calendar feed of the authenticated user and returns its URL.
+// The URL contains an unguessable token instead of relying on the Authorization header, which calendar
+// applications can not send, so it must be kept secret. Calling it again replaces the URL; the previous one stops working.
+// It returns a 500 Internal Server Error problem if the feed can not be created.
+func createCalendarFeed(context *gin.Context) {
+	userId := context.GetInt64("userId")
+
+	token, err := models.IssueCalendarFeedToken(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not create calendar feed."))
+		return
+	}
+	recordAudit(context, "calendar_feed.create", models.AuditTargetUser, userId, nil, nil)
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Calendar feed created", "url": publicURL() + "/calendar/" + token + ".ics"})
+}
+
+// deleteCalendarFeed revokes the calendar feed of the authenticated user, so its URL stops working.
+// It returns a 500 Internal Server Error problem if the feed can not be revoked.
+func deleteCalendarFeed(context *gin.Context) {
+	err := models.RevokeCalendarFeedToken(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not delete calendar feed."))
+		return
+	}
+	recordAudit(context, "calendar_feed.delete", models.AuditTargetUser, context.GetInt64("userId"), nil, nil)
+
+	context.JSON(http.StatusOK, gin.H{"message": "Calendar feed deleted"})
+}
+
+// getCalendarFeed serves the calendar feed identified by the token in the URL, with or without the .ics extension.
+// The feed lists every event the user is registered for, built on every request, so cancelled registrations
+// disappear and changed events are updated the next time the calendar application polls. Registrations on
+// the waitlist are marked as tentative, and cancelled events stay in the feed marked as cancelled. Registrations for an occurrence of a recurring event list just that occurrence.
+// It returns a 404 Not Found problem if the token is unknown or was revoked.
+func getCalendarFeed(context *gin.Context) {
+	token := strings.TrimSuffix(context.Param("token"), ".ics")
+
+	userId, err := models.GetCalendarFeedUser(token)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not find calendar feed."))
+		return
+	}
+
+	registered, err := models.GetRegisteredEvents(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch events."))
+		return
+	}
+
+	document := calendar.Calendar{Name: "Registered Events", RefreshInterval: feedRefreshInterval}
+	for _, registration := range registered {
+		event := toCalendarEvent(registration.Event)
+		if registration.Occurrence != nil {
+			event = toCalendarOccurrence(registration.Event)
+		}
+		if registration.Status == models.RegistrationWaitlisted && event.Status == calendar.StatusConfirmed {
+			event.Status = calendar.StatusTentative
+		}
+		document.Events = append(document.Events, event)
+	}
+
+	context.Header("Cache-Control", "private, no-cache")
+	context.Data(http.StatusOK, calendar.ContentType, document.Encode())
+}
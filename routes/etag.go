@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+)
+
+// errPreconditionRequired is returned by parseIfMatch if the request has no If-Match header.
+var errPreconditionRequired = errors.New("the If-Match header with the ETag of the event is required")
+
+// errPreconditionFailed is returned by parseIfMatch if the If-Match header holds no ETag of an event version.
+var errPreconditionFailed = errors.New("the If-Match header does not match the current ETag of the event")
+
+// Formats of event representations, which are part of their ETags, see eventETag.
+const (
+	etagJSON     = "json"
+	etagCalendar = "ics"
+)
+
+// eventETag returns the ETag of a representation of the event, a strong entity tag built from its version and
+// the format of the representation, followed by the time zone if the datetimes are rendered in another zone than
+// the one of the event, e.g. "2-ics", "2-json" or "2-json-Europe/Berlin". The version changes with every write
+// to the event, see models.Event.Version, and the format and zone tell apart the representations of one version.
+func eventETag(event models.Event, format, zone string) string {
+	etag := strconv.FormatInt(event.Version, 10) + "-" + format
+	if zone != "" {
+		etag += "-" + zone
+	}
+	return `"` + etag + `"`
+}
+
+// notModified reports whether the If-None-Match header of the request matches the ETag, in which case the client
+// already has the current representation. As required for If-None-Match, weak entity tags match as well.
+func notModified(context *gin.Context, etag string) bool {
+	header := context.GetHeader("If-None-Match")
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch returns the version of the event an update is based on, taken from the If-Match header of the
+// request. The ETag of any representation of a version matches that version, as they all render the same event.
+// "*" matches every version and gives 0, see models.Event.Update.
+// It returns errPreconditionRequired if the header is missing and errPreconditionFailed if it is not a single
+// strong entity tag of a version, as returned by eventETag.
+func parseIfMatch(context *gin.Context) (int64, error) {
+	header := strings.TrimSpace(context.GetHeader("If-Match"))
+	switch header {
+	case "":
+		return 0, errPreconditionRequired
+	case "*":
+		return 0, nil
+	}
+
+	value, quoted := strings.CutPrefix(header, `"`)
+	value, closed := strings.CutSuffix(value, `"`)
+	versionValue, _, _ := strings.Cut(value, "-")
+	version, err := strconv.ParseInt(versionValue, 10, 64)
+	if !quoted || !closed || strings.Contains(value, `"`) || err != nil || version <= 0 {
+		return 0, errPreconditionFailed
+	}
+	return version, nil
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testContext returns a context for a GET request with the header set to the value, if not empty.
+func testContext(header, value string) *gin.Context {
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest(http.MethodGet, "/events/1", nil)
+	if value != "" {
+		context.Request.Header.Set(header, value)
+	}
+	return context
+}
+
+func TestEventETagDiffersPerRepresentation(t *testing.T) {
+	event := models.Event{Version: 2}
+	tests := []struct {
+		format, zone string
+		want         string
+	}{
+		{etagJSON, "", `"2-json"`},
+		{etagJSON, "Europe/Berlin", `"2-json-Europe/Berlin"`},
+		{etagCalendar, "", `"2-ics"`},
+	}
+	for _, test := range tests {
+		got := eventETag(event, test.format, test.zone)
+		if got != test.want {
+			t.Errorf("eventETag(%q, %q) = %s, want %s", test.format, test.zone, got, test.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := eventETag(models.Event{Version: 2}, etagJSON, "")
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"2-json"`, true},
+		{`W/"2-json"`, true},
+		{`"1-json", "2-json"`, true},
+		{"*", true},
+		{`"2-ics"`, false},
+		{`"2-json-Europe/Berlin"`, false},
+		{`"1-json"`, false},
+	}
+	for _, test := range tests {
+		got := notModified(testContext("If-None-Match", test.ifNoneMatch), etag)
+		if got != test.want {
+			t.Errorf("notModified with If-None-Match %s = %v, want %v", test.ifNoneMatch, got, test.want)
+		}
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		ifMatch     string
+		wantVersion int64
+		wantErr     error
+	}{
+		{`"2-json"`, 2, nil},
+		{`"2-json-Europe/Berlin"`, 2, nil},
+		{`"2-ics"`, 2, nil},
+		{`"2"`, 2, nil},
+		{"*", 0, nil},
+		{"", 0, errPreconditionRequired},
+		{`W/"2-json"`, 0, errPreconditionFailed},
+		{`2-json`, 0, errPreconditionFailed},
+		{`"json"`, 0, errPreconditionFailed},
+		{`"0-json"`, 0, errPreconditionFailed},
+		{`"1-json", "2-json"`, 0, errPreconditionFailed},
+	}
+	for _, test := range tests {
+		version, err := parseIfMatch(testContext("If-Match", test.ifMatch))
+		if version != test.wantVersion || !errors.Is(err, test.wantErr) {
+			t.Errorf("parseIfMatch with If-Match %s = %d, %v, want %d, %v", test.ifMatch, version, err, test.wantVersion, test.wantErr)
+		}
+	}
+}
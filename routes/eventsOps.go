@@ -2,57 +2,131 @@ package routes
 
 import (
 	"RestAPI/Models"
+	"RestAPI/jsonpatch"
+	"RestAPI/middlewares"
+	"RestAPI/problems"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// getEvents retrieves all events from the database and returns them as a JSON response.
-// It calls the GetAllEvents function from the models package to fetch the events from the database.
-// If an error occurs during the database query, it returns a JSON response with a 500 Internal Server Error status.
-// Otherwise, it returns a JSON response with a 200 OK status and the events data.
+// zoneQuery is bound from the optional "tz" query parameter of the endpoints returning events. It names the IANA
+// time zone to render the datetimes of the events in instead of their own, see models.Event.InZone.
+type zoneQuery struct {
+	TimeZone string `form:"tz" binding:"omitempty,timezone"`
+}
+
+// getEvents retrieves a page of events from the database and returns it as a JSON response.
+// The filters, sort order, cursor and page size are bound from the query string into a models.EventFilter
+// and passed to models.ListEvents. The response holds the events and, if there are more, the nextCursor
+// to pass as the cursor parameter to fetch the next page.
+// Drafts are only listed for their authenticated owner; the status parameter lists only events in that state.
+// With tz=<IANA time zone>, the datetimes are rendered in that zone instead of the zone of each event.
+// With expand=true, every occurrence of a recurring event within from and to is listed as an event of its own.
+// If the query string can not be parsed, the cursor is invalid or expand is set without a valid window,
+// it returns a 400 Bad Request problem.
+// If an error occurs during the database query, it returns a 500 Internal Server Error problem.
+// Otherwise, it returns a JSON response with a 200 OK status and the page of events.
 func getEvents(context *gin.Context) {
-	events, err := models.GetAllEvents()
+	var filter models.EventFilter
+	err := context.ShouldBindQuery(&filter)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events. Try again later."})
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+	filter.ViewerID = context.GetInt64("userId")
+
+	page, err := models.ListEvents(filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidCursor, "Invalid cursor."))
 		return
 	}
-	context.JSON(http.StatusOK, events)
+	if errors.Is(err, models.ErrInvalidWindow) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidWindow, "Expanding occurrences requires from and to at most 366 days apart, sorted by dateTime."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch events. Try again later."))
+		return
+	}
+	context.JSON(http.StatusOK, page)
 }
 
 // getEvent retrieves an event from the database based on the provided event ID.
+// If the ID carries the .ics extension, as in GET /events/1.ics, the event is returned as an
+// iCalendar document instead (see getEventCalendar). The datetimes are rendered in the time zone of the event,
+// or in the one given by the "tz" query parameter, see zoneQuery. Drafts are only returned to their
+// authenticated owner; for anyone else they do not exist.
+// The response carries the ETag of the event in the requested format and zone (see eventETag), which updates
+// must send back in If-Match. If the If-None-Match header matches it, the client already has this representation
+// of the event and a 304 Not Modified without a body is returned instead.
 // It parses the event ID from the request URL and calls models.GetEventByID
 // to fetch the event from the database. If the event is found, it is returned
 // as a JSON response with status code OK (200). If the event ID cannot be parsed
-// or an error occurs during the fetching process, an appropriate error message
-// is returned as a JSON response with the corresponding status code (BadRequest
-// for parsing error, InternalServerError for fetching error).
+// or an error occurs during the fetching process, a problem is returned with the
+// corresponding status code (BadRequest for parsing error, NotFound if no event
+// has the ID, InternalServerError for any other fetching error).
 func getEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	id, isCalendar := strings.CutSuffix(context.Param("id"), ".ics")
+	eventId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
+		return
+	}
+
+	var zone zoneQuery
+	err = context.ShouldBindQuery(&zone)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		problems.Respond(context, problems.FromBindError(err))
 		return
 	}
 
 	event, err := models.GetEventByID(eventId)
 
+	if err == nil && !event.IsVisibleTo(context.GetInt64("userId")) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event."})
+		problems.Respond(context, problems.FromError(err, "Could not fetch event."))
 		return
 	}
 
-	context.JSON(http.StatusOK, event)
+	etag := eventETag(*event, etagJSON, zone.TimeZone)
+	if isCalendar {
+		etag = eventETag(*event, etagCalendar, "")
+	}
+	context.Header("ETag", etag)
+	if notModified(context, etag) {
+		context.Status(http.StatusNotModified)
+		return
+	}
+
+	if isCalendar {
+		getEventCalendar(context, *event)
+		return
+	}
+	context.JSON(http.StatusOK, event.InZone(zone.TimeZone))
 }
 
 // createEvent creates a new event based on the request data provided in the JSON body.
 // It first binds the JSON data to the event struct using ShouldBindJSON(). If there is an error parsing the data,
-// it returns a JSON response with a 400 Bad Request status code and an error message.
+// it returns a 400 Bad Request problem listing the invalid fields, if any, and so it does if the event does not
+// start in the future.
 // If the data is successfully parsed, it retrieves the user ID from the request context.
 // It sets the retrieved user ID as the UserID of the event struct.
 // Then, it calls the Save() method of the event, which saves the event to the database.
-// If there is an error saving the event, it returns a JSON response with a 500 Internal Server Error status code and an error message.
+// If there is an error saving the event, it returns a 500 Internal Server Error problem.
 // If the event is successfully saved, it returns a JSON response with a 201 Created status code,
 // a success message, and the event details in the response body.
+// The event is created as a draft unless the body sets its Status to "published"; drafts are only visible to
+// their owner and are published with changeEventStatus.
 // This function is typically used to handle the creation of events in the application.
 func createEvent(context *gin.Context) {
 
@@ -60,7 +134,11 @@ func createEvent(context *gin.Context) {
 	err := context.ShouldBindJSON(&event)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+	if problem := eventStartProblem(event, time.Time{}); problem != nil {
+		problems.Respond(context, problem)
 		return
 	}
 	userId := context.GetInt64("userId")
@@ -70,38 +148,67 @@ func createEvent(context *gin.Context) {
 	err = event.Save()
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create event. Try again later."})
+		problems.Respond(context, problems.FromError(err, "Could not create event. Try again later."))
 		return
 	}
+	recordAudit(context, "event.create", models.AuditTargetEvent, event.ID, nil, event)
 
 	context.JSON(http.StatusCreated, gin.H{"message": "Event created!", "event": event})
 }
 
 // updateEvent updates an event's details in the database based on the provided event ID.
-// It first parses the event ID from the request parameter. If parsing fails, it returns an error message.
+// It first parses the event ID from the request parameter. If parsing fails, it returns a 400 Bad Request problem.
+// It fetches the event from the database using the event ID. If no event has the ID, it returns a 404 Not Found problem.
 // It retrieves the user ID from the request context and compares it with the event's user ID.
-// If the user IDs do not match, it returns an unauthorized error message.
-// It fetches the event from the database using the event ID. If fetching fails, it returns an error message.
-// It binds the JSON data from the request body to the updatedEvent struct. If binding fails, it returns an error message.
-// It assigns the event ID to the updatedEvent struct and updates the event in the database.
-// If updating fails, it returns an error message.
-// Finally, it returns a success message if the event is updated successfully.
+// If the user IDs do not match and the user lacks the "events:update:any" permission, it returns a 403 Forbidden problem.
+// The If-Match header must hold the ETag of the event as returned by getEvent, so changes made in the meantime are
+// not overwritten: without it, it returns a 428 Precondition Required problem, and if it does not match the
+// current version of the event, a 412 Precondition Failed problem with the code precondition_failed.
+// "If-Match: *" updates the event whatever its version.
+// It binds the JSON data from the request body to the updatedEvent struct. If binding fails, it returns a 400 Bad Request problem listing the invalid fields.
+// It then saves the updated event with saveEventUpdate, which assigns the event ID and updates the event in the database.
+// For a recurring event, the "occurrence" and "scope" query parameters (see parseOccurrenceScope) apply the update
+// to a single occurrence or to an occurrence and all after it instead; the changed occurrences become a new event,
+// which is returned, see models.Event.UpdateOccurrences.
+// If the occurrence does not fit the event, it returns a 400 Bad Request problem with the code invalid_occurrence,
+// and if the update would make an event with registrations start or stop recurring or the event was cancelled
+// or completed, a 409 Conflict problem. The status of the event is not changed by updates, see changeEventStatus.
+// If updating fails, it returns a 500 Internal Server Error problem.
+// Finally, it returns a success message and the new ETag of the event if the event is updated successfully.
 func updateEvent(context *gin.Context) {
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
 		return
 	}
 	userId := context.GetInt64("userId")
 	event, err := models.GetEventByID(eventId)
 
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized to update event"})
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event."))
+		return
+	}
+
+	canModifyAny, err := middlewares.HasPermission(context, "events:update:any")
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check permissions."))
+		return
+	}
+
+	if event.UserID != userId && !canModifyAny {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Not Authorized to update event"))
+		return
+	}
+
+	occurrence, following, err := parseOccurrenceScope(context)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not update event."))
 		return
 	}
 
+	version, err := parseIfMatch(context)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event."})
+		problems.Respond(context, eventProblem(err, "Could not update event."))
 		return
 	}
 
@@ -109,56 +216,306 @@ func updateEvent(context *gin.Context) {
 	err = context.ShouldBindJSON(&updatedEvent)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+	updatedEvent.Version = version
+
+	saveEventUpdate(context, *event, updatedEvent, occurrence, following)
+}
+
+// saveEventUpdate saves the updated event in place of the event, or of its occurrence and, if following is set,
+// the occurrences after it, and responds with the result. It records the update in the audit log and returns
+// the new ETag of the event. It is shared by updateEvent and patchEvent, which have checked the ownership of
+// the event and bound the updated event, including the version it is based on.
+// If the update moves the start of the event or occurrence, the new start must be in the future, else it returns
+// a 400 Bad Request problem listing the DateTime as invalid, see models.ValidateEventStart.
+func saveEventUpdate(context *gin.Context, event, updatedEvent models.Event, occurrence *time.Time, following bool) {
+	previous := event.DateTime
+	if occurrence != nil {
+		previous = *occurrence
+	}
+	if problem := eventStartProblem(updatedEvent, previous); problem != nil {
+		problems.Respond(context, problem)
 		return
 	}
 
-	updatedEvent.ID = eventId
-	err = updatedEvent.Update()
+	if occurrence != nil {
+		occurrenceEvent, err := event.UpdateOccurrences(*occurrence, updatedEvent, following)
+		if err != nil {
+			problems.Respond(context, eventProblem(err, "Could not update event."))
+			return
+		}
+		series := eventAfter(event.ID)
+		recordAudit(context, "event.update", models.AuditTargetEvent, event.ID, event, series)
+		if series != nil {
+			context.Header("ETag", eventETag(*series, etagJSON, ""))
+		}
+		if occurrenceEvent.ID != event.ID {
+			recordAudit(context, "event.create", models.AuditTargetEvent, occurrenceEvent.ID, nil, occurrenceEvent)
+		}
+		context.JSON(http.StatusOK, gin.H{"message": "Event updated successfully!", "event": occurrenceEvent})
+		return
+	}
+
+	updatedEvent.ID = event.ID
+	err := updatedEvent.Update()
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update event."})
+		problems.Respond(context, eventProblem(err, "Could not update event."))
 		return
 	}
+	updated := eventAfter(event.ID)
+	recordAudit(context, "event.update", models.AuditTargetEvent, event.ID, event, updated)
+	if updated != nil {
+		context.Header("ETag", eventETag(*updated, etagJSON, ""))
+	}
 	context.JSON(http.StatusOK, gin.H{"message": "Event updated successfully!"})
 }
 
-// deleteEvent deletes an event from the database based on the event ID provided in the URL parameter.
+// patchEvent changes only the fields of an event given in the request body, so clients need not resend the whole
+// event like for updateEvent and do not overwrite fields they did not mean to touch. The body is either a JSON
+// Merge Patch (RFC 7396) with the Content-Type application/merge-patch+json, or a JSON Patch (RFC 6902) with
+// application/json-patch+json, applied to the event as returned by getEvent. For other media types it returns a
+// 415 Unsupported Media Type problem listing the supported ones in the Accept-Patch header.
+// The patched event must satisfy the same binding rules as the body of updateEvent, and ownership, If-Match and
+// the "occurrence" and "scope" query parameters are handled alike. For an occurrence, the patch is applied to
+// the occurrence without the recurrence rule and exception dates of the series, which the updated occurrences
+// inherit unless the patch sets them, see models.Event.UpdateOccurrences.
+// A malformed patch is a 400 Bad Request problem, and a patch that can not be applied, e.g. because a test
+// operation fails or a path does not exist, a 422 Unprocessable Entity problem with the code patch_failed.
+func patchEvent(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
+		return
+	}
+	event, err := models.GetEventByID(eventId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event."))
+		return
+	}
+
+	canModifyAny, err := middlewares.HasPermission(context, "events:update:any")
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check permissions."))
+		return
+	}
+
+	if event.UserID != context.GetInt64("userId") && !canModifyAny {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Not Authorized to update event"))
+		return
+	}
+
+	occurrence, following, err := parseOccurrenceScope(context)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not update event."))
+		return
+	}
+
+	version, err := parseIfMatch(context)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not update event."))
+		return
+	}
+
+	apply := jsonpatch.Merge
+	switch context.ContentType() {
+	case jsonpatch.MergePatchType:
+	case jsonpatch.JSONPatchType:
+		apply = jsonpatch.Apply
+	default:
+		context.Header("Accept-Patch", jsonpatch.MergePatchType+", "+jsonpatch.JSONPatchType)
+		problems.Respond(context, problems.New(http.StatusUnsupportedMediaType, problems.CodeUnsupportedMediaType, "The patch must be a JSON Merge Patch or a JSON Patch."))
+		return
+	}
+
+	patch, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not read the patch."))
+		return
+	}
+
+	base := *event
+	if occurrence != nil {
+		base = event.Occurrence(*occurrence)
+		base.RecurrenceRule = ""
+		base.ExceptionDates = nil
+	}
+	document, err := json.Marshal(base)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not update event."))
+		return
+	}
+
+	patched, err := apply(document, patch)
+	if errors.Is(err, jsonpatch.ErrInvalidPatch) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, err.Error()))
+		return
+	}
+	if errors.Is(err, jsonpatch.ErrPatchFailed) {
+		problems.Respond(context, problems.New(http.StatusUnprocessableEntity, problems.CodePatchFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not update event."))
+		return
+	}
+
+	var updatedEvent models.Event
+	err = json.Unmarshal(patched, &updatedEvent)
+	if err == nil {
+		err = binding.Validator.ValidateStruct(&updatedEvent)
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+	updatedEvent.Version = version
+
+	saveEventUpdate(context, *event, updatedEvent, occurrence, following)
+}
+
+// deleteEvent moves an event to the trash based on the event ID provided in the URL parameter.
+// Events in the trash are hidden everywhere but GET /me/trash, can be restored with POST /events/:id/restore
+// and are purged after the retention period, see models.StartTrashPurge.
 // It first parses the event ID from the URL parameter and checks for any parsing errors.
-// If there is an error, it sends an HTTP response with a 400 status code and an error message.
+// If there is an error, it sends a problem response with a 400 status code.
 // Then, it retrieves the user ID from the context and fetches the event details from the database
 // using the models.GetEventByID function.
-// If the event's user ID doesn't match the authenticated user ID, it sends an HTTP response with
-// a 401 status code and an error message indicating that the user is not authorized to delete the event.
-// If there is an error fetching the event details, it sends an HTTP response with a 500 status code
-// and an error message indicating the failure to fetch the event.
-// If all checks pass, it calls the Delete method on the event to delete it from the database.
-// If there is an error while deleting the event, it sends an HTTP response with a 500 status code
-// and an error message indicating the failure to delete the event.
+// If no event has the ID, it sends a problem response with a 404 status code, and if there is any other error
+// fetching the event details, with a 500 status code.
+// If the event's user ID doesn't match the authenticated user ID and the user lacks the "events:delete:any"
+// permission, it sends a problem response with a 403 status code indicating that
+// the user is not authorized to delete the event.
+// If all checks pass, it calls the Delete method on the event to move it to the trash.
+// For a recurring event, the "occurrence" and "scope" query parameters (see parseOccurrenceScope) delete a single
+// occurrence or an occurrence and all after it instead, see models.Event.DeleteOccurrences; an occurrence that
+// does not fit the event is a 400 Bad Request problem with the code invalid_occurrence.
+// A published event with registrations can not be deleted; it must be cancelled first so the registered users
+// are notified, otherwise a 409 Conflict problem with the code has_registrations is returned.
+// If there is an error while deleting the event, it sends a problem response with a 500 status code.
 // Finally, if the event is successfully deleted, it sends an HTTP response with a 200 status code
 // and a success message indicating the successful deletion of the event.
 func deleteEvent(context *gin.Context) {
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
 		return
 	}
 
 	userId := context.GetInt64("userId")
 	event, err := models.GetEventByID(eventId)
 
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized to delete event"})
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event."))
+		return
+	}
+
+	canModifyAny, err := middlewares.HasPermission(context, "events:delete:any")
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check permissions."))
 		return
 	}
 
+	if event.UserID != userId && !canModifyAny {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Not Authorized to delete event"))
+		return
+	}
+
+	occurrence, following, err := parseOccurrenceScope(context)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event."})
+		problems.Respond(context, eventProblem(err, "Could Not Delete Event"))
 		return
 	}
-	err = event.Delete()
+
+	if occurrence != nil {
+		err = event.DeleteOccurrences(*occurrence, following)
+	} else {
+		err = event.Delete()
+	}
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could Not Delete Event"})
+		problems.Respond(context, eventProblem(err, "Could Not Delete Event"))
 		return
 	}
+	recordAudit(context, "event.delete", models.AuditTargetEvent, eventId, event, eventAfter(eventId))
 	context.JSON(http.StatusOK, gin.H{"message": "Deleted Successfully"})
 }
+
+// searchEvents performs a full-text search over the name, description and location of events.
+// The search terms are taken from the "q" query parameter; "quoted phrases" match words in sequence and
+// a term ending in * matches every word starting with it. The optional "limit" parameter bounds the number
+// of results, and "tz" the time zone to render the events in (see zoneQuery). The results are ranked best first
+// and contain highlighted snippets of the matches. Drafts are only found for their authenticated owner.
+// It returns a 400 Bad Request problem if the query is empty or the limit can not be parsed,
+// a 503 Service Unavailable problem if the server was built without full-text search,
+// and a 500 Internal Server Error problem if the search fails.
+func searchEvents(context *gin.Context) {
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", "0"))
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse query parameters."))
+		return
+	}
+
+	var zone zoneQuery
+	err = context.ShouldBindQuery(&zone)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	results, err := models.SearchEvents(context.Query("q"), limit, context.GetInt64("userId"))
+	if errors.Is(err, models.ErrEmptySearch) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeEmptySearch, "Search query must not be empty."))
+		return
+	}
+	if errors.Is(err, models.ErrSearchUnavailable) {
+		problems.Respond(context, problems.New(http.StatusServiceUnavailable, problems.CodeSearchUnavailable, "Search is not available."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not search events. Try again later."))
+		return
+	}
+	for i := range results {
+		results[i].Event = results[i].Event.InZone(zone.TimeZone)
+	}
+	context.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// eventStartProblem returns a 400 Bad Request problem listing the DateTime of the event as invalid if it violates
+// models.ValidateEventStart for the start the event had before, or nil if it does not.
+func eventStartProblem(event models.Event, previous time.Time) *problems.Problem {
+	fieldError := models.ValidateEventStart(event, previous)
+	if fieldError == nil {
+		return nil
+	}
+	return problems.New(http.StatusBadRequest, problems.CodeValidationFailed, "The request contains invalid fields.").WithFields(*fieldError)
+}
+
+// eventProblem converts an error of an operation on an event into a problem: an occurrence that does not fit
+// the event is a 400 Bad Request with the code invalid_occurrence, and changing whether an event with
+// registrations recurs is a 409 Conflict. Violations of the event lifecycle are 409 Conflicts with the codes
+// invalid_transition, event_not_open, event_closed and has_registrations. Other errors are converted by
+// problems.FromError.
+func eventProblem(err error, detail string) *problems.Problem {
+	switch {
+	case errors.Is(err, errInvalidOccurrence), errors.Is(err, errInvalidScope), errors.Is(err, models.ErrOccurrenceRequired),
+		errors.Is(err, models.ErrNotRecurring), errors.Is(err, models.ErrNotAnOccurrence):
+		return problems.New(http.StatusBadRequest, problems.CodeInvalidOccurrence, err.Error())
+	case errors.Is(err, models.ErrRecurrenceChange):
+		return problems.New(http.StatusConflict, problems.CodeConflict, err.Error())
+	case errors.Is(err, models.ErrInvalidTransition):
+		return problems.New(http.StatusConflict, problems.CodeInvalidTransition, err.Error())
+	case errors.Is(err, models.ErrEventNotOpen):
+		return problems.New(http.StatusConflict, problems.CodeEventNotOpen, err.Error())
+	case errors.Is(err, models.ErrEventClosed):
+		return problems.New(http.StatusConflict, problems.CodeEventClosed, err.Error())
+	case errors.Is(err, models.ErrEventHasRegistrations):
+		return problems.New(http.StatusConflict, problems.CodeHasRegistrations, err.Error())
+	case errors.Is(err, errPreconditionRequired):
+		return problems.New(http.StatusPreconditionRequired, problems.CodeMissingPrecondition, err.Error())
+	case errors.Is(err, errPreconditionFailed), errors.Is(err, models.ErrVersionMismatch):
+		return problems.New(http.StatusPreconditionFailed, problems.CodePreconditionFailed, err.Error())
+	}
+	return problems.FromError(err, detail)
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxImportSize is the maximum size of an import file in bytes.
+const maxImportSize = 5 << 20
+
+// importEvents creates the events of an uploaded .ics or CSV file, owned by the authenticated user.
+// The file is either the request body or the "file" field of a multipart form. Its format is taken from the
+// "format" query parameter ("ics" or "csv"), or else from the Content-Type of the body or the extension of
+// the uploaded file. CSV columns are mapped to event fields with columns[Field]=header query parameters, e.g.
+// columns[Name]=title; unmapped fields are read from the column named like the field.
+// Every event is validated with the same rules as createEvent. If any event is invalid, nothing is saved and a
+// 400 Bad Request problem lists every invalid field together with the line of the file it was read from.
+// Otherwise all events are created in a single transaction and returned with a 201 Created status.
+// With dryRun=true nothing is saved; the response reports how many events are valid and lists the errors.
+// It returns a 400 Bad Request problem if the file can not be read and a 413 if it is larger than maxImportSize.
+func importEvents(context *gin.Context) {
+	userId := context.GetInt64("userId")
+	dryRun := context.Query("dryRun") == "true"
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxImportSize)
+
+	file, format, err := importFile(context)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problems.Respond(context, problems.New(http.StatusRequestEntityTooLarge, problems.CodeInvalidRequest, "The import file is too large."))
+			return
+		}
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not read the import file."))
+		return
+	}
+	defer file.Close()
+
+	var rows []models.ImportRow
+	switch format {
+	case "csv":
+		rows, err = models.ParseEventsCSV(file, context.QueryMap("columns"), userId)
+	case "ics":
+		rows, err = models.ParseEventsICS(file, userId)
+	default:
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "The import format must be ics or csv."))
+		return
+	}
+	if errors.Is(err, models.ErrInvalidImport) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not read the import file."))
+		return
+	}
+
+	events := []models.Event{}
+	fieldErrors := []problems.FieldError{}
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			fieldErrors = append(fieldErrors, row.Errors...)
+			continue
+		}
+		events = append(events, row.Event)
+	}
+
+	if dryRun {
+		context.JSON(http.StatusOK, gin.H{"message": "Dry run, no events imported", "valid": len(events), "invalid": len(rows) - len(events), "errors": fieldErrors})
+		return
+	}
+
+	if len(fieldErrors) > 0 {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeValidationFailed, "The import contains invalid events, no events were imported.").WithFields(fieldErrors...))
+		return
+	}
+
+	err = models.SaveEvents(events)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not import events. Try again later."))
+		return
+	}
+	for _, event := range events {
+		recordAudit(context, "event.import", models.AuditTargetEvent, event.ID, nil, event)
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Events imported!", "imported": len(events), "events": events})
+}
+
+// importFile returns the import file of the request together with its format as given by the "format" query
+// parameter, or else derived from the media type of the file or the extension of its name.
+func importFile(context *gin.Context) (io.ReadCloser, string, error) {
+	format := strings.ToLower(context.Query("format"))
+	mediaType, _, _ := mime.ParseMediaType(context.ContentType())
+
+	if mediaType != "multipart/form-data" {
+		if format == "" {
+			format = importFormat(mediaType, "")
+		}
+		return context.Request.Body, format, nil
+	}
+
+	header, err := context.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "" {
+		format = importFormat(header.Header.Get("Content-Type"), header.Filename)
+	}
+	return file, format, nil
+}
+
+// importFormat derives the format of an import file from its media type or, if that is not conclusive,
+// from the extension of its file name. It returns an empty string if neither tells.
+func importFormat(mediaType, filename string) string {
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+	switch {
+	case mediaType == "text/calendar":
+		return "ics"
+	case mediaType == "text/csv":
+		return "csv"
+	}
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".ics":
+		return "ics"
+	case ".csv":
+		return "csv"
+	}
+	return ""
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"RestAPI/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// getJWKS returns the public keys used to sign access tokens as a JSON Web Key Set (RFC 7517).
+// Other services fetch this document to verify tokens issued by this API, selecting the key by the
+// kid header of the token. Retired keys stay listed as long as they are configured for verification.
+func getJWKS(context *gin.Context) {
+	context.Header("Cache-Control", "public, max-age=300")
+	context.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// setRetryAfter sets the Retry-After header to the delay in whole seconds, rounded up.
+func setRetryAfter(context *gin.Context, delay time.Duration) {
+	context.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+}
+
+// loginThrottled responds with a problem and returns true if logins to the account with the email or from the
+// client IP are locked after too many failures, see models.CheckLoginThrottle. A locked IP gets a 429 Too Many
+// Requests problem with the code "too_many_requests" and a locked account a 423 Locked problem with the code
+// "account_locked", both with a Retry-After header.
+func loginThrottled(context *gin.Context, email string) bool {
+	err := models.CheckLoginThrottle(email, context.ClientIP())
+	if err == nil {
+		return false
+	}
+
+	var throttled *models.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return true
+	}
+	setRetryAfter(context, throttled.RetryAfter)
+	if throttled.Scope == models.ThrottleIP {
+		problems.Respond(context, problems.New(http.StatusTooManyRequests, problems.CodeTooManyRequests, "Too many failed logins, please try again later."))
+	} else {
+		problems.Respond(context, problems.New(http.StatusLocked, problems.CodeAccountLocked, "The account is locked after too many failed logins, please try again later."))
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login to the account with the email from the client IP. The login fails
+// anyway, so an error is only logged.
+func recordLoginFailure(context *gin.Context, email string) {
+	err := models.RecordLoginFailure(email, context.ClientIP())
+	if err != nil {
+		log.Printf("Could not record the failed login from %s: %v", context.ClientIP(), err)
+	}
+}
+
+// unlockAccount lifts the lock of the account of the user whose ID is given in the URL after too many failed
+// logins, and forgets its failed logins.
+// It returns a 400 Bad Request problem if the user ID can not be parsed, a 404 Not Found problem if the user
+// does not exist and a 500 Internal Server Error problem if the account can not be unlocked.
+func unlockAccount(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse user id."))
+		return
+	}
+
+	err = models.UnlockAccount(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not unlock the account."))
+		return
+	}
+	recordAudit(context, "user.unlock", models.AuditTargetUser, userId, nil, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// getNotifications returns the most recent notifications of the authenticated user, newest first,
+// e.g. about cancelled events the user registered for.
+// It returns a 500 Internal Server Error problem if the notifications can not be fetched.
+func getNotifications(context *gin.Context) {
+	notifications, err := models.GetNotifications(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch notifications."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+// readNotification marks a notification of the authenticated user as read.
+// It returns a 400 Bad Request problem if the ID can not be parsed and a 404 Not Found problem if the user
+// has no notification with the ID.
+func readNotification(context *gin.Context) {
+	id, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse notification id."))
+		return
+	}
+
+	err = models.MarkNotificationRead(context.GetInt64("userId"), id)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not mark the notification as read."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Notification read"})
+}
@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/config"
+	"RestAPI/problems"
+	"RestAPI/sso"
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// oidcStateCookie is the cookie that binds a login with the identity provider to the browser it was started in,
+// so a callback can not be completed with the state of a login started by someone else.
+const oidcStateCookie = "oidc_state"
+
+// oidcRedirectURL returns the URL the identity provider redirects back to after a login, configured by
+// OIDC_REDIRECT_URL (PUBLIC_URL + "/auth/oidc/callback" by default). It must be registered at the provider.
+func oidcRedirectURL() string {
+	return config.String("OIDC_REDIRECT_URL", publicURL()+"/auth/oidc/callback")
+}
+
+// setOIDCStateCookie sets the state cookie to the value for maxAge seconds, or removes it if maxAge is negative.
+// The cookie is only sent back on the top-level redirect from the provider and, if the API is served over HTTPS,
+// only over HTTPS.
+func setOIDCStateCookie(context *gin.Context, value string, maxAge int) {
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(oidcStateCookie, value, maxAge, "/auth/oidc", "", strings.HasPrefix(publicURL(), "https://"), true)
+}
+
+// oidcLogin starts a login with the identity provider configured by OIDC_ISSUER. It stores the state, the nonce
+// and the PKCE code verifier of the login, see models.StartOIDCLogin, and redirects the browser to the
+// provider, which redirects back to oidcCallback once the user signed in. The optional "login_hint" query
+// parameter, e.g. the email of the user, is passed on to the provider.
+// It returns a 404 Not Found problem if no identity provider is configured, a 502 Bad Gateway problem with the
+// code "identity_provider_unavailable" if the provider can not be reached and a 500 Internal Server Error problem
+// if the login can not be stored.
+func oidcLogin(context *gin.Context) {
+	if !sso.Enabled() {
+		problems.Respond(context, problems.New(http.StatusNotFound, problems.CodeNotFound, "Login with an identity provider is not configured."))
+		return
+	}
+
+	verifier := sso.NewVerifier()
+	state, nonce, err := models.StartOIDCLogin(verifier)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not start the login."))
+		return
+	}
+
+	authURL, err := sso.AuthCodeURL(oidcRedirectURL(), state, nonce, verifier, context.Query("login_hint"))
+	if err != nil {
+		log.Printf("Could not start a login with the identity provider: %v", err)
+		problems.Respond(context, problems.New(http.StatusBadGateway, problems.CodeProviderUnavailable, "The identity provider is unavailable, please try again later."))
+		return
+	}
+
+	setOIDCStateCookie(context, state, int(models.OIDCLoginTTL().Seconds()))
+	context.Header("Cache-Control", "no-store")
+	context.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallback completes a login with the identity provider, which redirects the browser here with the "state"
+// and the "code" of the login started by oidcLogin. The state has to match the state cookie and a stored login,
+// which can only be used once. The code is redeemed with the PKCE code verifier of the login, and the ID token
+// verified including its nonce, see sso.Exchange.
+// The identity is then signed in with models.SignInWithIdentity: by the user it was linked to earlier, else linked
+// to the user with the verified email, else a new user is created. The response is the same as for login, so the
+// user gets the same tokens and still needs the second factor if two-factor authentication is enabled.
+// It returns a 404 Not Found problem if no identity provider is configured, a 401 Unauthorized problem with the
+// code "oidc_login_failed" if the provider denied the login, the state is invalid or expired, or the code or the
+// ID token is rejected, a 403 Forbidden problem with the code "email_not_verified" if the provider has not
+// verified the email of a new identity, a 403 Forbidden problem if no user has the email and OIDC_CREATE_USERS
+// is off, a 409 Conflict problem with the code "email_taken" if the user is created concurrently, and a 502 Bad
+// Gateway problem with the code "identity_provider_unavailable" if the provider can not be reached.
+func oidcCallback(context *gin.Context) {
+	if !sso.Enabled() {
+		problems.Respond(context, problems.New(http.StatusNotFound, problems.CodeNotFound, "Login with an identity provider is not configured."))
+		return
+	}
+
+	cookie, _ := context.Cookie(oidcStateCookie)
+	setOIDCStateCookie(context, "", -1)
+	context.Header("Cache-Control", "no-store")
+
+	if providerError := context.Query("error"); providerError != "" {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeOIDCLoginFailed, "The identity provider denied the login: "+providerError+"."))
+		return
+	}
+
+	state := context.Query("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookie)) != 1 {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeOIDCLoginFailed, "The login was started in another browser or expired, please log in again."))
+		return
+	}
+	nonce, verifier, err := models.ConsumeOIDCLogin(state)
+	if errors.Is(err, models.ErrInvalidOIDCLogin) {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeOIDCLoginFailed, "The login expired, please log in again."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	identity, err := sso.Exchange(context.Request.Context(), oidcRedirectURL(), context.Query("code"), nonce, verifier)
+	if errors.Is(err, sso.ErrUnavailable) {
+		log.Printf("Could not complete a login with the identity provider: %v", err)
+		problems.Respond(context, problems.New(http.StatusBadGateway, problems.CodeProviderUnavailable, "The identity provider is unavailable, please try again later."))
+		return
+	}
+	if err != nil {
+		log.Printf("Rejected a login with the identity provider: %v", err)
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeOIDCLoginFailed, "Could not authenticate user."))
+		return
+	}
+
+	user, outcome, err := models.SignInWithIdentity(identity.Issuer, identity.Subject, identity.Email, identity.EmailVerified)
+	switch {
+	case errors.Is(err, models.ErrIdentityEmailNotVerified):
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeEmailNotVerified, "The identity provider has not verified your email."))
+		return
+	case errors.Is(err, models.ErrNoAccount):
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "There is no account for your email."))
+		return
+	case errors.Is(err, models.ErrEmailTaken):
+		problems.Respond(context, problems.New(http.StatusConflict, problems.CodeEmailTaken, "A user with this email already exists."))
+		return
+	case err != nil:
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	identityAudit := gin.H{"Issuer": identity.Issuer, "Subject": identity.Subject, "Email": identity.Email}
+	switch outcome {
+	case models.IdentityCreated:
+		recordAudit(context, "user.create", models.AuditTargetUser, user.ID, nil, gin.H{"Email": user.Email})
+		recordAudit(context, "user.identity_link", models.AuditTargetUser, user.ID, nil, identityAudit)
+	case models.IdentityLinked:
+		recordAudit(context, "user.identity_link", models.AuditTargetUser, user.ID, nil, identityAudit)
+	}
+
+	completeLogin(context, *user)
+}
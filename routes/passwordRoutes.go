@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/config"
+	"RestAPI/mail"
+	"RestAPI/problems"
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type forgotPasswordRequest struct {
+	Email string `binding:"required,email,max=254"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `binding:"required"`
+	Password string `binding:"required,password"`
+}
+
+// forgotPassword mails a password reset link to the user with the email given in the request body.
+// The link points to PASSWORD_RESET_URL (PUBLIC_URL + "/password/reset" by default) with the reset token in the
+// "token" query parameter; the page it leads to posts the token and a new password to resetPassword.
+// The response is the same 202 Accepted whether or not the email is registered, so it does not reveal which
+// emails have an account. The email is sent in the background, but the token is stored before responding,
+// so requests for registered emails take a little longer.
+// It returns a 400 Bad Request problem if the body is not a valid email and a 500 Internal Server Error problem
+// if the token can not be created.
+func forgotPassword(context *gin.Context) {
+	var request forgotPasswordRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId, token, err := models.IssuePasswordResetToken(request.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		problems.Respond(context, problems.FromError(err, "Could not request a password reset."))
+		return
+	}
+	if err == nil {
+		link := config.String("PASSWORD_RESET_URL", publicURL()+"/password/reset") + "?token=" + url.QueryEscape(token)
+		mail.SendAsync(mail.Message{
+			To:      request.Email,
+			Subject: "Reset your password",
+			Body: "Someone asked to reset the password of your account. If it was you, follow this link within " +
+				formatTTL(models.PasswordResetTTL()) + " to choose a new password:\n\n" + link + "\n\n" +
+				"If you did not ask for it, you can ignore this email; your password stays unchanged.\n",
+		})
+		recordAudit(context, "user.password_forgot", models.AuditTargetUser, userId, nil, nil)
+	}
+
+	context.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent to it."})
+}
+
+// resetPassword sets a new password with a token mailed by forgotPassword. The token can only be used once and
+// expires after PASSWORD_RESET_TTL. Resetting the password ends every login session of the user, so their
+// refresh tokens stop working.
+// It returns a 400 Bad Request problem listing the invalid fields if the token is missing or the password too weak,
+// a 400 Bad Request problem with the code invalid_reset_token if the token is unknown, expired or used, and a
+// 500 Internal Server Error problem if the password can not be changed.
+func resetPassword(context *gin.Context) {
+	var request resetPasswordRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId, err := models.ResetPassword(request.Token, request.Password)
+	if errors.Is(err, models.ErrInvalidResetToken) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidResetToken, err.Error()))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not reset the password."))
+		return
+	}
+
+	recordAudit(context, "user.password_reset", models.AuditTargetUser, userId, nil, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "Password reset, please log in again."})
+}
+
+// formatTTL formats how long a mailed link is valid in words, e.g. "1 hour 30 minutes". Parts that are zero
+// are left out and seconds are rounded up to a whole minute.
+func formatTTL(ttl time.Duration) string {
+	minutes := int64((ttl + time.Minute - 1) / time.Minute)
+	var parts []string
+	for _, unit := range []struct {
+		name    string
+		minutes int64
+	}{{"hour", 60}, {"minute", 1}} {
+		count := minutes / unit.minutes
+		minutes %= unit.minutes
+		switch {
+		case count == 1:
+			parts = append(parts, "1 "+unit.name)
+		case count > 1:
+			parts = append(parts, strconv.FormatInt(count, 10)+" "+unit.name+"s")
+		}
+	}
+	if len(parts) == 0 {
+		return "0 minutes"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTTL(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Minute:                "10 minutes",
+		30 * time.Minute:                "30 minutes",
+		time.Minute:                     "1 minute",
+		time.Hour:                       "1 hour",
+		90 * time.Minute:                "1 hour 30 minutes",
+		time.Hour + time.Minute:         "1 hour 1 minute",
+		48 * time.Hour:                  "48 hours",
+		20*time.Minute + 30*time.Second: "21 minutes",
+		0:                               "0 minutes",
+	}
+	for ttl, want := range tests {
+		if got := formatTTL(ttl); got != want {
+			t.Errorf("formatTTL(%v) = %q, want %q", ttl, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// The errors of parsing the occurrence query parameters.
+var (
+	errInvalidOccurrence = errors.New("the occurrence must be the RFC 3339 start of an occurrence")
+	errInvalidScope      = errors.New(`the scope must be "this" or "following" and requires an occurrence`)
+)
+
+// parseOccurrence parses the optional "occurrence" query parameter, the RFC 3339 start of an occurrence of a
+// recurring event, e.g. ?occurrence=2030-01-07T09:00:00Z. It returns nil if the parameter is not set.
+func parseOccurrence(context *gin.Context) (*time.Time, error) {
+	value, ok := context.GetQuery("occurrence")
+	if !ok {
+		return nil, nil
+	}
+	occurrence, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errInvalidOccurrence
+	}
+	return &occurrence, nil
+}
+
+// parseOccurrenceScope parses the query parameters selecting the occurrences of a recurring event an update or
+// deletion applies to: the "occurrence" (see parseOccurrence) and the "scope", which is "this" for just that
+// occurrence, the default, or "following" for that occurrence and all after it. The returned occurrence is nil
+// if the change applies to the whole event.
+func parseOccurrenceScope(context *gin.Context) (*time.Time, bool, error) {
+	occurrence, err := parseOccurrence(context)
+	if err != nil {
+		return nil, false, err
+	}
+
+	scope, ok := context.GetQuery("scope")
+	if ok && (occurrence == nil || scope != "this" && scope != "following") {
+		return nil, false, errInvalidScope
+	}
+	return occurrence, scope == "following", nil
+}
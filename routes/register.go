@@ -2,6 +2,7 @@ package routes
 
 import (
 	models "RestAPI/Models"
+	"RestAPI/problems"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -10,52 +11,80 @@ import (
 // registerForEvents is a handler function that registers a user for a specific event.
 // It expects a `userId` parameter to be set in the request context and an `id` parameter
 // in the URL path which represents the event id. It fetches the event by the provided id,
-// registers the user for the event, and returns a success message or an error message if any
-// error occurs during the process.
+// registers the user for the event, and returns the registration or a problem if any
+// error occurs during the process, with a 404 Not Found status if the event does not exist. If the event is full, the user is put on the waitlist and the
+// response contains the "waitlisted" status together with the user's position on the waitlist.
+// Recurring events are registered for per occurrence, given by the "occurrence" query parameter; a missing or
+// unknown occurrence is a 400 Bad Request problem with the code invalid_occurrence.
 func registerForEvents(context *gin.Context) {
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
 		return
 	}
 
 	event, err := models.GetEventByID(eventId)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event"})
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event"))
 		return
 	}
 
-	err = event.Register(userId)
+	occurrence, err := parseOccurrence(context)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register user for event"})
+		problems.Respond(context, eventProblem(err, "Could not register user for event"))
 		return
 	}
-	context.JSON(http.StatusCreated, gin.H{"message": "Event Registered"})
+
+	registration, err := event.Register(userId, occurrence)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not register user for event"))
+		return
+	}
+	recordAudit(context, "registration.create", models.AuditTargetEvent, eventId, nil, registration)
+
+	if registration.Status == models.RegistrationWaitlisted {
+		context.JSON(http.StatusCreated, gin.H{"message": "Event Full, Added To Waitlist", "registration": registration})
+		return
+	}
+	context.JSON(http.StatusCreated, gin.H{"message": "Event Registered", "registration": registration})
 }
 
 // cancelRegistration cancels the registration of a user for an event.
 // It retrieves the userId from the context and the eventId from the URL parameter.
-// If the eventId cannot be parsed, it returns an error response.
-// It creates a new Event struct with the retrieved eventId.
-// It then calls the CancelRegistration method on the event, passing the userId, to cancel the registration.
-// If the cancellation fails, it returns an error response.
+// If the eventId cannot be parsed, it returns a 400 Bad Request problem.
+// It fetches the event, returning a 404 Not Found problem if it does not exist, and then calls the
+// CancelRegistration method on the event, passing the userId and, for recurring events, the occurrence
+// given by the "occurrence" query parameter, to cancel the registration.
+// A seat freed by the cancellation is given to the next user on the waitlist.
+// If the occurrence does not fit the event, it returns a 400 Bad Request problem with the code invalid_occurrence.
+// If the cancellation fails, it returns a 500 Internal Server Error problem.
 // Finally, it returns a success response indicating the event registration was cancelled successfully.
 func cancelRegistration(context *gin.Context) {
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
 		return
 	}
 
-	var event models.Event
-	event.ID = eventId
+	event, err := models.GetEventByID(eventId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event"))
+		return
+	}
+
+	occurrence, err := parseOccurrence(context)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not cancel registration"))
+		return
+	}
 
-	err = event.CancelRegistration(userId)
+	err = event.CancelRegistration(userId, occurrence)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not cancel registration"})
+		problems.Respond(context, eventProblem(err, "Could not cancel registration"))
 		return
 	}
+	recordAudit(context, "registration.cancel", models.AuditTargetEvent, eventId, gin.H{"UserID": userId, "Occurrence": occurrence}, nil)
 	context.JSON(http.StatusOK, gin.H{"message": "Event Registration Cancelled"})
 }
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// roleRequest is the JSON body expected by grantRole.
+type roleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// roleTwoFactorRequest is the JSON body expected by setRoleTwoFactor.
+type roleTwoFactorRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// roleTwoFactorAudit is how setRoleTwoFactor records a change in the audit log. Only the value after it names
+// the role, so the role is kept in the entry although it does not change.
+type roleTwoFactorAudit struct {
+	Role     string `json:",omitempty"`
+	Required bool
+}
+
+// getRoles returns every role together with the permissions it grants.
+// If an error occurs during the database query, it returns a 500 Internal Server Error problem.
+func getRoles(context *gin.Context) {
+	roles, err := models.GetAllRoles()
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch roles."))
+		return
+	}
+	context.JSON(http.StatusOK, roles)
+}
+
+// getUserRoles returns the roles held by the user whose ID is given in the URL.
+// It returns a 400 Bad Request problem if the user ID can not be parsed, a 404 Not Found problem if the user
+// does not exist and a 500 Internal Server Error problem if the user or the roles can not be fetched.
+func getUserRoles(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse user id."))
+		return
+	}
+
+	_, err = models.GetUserByID(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not find user."))
+		return
+	}
+
+	roles, err := models.GetUserRoles(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch roles."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"userId": userId, "roles": roles})
+}
+
+// grantRole gives the role named in the JSON body to the user whose ID is given in the URL.
+// The change reaches the user's access token the next time it is refreshed.
+// It returns a 400 Bad Request problem if the user ID or body can not be parsed or the role does not exist
+// (with the code "unknown_role"), and a 404 Not Found problem if the user does not exist.
+func grantRole(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse user id."))
+		return
+	}
+
+	var request roleRequest
+	err = context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	_, err = models.GetUserByID(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not find user."))
+		return
+	}
+
+	err = models.GrantRole(userId, request.Role)
+	if errors.Is(err, models.ErrUnknownRole) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeUnknownRole, "Could not grant role."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not grant role."))
+		return
+	}
+	recordAudit(context, "role.grant", models.AuditTargetUser, userId, nil, request)
+	context.JSON(http.StatusOK, gin.H{"message": "Role granted"})
+}
+
+// revokeRole removes the role given in the URL from the user whose ID is given in the URL.
+// It returns a 400 Bad Request problem if the user ID can not be parsed and
+// a 500 Internal Server Error problem if the role can not be revoked.
+func revokeRole(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse user id."))
+		return
+	}
+
+	err = models.RevokeRole(userId, context.Param("role"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not revoke role."))
+		return
+	}
+	recordAudit(context, "role.revoke", models.AuditTargetUser, userId, roleRequest{Role: context.Param("role")}, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "Role revoked"})
+}
+
+// setRoleTwoFactor sets whether the users holding the role given in the URL must enable two-factor authentication,
+// as given by "required" in the JSON body. Users of a role that requires it who have not enabled it can only
+// enroll until they do (see middlewares.RequireTwoFactor), and can not disable it.
+// It returns a 400 Bad Request problem if the body can not be parsed, a 404 Not Found problem if the role does not
+// exist and a 500 Internal Server Error problem if the role can not be changed.
+func setRoleTwoFactor(context *gin.Context) {
+	var request roleTwoFactorRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	role := context.Param("role")
+	previous, err := models.SetRoleTwoFactor(role, *request.Required)
+	if errors.Is(err, models.ErrUnknownRole) {
+		problems.Respond(context, problems.New(http.StatusNotFound, problems.CodeNotFound, "Could not find role."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not change role."))
+		return
+	}
+	recordAudit(context, "role.two_factor", models.AuditTargetRole, 0, roleTwoFactorAudit{Required: previous},
+		roleTwoFactorAudit{Role: role, Required: *request.Required})
+	context.JSON(http.StatusOK, gin.H{"message": "Role changed"})
+}
@@ -7,17 +7,65 @@ import (
 
 // RegisterRoutes registers all routes for the server
 func RegisterRoutes(server *gin.Engine) {
-	server.GET("/events", getEvents)
-	server.GET("/events/:id", getEvent)
+	server.Use(middlewares.RequestID)
+
+	server.GET("/events", middlewares.IdentifyUser, getEvents)
+	server.GET("/events/search", middlewares.IdentifyUser, searchEvents)
+	server.GET("/events/:id", middlewares.IdentifyUser, getEvent)
 
 	authenticated := server.Group("/")
-	authenticated.Use(middlewares.Authenticate)
-	authenticated.POST("/events", createEvent)
-	authenticated.PUT("/events/:id", updateEvent)
-	authenticated.DELETE("/events/:id", deleteEvent)
-	authenticated.POST("/events/:id/register", registerForEvents)
+	authenticated.Use(middlewares.Authenticate, middlewares.RequireTwoFactor)
+	authenticated.POST("/events", middlewares.RequirePermission("events:create"), middlewares.RequireVerifiedEmail, createEvent)
+	authenticated.POST("/events/import", middlewares.RequirePermission("events:create"), middlewares.RequireVerifiedEmail, importEvents)
+	authenticated.PUT("/events/:id", middlewares.RequirePermission("events:update:own", "events:update:any"), updateEvent)
+	authenticated.PATCH("/events/:id", middlewares.RequirePermission("events:update:own", "events:update:any"), patchEvent)
+	authenticated.DELETE("/events/:id", middlewares.RequirePermission("events:delete:own", "events:delete:any"), deleteEvent)
+	authenticated.POST("/events/:id/restore", middlewares.RequirePermission("events:delete:own", "events:delete:any"), restoreEvent)
+	authenticated.POST("/events/:id/status", middlewares.RequirePermission("events:update:own", "events:update:any"), changeEventStatus)
+	authenticated.POST("/events/:id/register", middlewares.RequirePermission("events:register"), middlewares.RequireVerifiedEmail, registerForEvents)
 	authenticated.DELETE("/events/:id/register", cancelRegistration)
+	authenticated.POST("/me/calendar-feed", createCalendarFeed)
+	authenticated.DELETE("/me/calendar-feed", deleteCalendarFeed)
+	authenticated.GET("/me/notifications", getNotifications)
+	authenticated.GET("/me/trash", getTrash)
+	authenticated.POST("/verify-email/resend", resendVerificationEmail)
+	authenticated.POST("/me/notifications/:id/read", readNotification)
+	authenticated.GET("/me/api-keys", middlewares.RequireSession, getAPIKeys)
+	authenticated.POST("/me/api-keys", middlewares.RequireSession, createAPIKey)
+	authenticated.DELETE("/me/api-keys/:id", middlewares.RequireSession, revokeAPIKey)
+
+	authenticated.GET("/audit", middlewares.RequirePermission("audit:read"), getAuditLog)
+
+	admin := authenticated.Group("/")
+	admin.Use(middlewares.RequirePermission("roles:manage"))
+	admin.GET("/roles", getRoles)
+	admin.GET("/users/:id/roles", getUserRoles)
+	admin.POST("/users/:id/roles", grantRole)
+	admin.DELETE("/users/:id/roles/:role", revokeRole)
+	admin.PUT("/roles/:role/two-factor", setRoleTwoFactor)
+	admin.DELETE("/users/:id/lockout", unlockAccount)
+
+	// Users who are required to enable two-factor authentication can still set it up.
+	twoFactor := server.Group("/me/2fa")
+	twoFactor.Use(middlewares.Authenticate, middlewares.RequireSession)
+	twoFactor.GET("", getTwoFactor)
+	twoFactor.POST("", enrollTwoFactor)
+	twoFactor.DELETE("", disableTwoFactor)
+	twoFactor.GET("/qrcode", getTwoFactorQRCode)
+	twoFactor.POST("/confirm", confirmTwoFactor)
+	twoFactor.POST("/recovery-codes", regenerateRecoveryCodes)
 
 	server.POST("/signup", signup)
+	server.GET("/verify-email", verifyEmail)
+	server.POST("/verify-email", verifyEmail)
 	server.POST("/login", login)
+	server.POST("/login/2fa", loginTwoFactor)
+	server.GET("/auth/oidc/login", oidcLogin)
+	server.GET("/auth/oidc/callback", oidcCallback)
+	server.POST("/token/refresh", refreshAccessToken)
+	server.POST("/logout", logout)
+	server.POST("/password/forgot", forgotPassword)
+	server.POST("/password/reset", resetPassword)
+	server.GET("/.well-known/jwks.json", getJWKS)
+	server.GET("/calendar/:token", getCalendarFeed)
 }
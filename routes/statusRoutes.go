@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/middlewares"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// statusChange is the body of changeEventStatus. Reason is optional and added to the notifications sent
+// when an event is cancelled.
+type statusChange struct {
+	Status string `binding:"required,oneof=published cancelled completed"`
+	Reason string `binding:"max=500"`
+}
+
+// changeEventStatus moves an event through its lifecycle: a draft is published, a published event is completed,
+// and any event can be cancelled. Cancelling keeps the event and its registrations and notifies every registered
+// user instead, see models.SetEventStatus.
+// Like updateEvent, it requires the caller to own the event or hold the "events:update:any" permission,
+// otherwise it returns a 403 Forbidden problem.
+// It returns a 400 Bad Request problem if the body is invalid, a 404 Not Found problem if the event does not
+// exist, and a 409 Conflict problem with the code invalid_transition if the event can not change to the status.
+// Otherwise it returns the changed event with a 200 OK status.
+func changeEventStatus(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
+		return
+	}
+	userId := context.GetInt64("userId")
+	event, err := models.GetEventByID(eventId)
+
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event."))
+		return
+	}
+
+	canModifyAny, err := middlewares.HasPermission(context, "events:update:any")
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check permissions."))
+		return
+	}
+
+	if event.UserID != userId && !canModifyAny {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Not Authorized to change the status of the event"))
+		return
+	}
+
+	var change statusChange
+	err = context.ShouldBindJSON(&change)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	changed, err := models.SetEventStatus(eventId, change.Status, change.Reason)
+	if err != nil {
+		problems.Respond(context, eventProblem(err, "Could not change the status of the event."))
+		return
+	}
+	recordAudit(context, "event.status", models.AuditTargetEvent, eventId, event, changed)
+	context.JSON(http.StatusOK, gin.H{"message": "Event " + changed.Status, "event": changed})
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"RestAPI/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// refreshTokenRequest is the JSON body expected by refreshAccessToken and logout.
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// refreshAccessToken exchanges a refresh token for a new access token and a new refresh token.
+// The new access token carries the user's current roles, so role changes are picked up on refresh.
+// The presented refresh token is rotated and can not be used again; presenting it a second time
+// revokes the whole login session. Any failure to rotate the token results in an Unauthorized problem with the code "invalid_refresh_token",
+// so the client knows it has to log in again.
+func refreshAccessToken(context *gin.Context) {
+	var request refreshTokenRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId, newRefreshToken, err := models.RotateRefreshToken(request.RefreshToken)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeInvalidToken, "Could not refresh token."))
+		return
+	}
+
+	user, err := models.GetUserByID(userId)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeInvalidToken, "Could not refresh token."))
+		return
+	}
+
+	roles, err := models.GetUserRoles(user.ID)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not refresh token."))
+		return
+	}
+
+	token, err := utils.GenerateToken(user.Email, user.ID, roles)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not refresh token."))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Token refreshed!", "token": token, "refreshToken": newRefreshToken})
+}
+
+// logout revokes the presented refresh token together with every token rotated from the same login.
+// Access tokens that were already issued stay valid until they expire, which is at most utils.AccessTokenTTL.
+func logout(context *gin.Context) {
+	var request refreshTokenRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	err = models.RevokeRefreshToken(request.RefreshToken)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not log out."))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
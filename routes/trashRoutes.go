@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/middlewares"
+	"RestAPI/problems"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// getTrash returns the events of the authenticated user that are in the trash, most recently deleted first.
+// Each event carries the time of its deletion in DeletedAt; it is purged for good once it has been in the trash
+// for longer than the retention period (see TRASH_RETENTION).
+// It returns a 500 Internal Server Error problem if the events can not be fetched.
+func getTrash(context *gin.Context) {
+	events, err := models.GetTrash(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the trash."))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// restoreEvent takes an event out of the trash, together with the registrations it had when it was deleted.
+// It returns a 400 Bad Request problem if the ID can not be parsed and a 404 Not Found problem if no event
+// with the ID is in the trash.
+// Like deleteEvent, only the owner of the event or a user with the "events:delete:any" permission may restore it,
+// otherwise a 403 Forbidden problem is returned.
+// On success it responds with 200 OK and the restored event, as stored after the restore.
+func restoreEvent(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidRequest, "Could not parse event id."))
+		return
+	}
+
+	event, err := models.GetDeletedEventByID(eventId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch the event."))
+		return
+	}
+
+	canModifyAny, err := middlewares.HasPermission(context, "events:delete:any")
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not check permissions."))
+		return
+	}
+
+	if event.UserID != context.GetInt64("userId") && !canModifyAny {
+		problems.Respond(context, problems.New(http.StatusForbidden, problems.CodeForbidden, "Not Authorized to restore event"))
+		return
+	}
+
+	err = event.Restore()
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not restore the event."))
+		return
+	}
+
+	restored := eventAfter(eventId)
+	recordAudit(context, "event.restore", models.AuditTargetEvent, eventId, event, restored)
+	if restored == nil {
+		event.DeletedAt = nil
+		restored = event
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Event restored", "event": restored})
+}
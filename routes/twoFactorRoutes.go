@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/problems"
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"image/png"
+	"net/http"
+)
+
+// twoFactorCodeRequest is the JSON body of the endpoints that need a current two-factor code.
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// twoFactorProblem turns an error of the two-factor models into a Problem: a wrong code becomes a 400 Bad Request
+// with the code "invalid_two_factor_code", the states two-factor authentication can not be changed in become a
+// 409 Conflict, and every other error is handled by problems.FromError with the given detail.
+func twoFactorProblem(err error, detail string) *problems.Problem {
+	switch {
+	case errors.Is(err, models.ErrInvalidTwoFactorCode):
+		return problems.New(http.StatusBadRequest, problems.CodeInvalidTwoFactorCode, "The code is invalid.")
+	case errors.Is(err, models.ErrTwoFactorRequired):
+		return problems.New(http.StatusConflict, problems.CodeTwoFactorRequired, "Your role requires two-factor authentication.")
+	case errors.Is(err, models.ErrTwoFactorEnabled):
+		return problems.New(http.StatusConflict, problems.CodeConflict, "Two-factor authentication is already enabled.")
+	case errors.Is(err, models.ErrTwoFactorNotEnabled):
+		return problems.New(http.StatusConflict, problems.CodeConflict, "Two-factor authentication is not enabled.")
+	case errors.Is(err, models.ErrTwoFactorNotEnrolled):
+		return problems.New(http.StatusConflict, problems.CodeConflict, "Enroll two-factor authentication first.")
+	default:
+		return problems.FromError(err, detail)
+	}
+}
+
+// getTwoFactor returns the two-factor authentication state of the authenticated user: whether it is "enabled",
+// whether a role of the user "required" it, and the number of unused recovery codes in "recoveryCodesLeft".
+// It returns a 500 Internal Server Error problem if the state can not be fetched.
+func getTwoFactor(context *gin.Context) {
+	userId := context.GetInt64("userId")
+	enabled, err := models.IsTwoFactorEnabled(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch two-factor authentication."))
+		return
+	}
+	required, err := models.RolesRequireTwoFactor(context.GetStringSlice("roles"))
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch two-factor authentication."))
+		return
+	}
+	recoveryCodes, err := models.CountRecoveryCodes(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not fetch two-factor authentication."))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"enabled": enabled, "required": required, "recoveryCodesLeft": recoveryCodes})
+}
+
+// enrollTwoFactor starts enabling two-factor authentication for the authenticated user. It creates a new TOTP
+// secret and returns it as "secret" together with the otpauth:// "uri" for authenticator apps; the same URI is
+// available as a QR code from getTwoFactorQRCode. Two-factor authentication is only enabled once the user
+// confirmed the secret with a code, see confirmTwoFactor; enrolling again replaces an unconfirmed secret.
+// It returns a 409 Conflict problem if two-factor authentication is already enabled.
+func enrollTwoFactor(context *gin.Context) {
+	key, err := models.EnrollTwoFactor(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, twoFactorProblem(err, "Could not enroll two-factor authentication."))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"secret": key.Secret(), "uri": key.URL()})
+}
+
+// getTwoFactorQRCode renders the otpauth:// URI of the unconfirmed secret of the authenticated user as a PNG QR
+// code, so it can be scanned by an authenticator app. The secret of an enabled two-factor authentication is never
+// shown again.
+// It returns a 409 Conflict problem if the user has not enrolled or already confirmed the secret.
+func getTwoFactorQRCode(context *gin.Context) {
+	key, err := models.GetTwoFactorEnrollment(context.GetInt64("userId"))
+	if err != nil {
+		problems.Respond(context, twoFactorProblem(err, "Could not render the QR code."))
+		return
+	}
+
+	image, err := key.Image(256, 256)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not render the QR code."))
+		return
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, image)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not render the QR code."))
+		return
+	}
+
+	context.Header("Cache-Control", "no-store")
+	context.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// confirmTwoFactor enables two-factor authentication for the authenticated user with the first "code" of the
+// authenticator app. It returns the "recoveryCodes", which log in once each without the app; they are shown only
+// this once.
+// It returns a 400 Bad Request problem with the code "invalid_two_factor_code" if the code is wrong and a
+// 409 Conflict problem if the user has not enrolled or two-factor authentication is already enabled.
+func confirmTwoFactor(context *gin.Context) {
+	var request twoFactorCodeRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	codes, err := models.ConfirmTwoFactor(userId, request.Code)
+	if err != nil {
+		problems.Respond(context, twoFactorProblem(err, "Could not enable two-factor authentication."))
+		return
+	}
+
+	recordAudit(context, "user.two_factor_enable", models.AuditTargetUser, userId, nil, nil)
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled.", "recoveryCodes": codes})
+}
+
+// disableTwoFactor turns two-factor authentication off for the authenticated user, who has to give a current
+// "code" of the authenticator app or a recovery code.
+// It returns a 400 Bad Request problem with the code "invalid_two_factor_code" if the code is wrong, a 409 Conflict
+// problem with the code "two_factor_required" if a role of the user requires two-factor authentication and a
+// 409 Conflict problem if it is not enabled.
+func disableTwoFactor(context *gin.Context) {
+	var request twoFactorCodeRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	err = models.DisableTwoFactor(userId, context.GetStringSlice("roles"), request.Code)
+	if err != nil {
+		problems.Respond(context, twoFactorProblem(err, "Could not disable two-factor authentication."))
+		return
+	}
+
+	recordAudit(context, "user.two_factor_disable", models.AuditTargetUser, userId, nil, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled."})
+}
+
+// regenerateRecoveryCodes replaces the recovery codes of the authenticated user, who has to give a current
+// "code" of the authenticator app or a recovery code, and returns the new "recoveryCodes". The old codes stop
+// working.
+// It returns a 400 Bad Request problem with the code "invalid_two_factor_code" if the code is wrong and a
+// 409 Conflict problem if two-factor authentication is not enabled.
+func regenerateRecoveryCodes(context *gin.Context) {
+	var request twoFactorCodeRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	codes, err := models.RegenerateRecoveryCodes(userId, request.Code)
+	if err != nil {
+		problems.Respond(context, twoFactorProblem(err, "Could not regenerate the recovery codes."))
+		return
+	}
+
+	recordAudit(context, "user.recovery_codes_regenerate", models.AuditTargetUser, userId, nil, nil)
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
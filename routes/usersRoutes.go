@@ -2,61 +2,194 @@ package routes
 
 import (
 	"RestAPI/Models"
+	"RestAPI/problems"
 	"RestAPI/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
 // signup handles the signup functionality by parsing the request JSON body into a User struct,
 // saving the user to the database, and returning a JSON response.
-// If parsing the request data fails, a bad request response is returned with a corresponding message.
-// If saving the user to the database fails, an internal server error response is returned with a corresponding message.
-// If all operations are successful, a created response is returned with a corresponding message.
+// If parsing the request data fails or the email or password are invalid, a bad request problem listing the invalid fields is returned.
+// If the email is already registered, a conflict problem with the code "email_taken" is returned.
+// If saving the user to the database fails, an internal server error problem is returned.
+// If all operations are successful, a verification email is sent to the new user (see verifyEmail) and
+// a created response is returned with a corresponding message.
 func signup(context *gin.Context) {
 	var user models.User
 	err := context.ShouldBindJSON(&user)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		problems.Respond(context, problems.FromBindError(err))
 		return
 	}
 
 	err = user.Save()
+	if errors.Is(err, models.ErrEmailTaken) {
+		problems.Respond(context, problems.New(http.StatusConflict, problems.CodeEmailTaken, "A user with this email already exists."))
+		return
+	}
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save user."})
+		problems.Respond(context, problems.FromError(err, "Could not save user."))
 		return
 	}
+	recordAudit(context, "user.create", models.AuditTargetUser, user.ID, nil, gin.H{"Email": user.Email})
+	issueVerificationEmail(user.ID)
 	context.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
-// login handles the login functionality by parsing the JSON request body into a User struct.
+// credentials is the JSON body expected by login. Unlike models.User it does not apply the password policy,
+// so accounts created before the policy was introduced can still log in.
+type credentials struct {
+	Email    string `binding:"required"`
+	Password string `binding:"required"`
+}
+
+// login handles the login functionality by parsing the JSON request body into credentials and a User struct.
 // It then calls the ValidateCredentials method on the user to check if the credentials are valid.
-// If the credentials are valid, a token is generated using the GenerateToken function.
-// The generated token is returned in the response along with a success message.
-// If any error occurs during parsing, credential validation, or token generation, an appropriate error message is returned in the response.
+// If the user enabled two-factor authentication, the password is only the first step: instead of tokens the
+// response carries "twoFactorRequired": true and a short-lived "twoFactorToken", which loginTwoFactor exchanges
+// for the tokens together with a code.
+// Otherwise the tokens are issued right away by issueLoginTokens and returned along with a success message,
+// see completeLogin.
+// If any error occurs during parsing, credential validation, or token generation, an appropriate problem is returned in the response;
+// invalid credentials result in an Unauthorized problem with the code "invalid_credentials".
+// Failed logins are counted per account and client IP; once either is locked, the credentials are not checked and
+// a 423 Locked or 429 Too Many Requests problem is returned instead, see loginThrottled.
 func login(context *gin.Context) {
-	var user models.User
+	var request credentials
 
-	err := context.ShouldBindJSON(&user)
+	err := context.ShouldBindJSON(&request)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	if loginThrottled(context, request.Email) {
 		return
 	}
 
+	user := models.User{Email: request.Email, Password: request.Password}
+
 	err = user.ValidateCredentials()
 
 	if err != nil {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Could not authenticate user."})
+		recordLoginFailure(context, request.Email)
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeInvalidCredentials, "Could not authenticate user."))
+		return
+	}
+
+	completeLogin(context, user)
+}
+
+// completeLogin finishes the first step of a login of the user, by password or through the identity provider.
+// If the user enabled two-factor authentication, it responds with "twoFactorRequired": true and a short-lived
+// "twoFactorToken" for loginTwoFactor, otherwise with the tokens issued by issueLoginTokens.
+func completeLogin(context *gin.Context, user models.User) {
+	twoFactor, err := models.IsTwoFactorEnabled(user.ID)
+
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	if twoFactor {
+		twoFactorToken, err := utils.GenerateTwoFactorToken(user.ID, models.TwoFactorLoginTTL())
+		if err != nil {
+			problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+			return
+		}
+		context.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication required.", "twoFactorRequired": true, "twoFactorToken": twoFactorToken})
+		return
+	}
+
+	issueLoginTokens(context, user)
+}
+
+// twoFactorLoginRequest is the JSON body expected by loginTwoFactor.
+type twoFactorLoginRequest struct {
+	TwoFactorToken string `json:"twoFactorToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// loginTwoFactor completes a login of a user with two-factor authentication. It expects the "twoFactorToken"
+// returned by login and a "code", either the current code of the authenticator app or one of the recovery codes,
+// and responds with the tokens like a login without two-factor authentication.
+// It returns an Unauthorized problem if the two-factor token is invalid or expired, in which case the user has to
+// log in again, and an Unauthorized problem with the code "invalid_two_factor_code" if the code is wrong or was
+// already used. Wrong codes count as failed logins of the account, see login.
+func loginTwoFactor(context *gin.Context) {
+	var request twoFactorLoginRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId, err := utils.VerifyTwoFactorToken(request.TwoFactorToken)
+	if err != nil {
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeUnauthorized, "The login expired, please log in again."))
+		return
+	}
+
+	user, err := models.GetUserByID(userId)
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	if loginThrottled(context, user.Email) {
+		return
+	}
+
+	err = models.VerifyTwoFactor(userId, request.Code)
+	if errors.Is(err, models.ErrInvalidTwoFactorCode) || errors.Is(err, models.ErrTwoFactorNotEnabled) {
+		recordLoginFailure(context, user.Email)
+		problems.Respond(context, problems.New(http.StatusUnauthorized, problems.CodeInvalidTwoFactorCode, "Could not authenticate user."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	issueLoginTokens(context, *user)
+}
+
+// issueLoginTokens completes a login of the user: a short-lived access token carrying the user's roles is
+// generated using the GenerateToken function and a refresh token is issued through models.IssueRefreshToken.
+// Both tokens are returned in the response along with a success message, and the failed logins of the account
+// are forgotten.
+func issueLoginTokens(context *gin.Context, user models.User) {
+	err := models.RecordLoginSuccess(user.Email)
+
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	roles, err := models.GetUserRoles(user.ID)
+
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
+		return
+	}
+
+	token, err := utils.GenerateToken(user.Email, user.ID, roles)
+
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Email, user.ID)
+	refreshToken, err := models.IssueRefreshToken(user.ID)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user."})
+		problems.Respond(context, problems.FromError(err, "Could not authenticate user."))
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "token": token})
+	context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "token": token, "refreshToken": refreshToken})
 }
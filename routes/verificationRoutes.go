@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"RestAPI/Models"
+	"RestAPI/config"
+	"RestAPI/mail"
+	"RestAPI/problems"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+type verifyEmailRequest struct {
+	Token string `form:"token" binding:"required"`
+}
+
+// sendVerificationEmail mails the verification link with the token to the email in the background.
+// The link points to EMAIL_VERIFICATION_URL (PUBLIC_URL + "/verify-email" by default) with the token in the
+// "token" query parameter, so by default following it verifies the email through verifyEmail.
+func sendVerificationEmail(email, token string) {
+	link := config.String("EMAIL_VERIFICATION_URL", publicURL()+"/verify-email") + "?token=" + url.QueryEscape(token)
+	mail.SendAsync(mail.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body: "Welcome! Please confirm that this is your email by following this link within " +
+			formatTTL(models.EmailVerificationTTL()) + ":\n\n" + link + "\n\n" +
+			"If you did not sign up, you can ignore this email.\n",
+	})
+}
+
+// issueVerificationEmail sends the verification email to a user who just signed up. Signing up does not fail if
+// the email can not be sent; the user can ask for it again with resendVerificationEmail.
+func issueVerificationEmail(userId int64) {
+	email, token, err := models.IssueEmailVerificationToken(userId)
+	if err != nil {
+		log.Printf("Could not send the verification email to user %d: %v", userId, err)
+		return
+	}
+	sendVerificationEmail(email, token)
+}
+
+// verifyEmail marks the email of a user as verified with the token of a verification email. The token is taken
+// from the "token" query parameter of a GET request, which is what the link in the email leads to, or from the
+// JSON body {"Token": ...} of a POST request. Following the link again once the email is verified succeeds as well.
+// It returns a 400 Bad Request problem if the token is missing, a 400 Bad Request problem with the code
+// invalid_verification_token if it is invalid, expired or was issued for an email the user no longer has, and a
+// 500 Internal Server Error problem if the email can not be marked as verified.
+func verifyEmail(context *gin.Context) {
+	var request verifyEmailRequest
+	var err error
+	if context.Request.Method == http.MethodGet {
+		err = context.ShouldBindQuery(&request)
+	} else {
+		err = context.ShouldBindJSON(&request)
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromBindError(err))
+		return
+	}
+
+	userId, err := models.VerifyEmail(request.Token)
+	if errors.Is(err, models.ErrInvalidVerificationToken) {
+		problems.Respond(context, problems.New(http.StatusBadRequest, problems.CodeInvalidVerificationToken, err.Error()))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not verify the email."))
+		return
+	}
+
+	recordAudit(context, "user.email_verify", models.AuditTargetUser, userId, nil, nil)
+	context.JSON(http.StatusOK, gin.H{"message": "Email verified."})
+}
+
+// resendVerificationEmail sends another verification email to the authenticated user, e.g. because the first one
+// expired or got lost. Only one email is sent per EMAIL_VERIFICATION_RESEND_INTERVAL.
+// It returns 202 Accepted as the email is sent in the background, a 409 Conflict problem if the email is already
+// verified, a 429 Too Many Requests problem with the code too_many_requests and a Retry-After header if the last
+// email was sent too recently, and a 500 Internal Server Error problem if the email can not be sent.
+func resendVerificationEmail(context *gin.Context) {
+	email, token, err := models.IssueEmailVerificationToken(context.GetInt64("userId"))
+	if errors.Is(err, models.ErrEmailAlreadyVerified) {
+		problems.Respond(context, problems.New(http.StatusConflict, problems.CodeConflict, "The email is already verified."))
+		return
+	}
+	if errors.Is(err, models.ErrVerificationThrottled) {
+		setRetryAfter(context, models.EmailVerificationResendInterval())
+		problems.Respond(context, problems.New(http.StatusTooManyRequests, problems.CodeTooManyRequests, "A verification email was sent recently, please wait before asking for another one."))
+		return
+	}
+	if err != nil {
+		problems.Respond(context, problems.FromError(err, "Could not send the verification email."))
+		return
+	}
+
+	sendVerificationEmail(email, token)
+	context.JSON(http.StatusAccepted, gin.H{"message": "A verification email has been sent."})
+}
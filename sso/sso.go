@@ -0,0 +1,173 @@
+// Package sso signs users in with an OpenID Connect identity provider, like the company single sign-on. It runs
+// the authorization code flow with PKCE against the provider configured by OIDC_ISSUER and verifies the ID token
+// it returns. The provider is discovered on first use, so the API starts even while the provider is unreachable.
+package sso
+
+import (
+	"RestAPI/config"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTimeout limits every request to the identity provider, so a hanging provider does not hang logins.
+const httpTimeout = 10 * time.Second
+
+// ErrUnavailable is returned if the identity provider can not be discovered, e.g. because it is unreachable.
+var ErrUnavailable = errors.New("the identity provider is unavailable")
+
+// Identity is the user the identity provider signed in. Issuer and Subject identify the user at the provider
+// and never change; the Email may, and EmailVerified tells whether the provider verified it.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Enabled reports whether an identity provider is configured by OIDC_ISSUER.
+func Enabled() bool {
+	return Issuer() != ""
+}
+
+// Issuer returns the issuer URL of the identity provider, configured by OIDC_ISSUER. Its discovery document is
+// expected at Issuer + "/.well-known/openid-configuration".
+func Issuer() string {
+	return config.String("OIDC_ISSUER", "")
+}
+
+var (
+	providerMu sync.Mutex
+	provider   *oidc.Provider
+)
+
+// httpContext returns a context whose requests to the identity provider time out after httpTimeout.
+func httpContext(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, &http.Client{Timeout: httpTimeout})
+}
+
+// discover returns the provider, fetching its discovery document on first use. A failed discovery is not
+// remembered, so the next login tries again.
+func discover() (*oidc.Provider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+
+	if provider != nil {
+		return provider, nil
+	}
+	// The provider keeps the context to fetch its signing keys later on, so it must not be a request context.
+	discovered, err := oidc.NewProvider(httpContext(context.Background()), Issuer())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	provider = discovered
+	return provider, nil
+}
+
+// oauthConfig returns the client configuration for the provider, with the client registered at the provider by
+// OIDC_CLIENT_ID and OIDC_CLIENT_SECRET, which may be empty for a public client, and the scopes configured by
+// OIDC_SCOPES, separated by spaces ("openid email profile" by default).
+func oauthConfig(provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	scopes := strings.Fields(config.String("OIDC_SCOPES", "openid email profile"))
+	if !slices.Contains(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+	return &oauth2.Config{
+		ClientID:     config.String("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.String("OIDC_CLIENT_SECRET", ""),
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// NewVerifier returns a new random PKCE code verifier for a login, see AuthCodeURL.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the URL of the identity provider to send the browser to for a login. The provider redirects
+// back to redirectURL with the state and a code, which Exchange turns into the Identity; the nonce is checked
+// against the ID token and the code verifier proves the code is redeemed by whoever started the login. loginHint
+// is passed on to the provider to preselect an account, if not empty.
+// It returns an error wrapping ErrUnavailable if the provider can not be discovered.
+func AuthCodeURL(redirectURL, state, nonce, verifier, loginHint string) (string, error) {
+	provider, err := discover()
+	if err != nil {
+		return "", err
+	}
+
+	options := []oauth2.AuthCodeOption{oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)}
+	if loginHint != "" {
+		options = append(options, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	return oauthConfig(provider, redirectURL).AuthCodeURL(state, options...), nil
+}
+
+// Exchange redeems the code the identity provider redirected back with for its tokens, verifies the ID token,
+// its signature, issuer, audience and expiry, and that it carries the nonce of the login, and returns the
+// Identity it names. redirectURL, nonce and verifier must be the ones the login was started with by AuthCodeURL.
+// It returns an error wrapping ErrUnavailable if the provider can not be discovered, and another error if the
+// code is rejected or the ID token is invalid.
+func Exchange(ctx context.Context, redirectURL, code, nonce, verifier string) (*Identity, error) {
+	provider, err := discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = httpContext(ctx)
+	token, err := oauthConfig(provider, redirectURL).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("could not redeem the code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("the token response contains no ID token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.String("OIDC_CLIENT_ID", "")}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("invalid ID token: the nonce does not match the login")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Email == "" {
+		return nil, errors.New("the ID token contains no email, is the email scope granted?")
+	}
+
+	return &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+	}, nil
+}
+
+// isTrue reports whether a boolean claim is true. Some providers send booleans as the strings "true" and "false".
+func isTrue(claim any) bool {
+	switch value := claim.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,161 @@
+package sso
+
+import (
+	"RestAPI/sso/ssotest"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const redirectURL = "http://api.example.com/auth/oidc/callback"
+
+// startProvider serves a mock identity provider for the test and configures the package to use it.
+func startProvider(t *testing.T) *ssotest.Provider {
+	t.Helper()
+	p, err := ssotest.NewProvider("", "restapi", "secret")
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	p.Issuer = server.URL
+
+	t.Setenv("OIDC_ISSUER", server.URL)
+	t.Setenv("OIDC_CLIENT_ID", "restapi")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	resetProvider(t)
+	return p
+}
+
+// resetProvider forgets the discovered provider before and after the test.
+func resetProvider(t *testing.T) {
+	providerMu.Lock()
+	provider = nil
+	providerMu.Unlock()
+	t.Cleanup(func() {
+		providerMu.Lock()
+		provider = nil
+		providerMu.Unlock()
+	})
+}
+
+// authorize starts a login at the provider and returns the code it redirects back with.
+func authorize(t *testing.T, nonce, verifier, loginHint string) string {
+	t.Helper()
+	authURL, err := AuthCodeURL(redirectURL, "state", nonce, verifier, loginHint)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Could not start the login: %v", err)
+	}
+	defer response.Body.Close()
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	if response.StatusCode != http.StatusFound || err != nil {
+		t.Fatalf("The provider responded with %d to %s, want a redirect", response.StatusCode, authURL)
+	}
+	query := location.Query()
+	if query.Get("state") != "state" || query.Get("code") == "" {
+		t.Fatalf("The provider redirected to %s, want the state and a code", location)
+	}
+	return query.Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	p := startProvider(t)
+	verifier := NewVerifier()
+	code := authorize(t, "nonce", verifier, "ada@example.com")
+
+	identity, err := Exchange(context.Background(), redirectURL, code, "nonce", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Issuer: p.Issuer, Subject: ssotest.Subject("ada@example.com"), Email: "ada@example.com", EmailVerified: true}
+	if *identity != want {
+		t.Errorf("Exchange = %+v, want %+v", *identity, want)
+	}
+
+	_, err = Exchange(context.Background(), redirectURL, code, "nonce", verifier)
+	if err == nil {
+		t.Error("Exchange of a redeemed code succeeded, want an error")
+	}
+}
+
+func TestExchangeUnverifiedEmail(t *testing.T) {
+	p := startProvider(t)
+	p.EmailVerified = false
+	verifier := NewVerifier()
+	code := authorize(t, "nonce", verifier, "")
+
+	identity, err := Exchange(context.Background(), redirectURL, code, "nonce", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Email != p.Email || identity.EmailVerified {
+		t.Errorf("Exchange = %+v, want the unverified email %s", *identity, p.Email)
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	startProvider(t)
+	verifier := NewVerifier()
+	code := authorize(t, "nonce", verifier, "ada@example.com")
+
+	identity, err := Exchange(context.Background(), redirectURL, code, "another nonce", verifier)
+	if err == nil {
+		t.Errorf("Exchange with another nonce = %+v, want an error", *identity)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	startProvider(t)
+	code := authorize(t, "nonce", NewVerifier(), "ada@example.com")
+
+	identity, err := Exchange(context.Background(), redirectURL, code, "nonce", NewVerifier())
+	if err == nil {
+		t.Errorf("Exchange with another code verifier = %+v, want an error", *identity)
+	}
+}
+
+func TestUnavailableProvider(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("OIDC_ISSUER", server.URL)
+	resetProvider(t)
+
+	_, err := AuthCodeURL(redirectURL, "state", "nonce", NewVerifier(), "")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("AuthCodeURL: got %v, want ErrUnavailable", err)
+	}
+	_, err = Exchange(context.Background(), redirectURL, "code", "nonce", NewVerifier())
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Exchange: got %v, want ErrUnavailable", err)
+	}
+}
+
+func TestIsTrue(t *testing.T) {
+	tests := []struct {
+		claim any
+		want  bool
+	}{
+		{true, true},
+		{false, false},
+		{"true", true},
+		{"false", false},
+		{nil, false},
+		{1.0, false},
+	}
+	for _, test := range tests {
+		if got := isTrue(test.claim); got != test.want {
+			t.Errorf("isTrue(%#v) = %v, want %v", test.claim, got, test.want)
+		}
+	}
+}
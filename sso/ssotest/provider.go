@@ -0,0 +1,267 @@
+// Package ssotest provides a minimal OpenID Connect identity provider for tests and for trying out the login with
+// an identity provider locally, see tools/mockoidc. It signs in every login right away, without asking for a
+// password, as the user named by the login_hint of the login or else by Provider.Email, and supports just what
+// the API uses: discovery, the authorization code flow with PKCE (S256) and RS256 signed ID tokens.
+// It must never be used in production.
+package ssotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyID is the kid of the signing key, which is created anew for every Provider.
+const keyID = "ssotest"
+
+// authorization is a code handed out by the authorize endpoint that was not redeemed yet.
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	expiresAt     time.Time
+}
+
+// Provider is a mock identity provider. It serves its endpoints under Issuer, which must be set to the URL the
+// provider is reachable at before the first request, e.g. the URL of an httptest.Server. The fields must not be
+// changed while requests are served.
+//   - ClientID and ClientSecret are the client the API is registered with; an empty secret makes it a public client.
+//   - Email is the user signed in by logins without a login_hint.
+//   - EmailVerified is the email_verified claim of the ID tokens.
+type Provider struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	Email         string
+	EmailVerified bool
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]authorization
+	mux   *http.ServeMux
+}
+
+// NewProvider returns a Provider for the client with a new signing key, which signs in user@example.com with a
+// verified email unless changed.
+func NewProvider(issuer, clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		Issuer:        strings.TrimSuffix(issuer, "/"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Email:         "user@example.com",
+		EmailVerified: true,
+		key:           key,
+		codes:         map[string]authorization{},
+		mux:           http.NewServeMux(),
+	}
+	p.mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("GET /jwks", p.jwks)
+	p.mux.HandleFunc("GET /authorize", p.authorize)
+	p.mux.HandleFunc("POST /token", p.token)
+	return p, nil
+}
+
+// ServeHTTP serves the endpoints of the provider.
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// Subject returns the subject the provider signs in the user with the email as. It stays the same for an email
+// in any case, so a user keeps the identity across logins.
+func Subject(email string) string {
+	subject := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(subject[:16])
+}
+
+// discovery serves the discovery document.
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// jwks serves the public signing key.
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": keyID,
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// authorize signs the user in right away and redirects back to the client with a code and the state.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case query.Get("client_id") != p.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case query.Get("response_type") != "code":
+		redirectError(w, r, redirectURI, query.Get("state"), "unsupported_response_type")
+		return
+	case !strings.Contains(" "+query.Get("scope")+" ", " openid "):
+		redirectError(w, r, redirectURI, query.Get("state"), "invalid_scope")
+		return
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		redirectError(w, r, redirectURI, query.Get("state"), "invalid_request")
+		return
+	}
+
+	email := query.Get("login_hint")
+	if email == "" {
+		email = p.Email
+	}
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		clientID:      p.ClientID,
+		redirectURI:   redirectURI.String(),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		email:         email,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirectURI.RawQuery = values.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token redeems a code for an ID token, checking the client, the redirect URI and the PKCE code verifier.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok || time.Now().After(auth.expiresAt) || auth.clientID != clientID:
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	case auth.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	case base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge:
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.Issuer,
+		"sub":            Subject(auth.email),
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          auth.email,
+		"email_verified": p.EmailVerified,
+	}
+	if auth.nonce != "" {
+		claims["nonce"] = auth.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+// redirectError redirects back to the client with an OAuth error code.
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI *url.URL, state, code string) {
+	values := redirectURI.Query()
+	values.Set("error", code)
+	values.Set("state", state)
+	redirectURI.RawQuery = values.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// tokenError responds to a token request with an OAuth error code.
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+// writeJSON responds with the value encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Printf("Could not write the response: %v", err)
+	}
+}
+
+// randomString returns a random, URL-safe string for codes and access tokens.
+func randomString() string {
+	buf := make([]byte, 24)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic("Could not read random bytes.")
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
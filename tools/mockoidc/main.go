@@ -0,0 +1,50 @@
+// Command mockoidc is a minimal OpenID Connect identity provider for trying out and testing the login with an
+// identity provider locally, without a real one. It serves the mock provider of package ssotest, which signs in
+// every login right away, without asking for a password, as the user named by the login_hint of the login or
+// else by -email.
+//
+// Run it next to the API with
+//
+//	go run ./tools/mockoidc -addr :9000
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=restapi OIDC_CLIENT_SECRET=secret go run .
+//
+// and open http://localhost:8080/auth/oidc/login in a browser. It must never be used in production.
+package main
+
+import (
+	"RestAPI/sso/ssotest"
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, the URL the provider is reachable at")
+	clientID := flag.String("client-id", "restapi", "client ID the API is registered with")
+	clientSecret := flag.String("client-secret", "secret", "client secret of the API, empty for a public client")
+	email := flag.String("email", "user@example.com", "email of the user signed in if a login has no login_hint")
+	emailVerified := flag.Bool("email-verified", true, "whether the email counts as verified by the provider")
+	flag.Parse()
+
+	provider, err := ssotest.NewProvider(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Could not create the provider: %v", err)
+	}
+	provider.Email = *email
+	provider.EmailVerified = *emailVerified
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/authorize" {
+			email := r.URL.Query().Get("login_hint")
+			if email == "" {
+				email = provider.Email
+			}
+			log.Printf("Signing in %s", email)
+		}
+		provider.ServeHTTP(w, r)
+	})
+
+	log.Printf("Mock identity provider %s listening on %s", provider.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
@@ -3,54 +3,138 @@ package utils
 import (
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"strconv"
 	"time"
 )
 
-const secretKey = "supersecret"
+// AccessTokenTTL is the lifetime of the JWT access tokens issued by GenerateToken.
+// Access tokens are kept short-lived; clients renew them with a refresh token.
+const AccessTokenTTL = time.Minute * 15
 
-// GenerateToken generates a JWT token for a given email and userId.
-// It uses jwt.NewWithClaims to create a new token with the HMAC SHA256 signing method.
-// The token contains the email, userId, and expiration time, which is set to 2 hours from the current time.
-// The token is then signed with the secretKey and returned as a string.
+// GenerateToken generates a JWT token for a given email, userId and the roles the user holds.
+// The token contains the email, userId, roles, and expiration time, which is set to AccessTokenTTL from the current time.
+// Since the roles are embedded, role changes take effect when the token is next refreshed.
+// The token is signed with the configured signing key (see InitKeys), whose kid is set in the token header,
+// and returned as a string.
 // If any error occurs during token generation, an error is returned.
-func GenerateToken(email string, userId int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+func GenerateToken(email string, userId int64, roles []string) (string, error) {
+	return signClaims(jwt.MapClaims{
 		"email":  email,
 		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
+		"roles":  roles,
+		"exp":    time.Now().Add(AccessTokenTTL).Unix(),
 	})
-
-	return token.SignedString([]byte(secretKey))
 }
 
-// VerifyToken verifies the validity of the given token and extracts the userId.
+// VerifyToken verifies the validity of the given token and extracts the userId and roles.
+// The token may be signed by any of the configured keys, which allows rotating the signing key
+// without invalidating tokens that were issued with the previous one.
 // If the token is not parsed successfully, it returns an error with the message "could not parse token".
 // If the token is invalid, it returns an error with the message "invalid Token".
 // If the token claims are not valid, it returns an error with the message "invalid Token".
-// Otherwise, it returns the extracted userId and nil error.
-func VerifyToken(token string) (int64, error) {
-	parsedToken, err := jwt.Parse(token, func(Token *jwt.Token) (any, error) {
-		_, ok := Token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secretKey), nil
-	})
+// Tokens issued before roles were added to the claims yield no roles.
+// Otherwise, it returns the extracted userId, roles and nil error.
+func VerifyToken(token string) (int64, []string, error) {
+	parsedToken, err := parseSignedToken(token)
 
 	if err != nil {
-		return 0, errors.New("could not parse token")
+		return 0, nil, errors.New("could not parse token")
 	}
 
 	TokenIsvalid := parsedToken.Valid
 	if !TokenIsvalid {
-		return 0, errors.New("invalid Token")
+		return 0, nil, errors.New("invalid Token")
 	}
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 
 	if !ok {
-		return 0, errors.New("invalid Token")
+		return 0, nil, errors.New("invalid Token")
 	}
 	//email := claims["email"].(string)
-	userId := int64(claims["userId"].(float64))
-	return userId, nil
+	userIdClaim, ok := claims["userId"].(float64)
+	if !ok {
+		return 0, nil, errors.New("invalid Token")
+	}
+	userId := int64(userIdClaim)
+
+	roles := []string{}
+	roleClaims, _ := claims["roles"].([]any)
+	for _, role := range roleClaims {
+		if name, ok := role.(string); ok {
+			roles = append(roles, name)
+		}
+	}
+	return userId, roles, nil
+}
+
+// emailVerificationPurpose is the "purpose" claim of email verification tokens. Access tokens have no purpose
+// and tokens with a purpose no "userId" claim, so neither can be used as the other.
+const emailVerificationPurpose = "verify_email"
+
+// GenerateEmailVerificationToken generates a signed token that proves the owner of the email received it,
+// for the link of a verification email. It names the user and the email, so it stops working if the email of
+// the user changes, and expires after the given ttl. It is signed like access tokens, see GenerateToken.
+func GenerateEmailVerificationToken(userId int64, email string, ttl time.Duration) (string, error) {
+	return signClaims(jwt.MapClaims{
+		"purpose": emailVerificationPurpose,
+		"sub":     strconv.FormatInt(userId, 10),
+		"email":   email,
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+}
+
+// VerifyEmailVerificationToken verifies a token generated by GenerateEmailVerificationToken and returns the ID
+// of the user and the email it was issued for. It returns an error if the token is invalid, expired or was not
+// issued for email verification.
+func VerifyEmailVerificationToken(token string) (int64, string, error) {
+	userId, claims, err := verifyPurposeToken(token, emailVerificationPurpose)
+	if err != nil {
+		return 0, "", err
+	}
+	email, ok := claims["email"].(string)
+	if !ok {
+		return 0, "", errors.New("invalid Token")
+	}
+	return userId, email, nil
+}
+
+// twoFactorPurpose is the "purpose" claim of the tokens handed out by a login that still needs the second factor.
+const twoFactorPurpose = "two_factor"
+
+// GenerateTwoFactorToken generates a signed token that proves the user with the given ID passed the first step
+// of a login, their password, and may complete it with a two-factor code until the token expires after ttl.
+// It is not an access token and is rejected by VerifyToken.
+func GenerateTwoFactorToken(userId int64, ttl time.Duration) (string, error) {
+	return signClaims(jwt.MapClaims{
+		"purpose": twoFactorPurpose,
+		"sub":     strconv.FormatInt(userId, 10),
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+}
+
+// VerifyTwoFactorToken verifies a token generated by GenerateTwoFactorToken and returns the ID of the user.
+// It returns an error if the token is invalid, expired or was not issued for two-factor authentication.
+func VerifyTwoFactorToken(token string) (int64, error) {
+	userId, _, err := verifyPurposeToken(token, twoFactorPurpose)
+	return userId, err
+}
+
+// verifyPurposeToken verifies a token that was issued for the given purpose and returns the ID of the user in its
+// "sub" claim together with all of its claims.
+func verifyPurposeToken(token, purpose string) (int64, jwt.MapClaims, error) {
+	parsedToken, err := parseSignedToken(token)
+	if err != nil || !parsedToken.Valid {
+		return 0, nil, errors.New("invalid Token")
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return 0, nil, errors.New("invalid Token")
+	}
+	subject, _ := claims["sub"].(string)
+	userId, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return 0, nil, errors.New("invalid Token")
+	}
+	return userId, claims, nil
 }
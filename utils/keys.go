@@ -0,0 +1,334 @@
+package utils
+
+import (
+	"RestAPI/config"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+)
+
+// keyConfig describes a single key of the key configuration.
+// Symmetric (HS*) keys use Secret. Asymmetric keys are given as PEM, either inline or as a file path.
+// A key with a private key can sign and verify tokens; a key with only a public key can only verify them,
+// which is how retired keys are kept around until the tokens they signed have expired.
+type keyConfig struct {
+	Kid            string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKey     string `json:"privateKey"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKey      string `json:"publicKey"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+}
+
+// keysConfig is the key configuration read from JWT_KEYS_FILE or JWT_KEYS.
+// SigningKey is the kid of the key used to sign new tokens; every key listed in Keys is accepted for verification.
+//
+// Example:
+//
+//	{
+//	  "signingKey": "2024-06",
+//	  "keys": [
+//	    {"kid": "2024-06", "alg": "EdDSA", "privateKeyFile": "keys/2024-06.pem"},
+//	    {"kid": "2024-01", "alg": "RS256", "publicKeyFile": "keys/2024-01.pub.pem"}
+//	  ]
+//	}
+type keysConfig struct {
+	SigningKey string      `json:"signingKey"`
+	Keys       []keyConfig `json:"keys"`
+}
+
+// jwtKey is a loaded key. signKey is nil for keys that may only be used for verification.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// JSONWebKey is the public part of a signing key in the JSON Web Key format (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// verificationKeys holds every configured key by kid, signingKey is the key new tokens are signed with.
+var (
+	verificationKeys = map[string]*jwtKey{}
+	signingKey       *jwtKey
+)
+
+// InitKeys loads the JWT keys from the configuration and must be called before tokens are generated or verified.
+// The configuration is read, in order of precedence, from:
+//   - JWT_KEYS_FILE: path to a JSON file in the keysConfig format,
+//   - JWT_KEYS: the same JSON document inline,
+//   - JWT_SECRET: a single HS256 secret with the kid "default".
+//
+// If none of them is set, an ephemeral EdDSA key is generated so the server still starts during development,
+// but tokens issued by it are no longer accepted after a restart.
+// It panics if the configuration can not be loaded, like db.InitDB does for the database.
+func InitKeys() {
+	cfg, err := loadKeysConfig()
+	if err != nil {
+		panic("Could not load JWT keys: " + err.Error())
+	}
+
+	if cfg == nil {
+		log.Println("No JWT keys configured, using an ephemeral key. Tokens will not survive a restart.")
+		cfg, err = ephemeralKeysConfig()
+		if err != nil {
+			panic("Could not generate JWT key: " + err.Error())
+		}
+	}
+
+	err = setKeys(cfg)
+	if err != nil {
+		panic("Could not load JWT keys: " + err.Error())
+	}
+}
+
+// loadKeysConfig reads the key configuration from the environment as described by InitKeys.
+// It returns a nil configuration if no keys are configured at all.
+func loadKeysConfig() (*keysConfig, error) {
+	var data []byte
+	if path := config.String("JWT_KEYS_FILE", ""); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data = content
+	} else if inline := config.String("JWT_KEYS", ""); inline != "" {
+		data = []byte(inline)
+	}
+
+	if data != nil {
+		var cfg keysConfig
+		err := json.Unmarshal(data, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &cfg, nil
+	}
+
+	if secret := config.String("JWT_SECRET", ""); secret != "" {
+		return &keysConfig{
+			SigningKey: "default",
+			Keys:       []keyConfig{{Kid: "default", Alg: "HS256", Secret: secret}},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// ephemeralKeysConfig generates a new Ed25519 key pair and returns a configuration that signs with it.
+func ephemeralKeysConfig() (*keysConfig, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	return &keysConfig{
+		SigningKey: "ephemeral",
+		Keys:       []keyConfig{{Kid: "ephemeral", Alg: "EdDSA", PrivateKey: string(privatePEM)}},
+	}, nil
+}
+
+// setKeys parses every key of the configuration and replaces the currently loaded keys.
+func setKeys(cfg *keysConfig) error {
+	loaded := map[string]*jwtKey{}
+	for _, keyCfg := range cfg.Keys {
+		if keyCfg.Kid == "" {
+			return errors.New("every key needs a kid")
+		}
+		if _, exists := loaded[keyCfg.Kid]; exists {
+			return fmt.Errorf("duplicate kid %q", keyCfg.Kid)
+		}
+		key, err := parseKey(keyCfg)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", keyCfg.Kid, err)
+		}
+		loaded[key.id] = key
+	}
+
+	active, ok := loaded[cfg.SigningKey]
+	if !ok {
+		return fmt.Errorf("signing key %q is not configured", cfg.SigningKey)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("signing key %q has no private key", cfg.SigningKey)
+	}
+
+	verificationKeys = loaded
+	signingKey = active
+	return nil
+}
+
+// parseKey turns a keyConfig into a jwtKey, parsing the PEM encoded keys according to the algorithm.
+func parseKey(cfg keyConfig) (*jwtKey, error) {
+	method := jwt.GetSigningMethod(cfg.Alg)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Alg)
+	}
+	key := &jwtKey{id: cfg.Kid, method: method}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if cfg.Secret == "" {
+			return nil, errors.New("missing secret")
+		}
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+		return key, nil
+	}
+
+	privatePEM, err := readPEM(cfg.PrivateKey, cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM, err := readPEM(cfg.PublicKey, cfg.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if privatePEM == nil && publicPEM == nil {
+		return nil, errors.New("missing private or public key")
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if privatePEM != nil {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		} else {
+			key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+		}
+	case *jwt.SigningMethodECDSA:
+		if privatePEM != nil {
+			private, err := jwt.ParseECPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		} else {
+			key.verifyKey, err = jwt.ParseECPublicKeyFromPEM(publicPEM)
+		}
+	case *jwt.SigningMethodEd25519:
+		if privatePEM != nil {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := private.(crypto.Signer)
+			if !ok {
+				return nil, errors.New("invalid Ed25519 private key")
+			}
+			key.signKey, key.verifyKey = signer, signer.Public()
+		} else {
+			key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(publicPEM)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Alg)
+	}
+
+	return key, err
+}
+
+// readPEM returns the inline PEM if it is set, otherwise the content of the file. Both empty yields nil.
+func readPEM(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+// signClaims signs the given claims with the current signing key and sets its kid in the token header.
+func signClaims(claims jwt.Claims) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("no signing key configured")
+	}
+	token := jwt.NewWithClaims(signingKey.method, claims)
+	token.Header["kid"] = signingKey.id
+
+	return token.SignedString(signingKey.signKey)
+}
+
+// parseSignedToken parses a token signed by one of the configured keys.
+// The key is selected by the kid header and the token's algorithm must match the algorithm of that key,
+// so a token can not switch e.g. from RS256 to HS256 to be verified with the public key as HMAC secret.
+// Tokens without a kid, issued before keys had IDs, are verified with the current signing key.
+func parseSignedToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(Token *jwt.Token) (any, error) {
+		key := signingKey
+		if kid, ok := Token.Header["kid"].(string); ok {
+			key = verificationKeys[kid]
+		}
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+		if Token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	})
+}
+
+// PublicJWKS returns the public keys of all configured asymmetric keys as JSON Web Keys, sorted by kid.
+// Symmetric HS* secrets are never published; services that must verify tokens without sharing
+// a secret need the API to sign with an asymmetric key.
+func PublicJWKS() []JSONWebKey {
+	jwks := []JSONWebKey{}
+	for _, key := range verificationKeys {
+		jwk := JSONWebKey{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
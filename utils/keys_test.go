@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKeyPEM returns the private key and its public key PEM encoded.
+func testKeyPEM(t *testing.T, private any, public any) (string, string) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+// setKeysEnv sets the key configuration variables read by InitKeys, empty ones count as unset, and restores the
+// keys loaded before the test when it ends.
+func setKeysEnv(t *testing.T, keysFile, keys, secret string) {
+	t.Setenv("JWT_KEYS_FILE", keysFile)
+	t.Setenv("JWT_KEYS", keys)
+	t.Setenv("JWT_SECRET", secret)
+	previousKeys, previousSigningKey := verificationKeys, signingKey
+	t.Cleanup(func() { verificationKeys, signingKey = previousKeys, previousSigningKey })
+}
+
+// keysJSON encodes the key configuration.
+func keysJSON(t *testing.T, cfg keysConfig) string {
+	t.Helper()
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Could not encode the key configuration: %v", err)
+	}
+	return string(data)
+}
+
+// issueToken generates an access token for user 7 and fails the test if that is not possible.
+func issueToken(t *testing.T) string {
+	t.Helper()
+	token, err := GenerateToken("ada@example.com", 7, []string{"organizer"})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestInitKeysSources(t *testing.T) {
+	fileKeys := keysConfig{SigningKey: "file", Keys: []keyConfig{{Kid: "file", Alg: "HS256", Secret: "file secret"}}}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	err := os.WriteFile(path, []byte(keysJSON(t, fileKeys)), 0o600)
+	if err != nil {
+		t.Fatalf("Could not write the key file: %v", err)
+	}
+	inlineKeys := keysJSON(t, keysConfig{SigningKey: "inline", Keys: []keyConfig{{Kid: "inline", Alg: "HS512", Secret: "inline secret"}}})
+
+	tests := []struct {
+		name     string
+		keysFile string
+		keys     string
+		secret   string
+		wantKid  string
+		wantAlg  string
+	}{
+		{"JWT_KEYS_FILE first", path, inlineKeys, "secret", "file", "HS256"},
+		{"JWT_KEYS before JWT_SECRET", "", inlineKeys, "secret", "inline", "HS512"},
+		{"JWT_SECRET", "", "", "secret", "default", "HS256"},
+		{"ephemeral key", "", "", "", "ephemeral", "EdDSA"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setKeysEnv(t, test.keysFile, test.keys, test.secret)
+			InitKeys()
+			if signingKey.id != test.wantKid || signingKey.method.Alg() != test.wantAlg {
+				t.Errorf("Signing with %q (%s), want %q (%s)", signingKey.id, signingKey.method.Alg(), test.wantKid, test.wantAlg)
+			}
+
+			userId, roles, err := VerifyToken(issueToken(t))
+			if err != nil || userId != 7 || len(roles) != 1 || roles[0] != "organizer" {
+				t.Errorf("VerifyToken = %d, %v, %v, want user 7 with the organizer role", userId, roles, err)
+			}
+		})
+	}
+}
+
+func TestInitKeysRejectsInvalidConfiguration(t *testing.T) {
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, ecPublicPEM := testKeyPEM(t, ecPrivate, &ecPrivate.PublicKey)
+
+	tests := []struct {
+		name string
+		cfg  keysConfig
+	}{
+		{"unknown signing key", keysConfig{SigningKey: "b", Keys: []keyConfig{{Kid: "a", Alg: "HS256", Secret: "s"}}}},
+		{"signing key without private key", keysConfig{SigningKey: "a", Keys: []keyConfig{{Kid: "a", Alg: "ES256", PublicKey: ecPublicPEM}}}},
+		{"duplicate kid", keysConfig{SigningKey: "a", Keys: []keyConfig{{Kid: "a", Alg: "HS256", Secret: "s"}, {Kid: "a", Alg: "HS256", Secret: "t"}}}},
+		{"missing kid", keysConfig{Keys: []keyConfig{{Alg: "HS256", Secret: "s"}}}},
+		{"none algorithm", keysConfig{SigningKey: "a", Keys: []keyConfig{{Kid: "a", Alg: "none"}}}},
+		{"missing secret", keysConfig{SigningKey: "a", Keys: []keyConfig{{Kid: "a", Alg: "HS256"}}}},
+		{"key of another algorithm", keysConfig{SigningKey: "a", Keys: []keyConfig{{Kid: "a", Alg: "RS256", PublicKey: ecPublicPEM}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setKeysEnv(t, "", keysJSON(t, test.cfg), "")
+			defer func() {
+				if recover() == nil {
+					t.Error("InitKeys did not panic")
+				}
+			}()
+			InitKeys()
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaPrivatePEM, rsaPublicPEM := testKeyPEM(t, rsaPrivate, &rsaPrivate.PublicKey)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	edPrivatePEM, _ := testKeyPEM(t, edPrivate, edPublic)
+
+	setKeysEnv(t, "", keysJSON(t, keysConfig{SigningKey: "old", Keys: []keyConfig{{Kid: "old", Alg: "RS256", PrivateKey: rsaPrivatePEM}}}), "")
+	InitKeys()
+	oldToken := issueToken(t)
+
+	// The new key signs, the old one is kept with just its public key until its tokens expired.
+	t.Setenv("JWT_KEYS", keysJSON(t, keysConfig{SigningKey: "new", Keys: []keyConfig{
+		{Kid: "new", Alg: "EdDSA", PrivateKey: edPrivatePEM},
+		{Kid: "old", Alg: "RS256", PublicKey: rsaPublicPEM},
+	}}))
+	InitKeys()
+	_, _, err = VerifyToken(oldToken)
+	if err != nil {
+		t.Errorf("VerifyToken of a token signed by the retired key: %v", err)
+	}
+	newToken := issueToken(t)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != "new" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("GenerateToken signed with the header %v, %v, want the kid new and EdDSA", parsed.Header, err)
+	}
+
+	// A token naming the RSA key but signed with HS256 and the public key as secret must not be accepted.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	forged.Header["kid"] = "old"
+	forgedToken, err := forged.SignedString([]byte(rsaPublicPEM))
+	if err != nil {
+		t.Fatalf("Could not sign the forged token: %v", err)
+	}
+	_, _, err = VerifyToken(forgedToken)
+	if err == nil {
+		t.Error("VerifyToken of a token with another algorithm than its key succeeded, want an error")
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"userId": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	unknown.Header["kid"] = "unknown"
+	unknownToken, err := unknown.SignedString(edPrivate)
+	if err != nil {
+		t.Fatalf("Could not sign the token: %v", err)
+	}
+	_, _, err = VerifyToken(unknownToken)
+	if err == nil {
+		t.Error("VerifyToken of a token with an unknown kid succeeded, want an error")
+	}
+
+	t.Setenv("JWT_KEYS", keysJSON(t, keysConfig{SigningKey: "new", Keys: []keyConfig{{Kid: "new", Alg: "EdDSA", PrivateKey: edPrivatePEM}}}))
+	InitKeys()
+	_, _, err = VerifyToken(oldToken)
+	if err == nil {
+		t.Error("VerifyToken of a token signed by a removed key succeeded, want an error")
+	}
+	_, _, err = VerifyToken(newToken)
+	if err != nil {
+		t.Errorf("VerifyToken of a token signed by the current key: %v", err)
+	}
+}
+
+func TestPublicJWKS(t *testing.T) {
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, rsaPublicPEM := testKeyPEM(t, rsaPrivate, &rsaPrivate.PublicKey)
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecPrivatePEM, _ := testKeyPEM(t, ecPrivate, &ecPrivate.PublicKey)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, edPublicPEM := testKeyPEM(t, edPrivate, edPublic)
+
+	setKeysEnv(t, "", keysJSON(t, keysConfig{SigningKey: "b-ec", Keys: []keyConfig{
+		{Kid: "c-rsa", Alg: "RS256", PublicKey: rsaPublicPEM},
+		{Kid: "b-ec", Alg: "ES256", PrivateKey: ecPrivatePEM},
+		{Kid: "a-hmac", Alg: "HS256", Secret: "never published"},
+		{Kid: "d-ed", Alg: "EdDSA", PublicKey: edPublicPEM},
+	}}), "")
+	InitKeys()
+
+	jwks := PublicJWKS()
+	if len(jwks) != 3 {
+		t.Fatalf("PublicJWKS = %+v, want the three asymmetric keys", jwks)
+	}
+	ec, rsaKey, ed := jwks[0], jwks[1], jwks[2]
+	if ec.Kid != "b-ec" || ec.Kty != "EC" || ec.Crv != "P-256" || ec.Alg != "ES256" || ec.Use != "sig" ||
+		ec.X != base64.RawURLEncoding.EncodeToString(ecPrivate.X.FillBytes(make([]byte, 32))) ||
+		ec.Y != base64.RawURLEncoding.EncodeToString(ecPrivate.Y.FillBytes(make([]byte, 32))) {
+		t.Errorf("PublicJWKS returned the EC key %+v", ec)
+	}
+	if rsaKey.Kid != "c-rsa" || rsaKey.Kty != "RSA" || rsaKey.Alg != "RS256" ||
+		rsaKey.N != base64.RawURLEncoding.EncodeToString(rsaPrivate.N.Bytes()) ||
+		rsaKey.E != base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaPrivate.E)).Bytes()) {
+		t.Errorf("PublicJWKS returned the RSA key %+v", rsaKey)
+	}
+	if ed.Kid != "d-ed" || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" ||
+		ed.X != base64.RawURLEncoding.EncodeToString(edPublic) {
+		t.Errorf("PublicJWKS returned the Ed25519 key %+v", ed)
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateOpaqueToken returns a random, URL-safe token built from 32 bytes of crypto/rand output.
+// The token carries no information by itself; it is only meaningful when looked up server-side.
+// It returns an error if the system random source could not be read.
+func GenerateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token.
+// Opaque tokens are stored in the database only in this hashed form, so a leaked
+// database dump cannot be used to replay them. A fast hash is sufficient because the
+// tokens are long random values and not user chosen passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}